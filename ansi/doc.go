@@ -0,0 +1,24 @@
+/*
+Package ansi implements a renderer of parsed markdown document for
+terminals, using ANSI escape codes for styles and OSC 8 escape codes for
+hyperlinks.
+
+	import (
+		"github.com/gomarkdown/markdown"
+		"github.com/gomarkdown/markdown/ansi"
+	)
+
+	opts := ansi.RendererOptions{
+		Width: 80,
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		opts.Flags |= ansi.NoColor
+	}
+	renderer := ansi.NewRenderer(opts)
+	os.Stdout.Write(markdown.Render(doc, renderer))
+
+Code blocks and tables are drawn with box-drawing characters. The styles
+are set with a Theme. With the NoColor flag the output has the same layout,
+without escape codes.
+*/
+package ansi
@@ -0,0 +1,740 @@
+package ansi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Flags control optional behavior of ANSI renderer.
+type Flags int
+
+// ANSI renderer configuration options.
+const (
+	FlagsNone    Flags = 0
+	NoColor      Flags = 1 << iota // Write the same layout without escape codes
+	NoHyperlinks                   // Write links as "text (url)" instead of OSC 8 hyperlinks
+)
+
+// Theme has the styles of the rendered elements. A style is a list of SGR
+// parameters separated by ';', e.g. "1;31" for bold red. An empty style
+// leaves the element unstyled.
+type Theme struct {
+	Headings []string // by level, the last one is used for deeper levels
+	Emph     string
+	Strong   string
+	Del      string
+	Code     string // inline code and code blocks
+	Link     string
+	Quote    string // the bar in front of block quotes
+	Border   string // the borders of code blocks and tables
+	Rule     string // horizontal rules
+}
+
+// DefaultTheme returns the theme used if RendererOptions.Theme is not set.
+func DefaultTheme() *Theme {
+	return &Theme{
+		Headings: []string{"1;4;35", "1;35", "1;36", "1"},
+		Emph:     "3",
+		Strong:   "1",
+		Del:      "9",
+		Code:     "36",
+		Link:     "4;34",
+		Quote:    "2",
+		Border:   "2",
+		Rule:     "2",
+	}
+}
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of ANSI renderer.
+type RendererOptions struct {
+	// Width wraps lines at Width columns, usually the width of the
+	// terminal. If 0, paragraphs are written on one line.
+	Width int
+
+	// Theme has the styles of the elements. If nil, DefaultTheme() is used.
+	Theme *Theme
+
+	Flags Flags // Flags allow customizing this renderer's behavior
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer implements Renderer interface for terminal output with ANSI
+// escape codes.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	// blocks are written line by line, prefixed with the indentation and
+	// quote bars of the blocks they are nested in. The first line of a
+	// list item starts with the marker instead.
+	prefixes []string
+	marker   string
+	inline   bytes.Buffer // text of the current block
+	styles   []string     // styles in effect in inline
+	lists    []int        // number of the next item of the nested lists
+	wrote    bool         // anything has been written
+	blank    bool         // write a blank line before the next block
+
+	table  [][]string // rows of the current table
+	row    []string
+	aligns []ast.CellAlignFlags
+	header int // number of header rows
+}
+
+// NewRenderer creates and configures a Renderer object, which
+// satisfies the Renderer interface.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.Theme == nil {
+		opts.Theme = DefaultTheme()
+	}
+	return &Renderer{Opts: opts}
+}
+
+func (r *Renderer) colors() bool {
+	return r.Opts.Flags&NoColor == 0
+}
+
+// sgr returns the escape sequence setting style
+func sgr(style string) string {
+	return "\x1b[" + style + "m"
+}
+
+const (
+	sgrReset  = "\x1b[0m"
+	osc8Close = "\x1b]8;;\x1b\\"
+)
+
+// styled returns s with style, for text outside of inline
+func (r *Renderer) styled(s, style string) string {
+	if !r.colors() || style == "" {
+		return s
+	}
+	return sgr(style) + s + sgrReset
+}
+
+// startStyle starts style in inline
+func (r *Renderer) startStyle(style string) {
+	if !r.colors() {
+		style = ""
+	}
+	r.styles = append(r.styles, style)
+	if style != "" {
+		r.inline.WriteString(sgr(style))
+	}
+}
+
+// endStyle ends the last started style in inline and restores the
+// outer ones
+func (r *Renderer) endStyle() {
+	style := r.styles[len(r.styles)-1]
+	r.styles = r.styles[:len(r.styles)-1]
+	if style == "" {
+		return
+	}
+	r.inline.WriteString(sgrReset)
+	for _, s := range r.styles {
+		if s != "" {
+			r.inline.WriteString(sgr(s))
+		}
+	}
+}
+
+// clean removes control characters from text, so the document can't
+// write its own escape sequences
+func clean(d []byte) []byte {
+	return bytes.Map(func(c rune) rune {
+		switch {
+		case c == '\t':
+			return ' '
+		case c < ' ' && c != '\n', c == 0x7f, c >= 0x80 && c < 0xa0:
+			return -1
+		}
+		return c
+	}, d)
+}
+
+var escapeRe = regexp.MustCompile("\x1b\\[[0-9;]*m|\x1b]8;;[^\x1b]*\x1b\\\\")
+
+// visibleWidth returns the number of columns s takes in a terminal
+func visibleWidth(s string) int {
+	return stringWidth(escapeRe.ReplaceAllString(s, ""))
+}
+
+// escapeState is the style and link in effect after a sequence of
+// escape codes
+type escapeState struct {
+	sgr  string
+	link string
+}
+
+func (st *escapeState) update(s string) {
+	for _, esc := range escapeRe.FindAllString(s, -1) {
+		switch {
+		case esc == sgrReset:
+			st.sgr = ""
+		case esc == osc8Close:
+			st.link = ""
+		case strings.HasPrefix(esc, "\x1b]"):
+			st.link = esc
+		default:
+			st.sgr += esc
+		}
+	}
+}
+
+// end returns the escape codes that end the state
+func (st *escapeState) end() string {
+	s := ""
+	if st.sgr != "" {
+		s += sgrReset
+	}
+	if st.link != "" {
+		s += osc8Close
+	}
+	return s
+}
+
+// wrap splits s into lines of at most width columns. Styles and links that
+// are in effect at the end of a line are ended, and started again on the
+// next line.
+func wrap(s string, width int) []string {
+	var lines []string
+	for _, hardLine := range strings.Split(s, "\n") {
+		if width <= 0 {
+			lines = append(lines, strings.Join(strings.Fields(hardLine), " "))
+			continue
+		}
+		line, n := "", 0
+		for _, word := range strings.Fields(hardLine) {
+			wn := visibleWidth(word)
+			switch {
+			case line == "":
+				line, n = word, wn
+			case n+1+wn <= width:
+				line += " " + word
+				n += 1 + wn
+			default:
+				lines = append(lines, line)
+				line, n = word, wn
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	// only the text of a line updates the state, the codes carried over
+	// from the previous line are already in it
+	var st escapeState
+	for i, line := range lines {
+		carried := st.sgr + st.link
+		st.update(line)
+		lines[i] = carried + line + st.end()
+	}
+	return lines
+}
+
+func (r *Renderer) pushPrefix(prefix string) {
+	r.prefixes = append(r.prefixes, prefix)
+}
+
+func (r *Renderer) popPrefix() {
+	r.prefixes = r.prefixes[:len(r.prefixes)-1]
+}
+
+// prefix returns the prefix of the next line
+func (r *Renderer) prefix() string {
+	if r.marker == "" {
+		return strings.Join(r.prefixes, "")
+	}
+	n := len(r.prefixes) - 1
+	return strings.Join(r.prefixes[:n], "") + r.marker
+}
+
+// writeLines writes the lines of a block to w
+func (r *Renderer) writeLines(w io.Writer, lines []string) {
+	if r.wrote && r.blank {
+		r.writeBlank(w)
+	}
+	for _, line := range lines {
+		io.WriteString(w, strings.TrimRight(r.prefix()+line, " ")+"\n")
+		r.marker = ""
+	}
+	r.wrote = true
+	r.blank = true
+}
+
+// writeBlank writes a blank line, with the quote bars of the blocks it's
+// nested in
+func (r *Renderer) writeBlank(w io.Writer) {
+	io.WriteString(w, strings.TrimRight(strings.Join(r.prefixes, ""), " ")+"\n")
+}
+
+// flushInline writes the text of the current block, wrapped at
+// Opts.Width
+func (r *Renderer) flushInline(w io.Writer) {
+	text := strings.TrimSpace(r.inline.String())
+	r.inline.Reset()
+	width := 0
+	if r.Opts.Width > 0 {
+		width = r.Opts.Width - visibleWidth(r.prefix())
+		if width < 1 {
+			width = 1
+		}
+	}
+	r.writeLines(w, wrap(text, width))
+}
+
+func isTightList(node ast.Node) bool {
+	list, ok := node.(*ast.List)
+	return ok && list.Tight
+}
+
+// Heading writes ast.Heading node
+func (r *Renderer) Heading(w io.Writer, hdr *ast.Heading, entering bool) {
+	if !entering {
+		r.endStyle()
+		r.flushInline(w)
+		return
+	}
+	styles := r.Opts.Theme.Headings
+	style := ""
+	if len(styles) > 0 {
+		i := hdr.Level - 1
+		if i >= len(styles) {
+			i = len(styles) - 1
+		}
+		style = styles[i]
+	}
+	r.startStyle(style)
+}
+
+// Paragraph writes ast.Paragraph node
+func (r *Renderer) Paragraph(w io.Writer, para *ast.Paragraph, entering bool) {
+	if entering {
+		return
+	}
+	if _, ok := para.Parent.(*ast.TableCell); ok {
+		return
+	}
+	r.flushInline(w)
+	if item, ok := para.Parent.(*ast.ListItem); ok && isTightList(item.Parent) {
+		r.blank = false
+	}
+}
+
+// List writes ast.List node
+func (r *Renderer) List(w io.Writer, list *ast.List, entering bool) {
+	if !entering {
+		r.lists = r.lists[:len(r.lists)-1]
+		r.blank = true
+		return
+	}
+	start := list.Start
+	if start == 0 {
+		start = 1
+	}
+	r.lists = append(r.lists, start)
+}
+
+// ListItem writes ast.ListItem node
+func (r *Renderer) ListItem(w io.Writer, item *ast.ListItem, entering bool) {
+	if !entering {
+		if r.inline.Len() > 0 {
+			// the text of an item without a paragraph, like a footnote
+			r.flushInline(w)
+			r.blank = false
+		}
+		r.popPrefix()
+		r.marker = ""
+		return
+	}
+	list, _ := item.Parent.(*ast.List)
+	var marker string
+	switch {
+	case item.ListFlags&ast.ListTypeTerm != 0:
+		marker = ""
+	case item.ListFlags&ast.ListTypeDefinition != 0:
+		marker = "    "
+	case list != nil && list.IsFootnotesList:
+		n := r.lists[len(r.lists)-1]
+		r.lists[len(r.lists)-1]++
+		marker = "[" + strconv.Itoa(n) + "] "
+	case item.ListFlags&ast.ListTypeOrdered != 0:
+		n := r.lists[len(r.lists)-1]
+		r.lists[len(r.lists)-1]++
+		marker = strconv.Itoa(n) + ". "
+	case item.IsTask && item.Checked:
+		marker = "☑ "
+	case item.IsTask:
+		marker = "☐ "
+	default:
+		marker = "• "
+	}
+	r.pushPrefix(strings.Repeat(" ", stringWidth(marker)))
+	r.marker = marker
+	if item.ListFlags&ast.ListTypeDefinition != 0 && item.ListFlags&ast.ListTypeTerm == 0 {
+		// the definition follows its term
+		r.marker = ""
+		r.blank = false
+	}
+	if item.ListFlags&ast.ListTypeTerm != 0 {
+		r.startStyle(r.Opts.Theme.Strong)
+	}
+}
+
+// Link writes ast.Link node, as an OSC 8 hyperlink
+func (r *Renderer) Link(w io.Writer, link *ast.Link, entering bool) ast.WalkStatus {
+	if link.NoteID != 0 {
+		if entering {
+			r.inline.WriteString("[" + strconv.Itoa(link.NoteID) + "]")
+		}
+		return ast.SkipChildren
+	}
+	dest := string(clean(link.Destination))
+	hyperlink := r.colors() && r.Opts.Flags&NoHyperlinks == 0 && dest != "" && dest[0] != '#'
+	if entering {
+		if hyperlink {
+			r.inline.WriteString("\x1b]8;;" + dest + "\x1b\\")
+		}
+		r.startStyle(r.Opts.Theme.Link)
+		return ast.GoToNext
+	}
+	r.endStyle()
+	if hyperlink {
+		r.inline.WriteString(osc8Close)
+	}
+	if r.Opts.Flags&NoHyperlinks != 0 && dest != "" && dest[0] != '#' {
+		text := string(childrenText(link))
+		if text != dest && "mailto:"+text != dest {
+			r.inline.WriteString(" (" + dest + ")")
+		}
+	}
+	return ast.GoToNext
+}
+
+// childrenText returns the text in the leaves under node
+func childrenText(node ast.Node) []byte {
+	var buf bytes.Buffer
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); leaf != nil && entering {
+			buf.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+// CodeBlock writes ast.CodeBlock node in a box. Lines wider than
+// Opts.Width are wrapped.
+func (r *Renderer) CodeBlock(w io.Writer, literal []byte, info []byte) {
+	code := strings.TrimRight(string(literal), "\n")
+	code = strings.Replace(code, "\t", "    ", -1)
+	lines := strings.Split(string(clean([]byte(code))), "\n")
+	lang := ""
+	if i := bytes.IndexAny(info, " \t{"); i >= 0 {
+		info = info[:i]
+	}
+	if len(info) > 0 {
+		lang = "─ " + string(clean(info)) + " "
+	}
+
+	width := stringWidth(lang)
+	for _, line := range lines {
+		if n := stringWidth(line); n > width {
+			width = n
+		}
+	}
+	if r.Opts.Width > 0 {
+		// the borders and the spaces inside them take 4 columns
+		if max := r.Opts.Width - visibleWidth(r.prefix()) - 4; width > max {
+			width = max
+			if width < 1 {
+				width = 1
+			}
+			var wrapped []string
+			for _, line := range lines {
+				wrapped = append(wrapped, splitWidth(line, width)...)
+			}
+			lines = wrapped
+			if stringWidth(lang) > width+2 {
+				lang = splitWidth(lang, width+2)[0]
+			}
+		}
+	}
+	border := r.Opts.Theme.Border
+	out := []string{r.styled("┌"+lang+strings.Repeat("─", width+2-stringWidth(lang))+"┐", border)}
+	for _, line := range lines {
+		spaces := pad("", width-stringWidth(line), 0)
+		out = append(out, r.styled("│", border)+" "+r.styled(line, r.Opts.Theme.Code)+spaces+" "+r.styled("│", border))
+	}
+	out = append(out, r.styled("└"+strings.Repeat("─", width+2)+"┘", border))
+	r.writeLines(w, out)
+}
+
+// TableCell writes ast.TableCell node
+func (r *Renderer) TableCell(w io.Writer, cell *ast.TableCell, entering bool) {
+	if entering {
+		if cell.IsHeader {
+			r.startStyle(r.Opts.Theme.Strong)
+		}
+		return
+	}
+	if cell.IsHeader {
+		r.endStyle()
+	}
+	text := strings.Join(strings.Fields(r.inline.String()), " ")
+	r.inline.Reset()
+	r.row = append(r.row, text)
+	if len(r.table) == 0 {
+		r.aligns = append(r.aligns, cell.Align)
+	}
+	for i := 1; i < cell.ColSpan; i++ {
+		r.row = append(r.row, "")
+		if len(r.table) == 0 {
+			r.aligns = append(r.aligns, cell.Align)
+		}
+	}
+}
+
+// TableRow writes ast.TableRow node
+func (r *Renderer) TableRow(w io.Writer, row *ast.TableRow, entering bool) {
+	if entering {
+		return
+	}
+	r.table = append(r.table, r.row)
+	r.row = nil
+	if _, ok := row.Parent.(*ast.TableHeader); ok {
+		r.header = len(r.table)
+	}
+}
+
+// Table writes ast.Table node, with box-drawing borders
+func (r *Renderer) Table(w io.Writer, table *ast.Table, entering bool) {
+	if entering {
+		r.table, r.aligns, r.header = nil, nil, 0
+		return
+	}
+	var widths []int
+	for _, row := range r.table {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := visibleWidth(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	border := r.Opts.Theme.Border
+	rule := func(left, middle, right string) string {
+		parts := make([]string, len(widths))
+		for i, n := range widths {
+			parts[i] = strings.Repeat("─", n+2)
+		}
+		return r.styled(left+strings.Join(parts, middle)+right, border)
+	}
+	bar := r.styled("│", border)
+
+	lines := []string{rule("┌", "┬", "┐")}
+	for i, row := range r.table {
+		line := bar
+		for j := range widths {
+			cell := ""
+			if j < len(row) {
+				cell = row[j]
+			}
+			var align ast.CellAlignFlags
+			if j < len(r.aligns) {
+				align = r.aligns[j]
+			}
+			line += " " + pad(cell, widths[j], align) + " " + bar
+		}
+		lines = append(lines, line)
+		if i == r.header-1 && i < len(r.table)-1 {
+			lines = append(lines, rule("├", "┼", "┤"))
+		}
+	}
+	lines = append(lines, rule("└", "┴", "┘"))
+	r.table = nil
+	r.writeLines(w, lines)
+}
+
+// pad pads s with spaces to width columns
+func pad(s string, width int, align ast.CellAlignFlags) string {
+	n := width - visibleWidth(s)
+	if n <= 0 {
+		return s
+	}
+	switch align {
+	case ast.TableAlignmentRight:
+		return strings.Repeat(" ", n) + s
+	case ast.TableAlignmentCenter:
+		return strings.Repeat(" ", n/2) + s + strings.Repeat(" ", n-n/2)
+	}
+	return s + strings.Repeat(" ", n)
+}
+
+// HorizontalRule writes ast.HorizontalRule node
+func (r *Renderer) HorizontalRule(w io.Writer) {
+	width := r.Opts.Width - visibleWidth(r.prefix())
+	if r.Opts.Width <= 0 || width > 80 {
+		width = 80
+	}
+	if width < 1 {
+		width = 1
+	}
+	r.writeLines(w, []string{r.styled(strings.Repeat("─", width), r.Opts.Theme.Rule)})
+}
+
+// RenderNode renders a markdown node to text with ANSI escape codes
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	theme := r.Opts.Theme
+	switch node := node.(type) {
+	case *ast.Document, *ast.DocumentMatter, *ast.Footnotes, *ast.CaptionFigure, *ast.TOC, *ast.Section,
+		*ast.FrontMatter, *ast.FencedDiv:
+		// do nothing
+	case *ast.Text:
+		// newlines in text are soft breaks
+		r.inline.Write(bytes.Replace(clean(node.Literal), []byte("\n"), []byte(" "), -1))
+	case *ast.Code, *ast.Math:
+		r.startStyle(theme.Code)
+		r.inline.Write(clean(node.AsLeaf().Literal))
+		r.endStyle()
+	case *ast.Subscript, *ast.Superscript:
+		r.inline.Write(clean(node.AsLeaf().Literal))
+	case *ast.Softbreak, *ast.NonBlockingSpace:
+		r.inline.WriteString(" ")
+	case *ast.Hardbreak:
+		r.inline.WriteString("\n")
+	case *ast.Emph:
+		r.styleOneOf(entering, theme.Emph)
+	case *ast.Strong:
+		r.styleOneOf(entering, theme.Strong)
+	case *ast.Del:
+		r.styleOneOf(entering, theme.Del)
+	case *ast.Image:
+		// the alt text
+	case *ast.HTMLSpan, *ast.HTMLBlock, *ast.Index:
+		// nothing to show
+	case *ast.Callout:
+		r.inline.WriteString("(" + string(clean(node.ID)) + ")")
+	case *ast.Citation:
+		r.inline.WriteString("[" + string(clean(bytes.Join(node.Destination, []byte("; ")))) + "]")
+	case *ast.CrossReference:
+		if entering && len(node.GetChildren()) == 0 {
+			r.inline.Write(clean(node.Destination))
+		}
+	case *ast.Link:
+		return r.Link(w, node, entering)
+	case *ast.WikiLink:
+		return r.Link(w, node.AsLink(), entering)
+	case *ast.Paragraph:
+		r.Paragraph(w, node, entering)
+	case *ast.Heading:
+		r.Heading(w, node, entering)
+	case *ast.Caption:
+		r.styleOneOf(entering, theme.Emph)
+		if !entering {
+			r.flushInline(w)
+		}
+	case *ast.BlockQuote, *ast.Aside:
+		if entering {
+			if r.wrote && r.blank {
+				// the blank line before the quote has no bar
+				r.writeBlank(w)
+				r.blank = false
+			}
+			r.pushPrefix(r.styled("│", theme.Quote) + " ")
+		} else {
+			r.popPrefix()
+		}
+	case *ast.Admonition:
+		if entering {
+			if r.wrote && r.blank {
+				r.writeBlank(w)
+				r.blank = false
+			}
+			r.pushPrefix(r.styled("│", theme.Quote) + " ")
+			r.startStyle(theme.Strong)
+			r.inline.Write(clean(admonitionTitle(node)))
+			r.endStyle()
+			r.flushInline(w)
+			r.blank = false
+		} else {
+			r.popPrefix()
+		}
+	case *ast.CodeBlock:
+		r.CodeBlock(w, node.Literal, node.Info)
+	case *ast.MathBlock:
+		if entering {
+			r.CodeBlock(w, bytes.TrimSpace(node.Literal), nil)
+		}
+		return ast.SkipChildren
+	case *ast.HorizontalRule:
+		r.HorizontalRule(w)
+	case *ast.List:
+		r.List(w, node, entering)
+	case *ast.ListItem:
+		if !entering && node.ListFlags&ast.ListTypeTerm != 0 {
+			r.endStyle()
+		}
+		r.ListItem(w, node, entering)
+	case *ast.Table:
+		r.Table(w, node, entering)
+	case *ast.TableHeader, *ast.TableBody, *ast.TableFooter:
+		// the rows are written by Table
+	case *ast.TableRow:
+		r.TableRow(w, node, entering)
+	case *ast.TableCell:
+		r.TableCell(w, node, entering)
+	default:
+		panic(fmt.Sprintf("Unknown node %T", node))
+	}
+	return ast.GoToNext
+}
+
+func admonitionTitle(node *ast.Admonition) []byte {
+	if len(node.Title) > 0 {
+		return node.Title
+	}
+	return []byte(node.Kind.Title())
+}
+
+// styleOneOf starts style when entering a node and ends it when leaving
+func (r *Renderer) styleOneOf(entering bool, style string) {
+	if entering {
+		r.startStyle(style)
+	} else {
+		r.endStyle()
+	}
+}
+
+// RenderHeader writes nothing
+func (r *Renderer) RenderHeader(w io.Writer, _ ast.Node) {
+}
+
+// RenderFooter writes nothing
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+}
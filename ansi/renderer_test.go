@@ -0,0 +1,160 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func renderString(input string, opts RendererOptions) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Footnotes)
+	doc := p.Parse([]byte(input))
+	return string(markdown.Render(doc, NewRenderer(opts)))
+}
+
+func doTests(t *testing.T, tests []string, opts RendererOptions) {
+	t.Helper()
+	for i := 0; i < len(tests); i += 2 {
+		got := renderString(tests[i], opts)
+		if got != tests[i+1] {
+			t.Errorf("\nInput:    %q\nExpected: %q\nGot:      %q", tests[i], tests[i+1], got)
+		}
+	}
+}
+
+func TestRenderNoColor(t *testing.T) {
+	tests := []string{
+		"# Title\n\nSome *emph*, **strong** and `code`\non two lines.\n",
+		"Title\n\nSome emph, strong and code on two lines.\n",
+
+		"* a\n* b\n    1. c\n    2. d\n",
+		"• a\n• b\n  1. c\n  2. d\n",
+
+		"> quote\n>\n> * item\n",
+		"│ quote\n│\n│ • item\n",
+
+		"```go\nfunc main() {}\n```\n",
+		"┌─ go ───────────┐\n│ func main() {} │\n└────────────────┘\n",
+
+		"| Name | Age |\n|:-----|----:|\n| Bob  | 7   |\n| Alice | 23 |\n",
+		"┌───────┬─────┐\n│ Name  │ Age │\n├───────┼─────┤\n│ Bob   │   7 │\n│ Alice │  23 │\n└───────┴─────┘\n",
+
+		"[link](http://x.org)\n\n***\n",
+		"link\n\n" + "────────────────────────────────────────────────────────────────────────────────\n",
+
+		"Note[^1].\n\n[^1]: The \x1b[31mnote\x07.\n",
+		"Note[1].\n\n[1] The [31mnote.\n",
+	}
+	doTests(t, tests, RendererOptions{Flags: NoColor})
+}
+
+func TestRenderNoHyperlinks(t *testing.T) {
+	tests := []string{
+		"[link](http://x.org) <http://y.org> [ref](#sec)\n",
+		"link (http://x.org) http://y.org ref\n",
+	}
+	doTests(t, tests, RendererOptions{Flags: NoColor | NoHyperlinks})
+}
+
+func TestRenderColor(t *testing.T) {
+	theme := &Theme{
+		Headings: []string{"1"},
+		Emph:     "3",
+		Strong:   "1",
+		Link:     "4",
+		Code:     "36",
+		Border:   "2",
+	}
+	tests := []string{
+		"# *Title*\n",
+		"\x1b[1m\x1b[3mTitle\x1b[0m\x1b[1m\x1b[0m\n",
+
+		"[a *b*](http://x.org)\n",
+		"\x1b]8;;http://x.org\x1b\\\x1b[4ma \x1b[3mb\x1b[0m\x1b[4m\x1b[0m\x1b]8;;\x1b\\\n",
+
+		"```\nx\n```\n",
+		"\x1b[2m┌───┐\x1b[0m\n\x1b[2m│\x1b[0m \x1b[36mx\x1b[0m \x1b[2m│\x1b[0m\n\x1b[2m└───┘\x1b[0m\n",
+	}
+	doTests(t, tests, RendererOptions{Theme: theme})
+}
+
+func TestRenderWidth(t *testing.T) {
+	tests := []string{
+		"The quick brown fox jumps over the lazy dog.\n",
+		"The quick brown\nfox jumps over the\nlazy dog.\n",
+
+		"> The quick brown fox jumps over the lazy dog.\n",
+		"│ The quick brown\n│ fox jumps over\n│ the lazy dog.\n",
+	}
+	doTests(t, tests, RendererOptions{Width: 18, Flags: NoColor})
+
+	// code blocks are wrapped to fit
+	tests = []string{
+		"```go\nfmt.Println(\"The quick brown fox\")\n```\n",
+		"┌─ go ──────────────┐\n│ fmt.Println(\"The  │\n│ quick brown fox\") │\n└───────────────────┘\n",
+
+		"> ```\n> 0123456789012345678\n> ```\n",
+		"│ ┌─────────────────┐\n│ │ 012345678901234 │\n│ │ 5678            │\n│ └─────────────────┘\n",
+	}
+	doTests(t, tests, RendererOptions{Width: 21, Flags: NoColor})
+
+	// styles and links are ended at the end of a line and continue on
+	// the next one
+	tests = []string{
+		"The quick [brown fox jumps](http://x.org) over\n",
+		"The quick \x1b]8;;http://x.org\x1b\\\x1b[4mbrown\x1b[0m\x1b]8;;\x1b\\\n" +
+			"\x1b[4m\x1b]8;;http://x.org\x1b\\fox jumps\x1b[0m\x1b]8;;\x1b\\ over\n",
+	}
+	doTests(t, tests, RendererOptions{Width: 18, Theme: &Theme{Link: "4"}})
+}
+
+func TestRenderWideCharacters(t *testing.T) {
+	tests := []string{
+		"| 名前 | Age |\n|------|-----|\n| 山田太郎 | 7 |\n| Bob | 23 |\n",
+		"┌──────────┬─────┐\n│ 名前     │ Age │\n├──────────┼─────┤\n│ 山田太郎 │ 7   │\n│ Bob      │ 23  │\n└──────────┴─────┘\n",
+
+		"```\n日本語 e\u0301\n```\n",
+		"┌──────────┐\n│ 日本語 e\u0301 │\n└──────────┘\n",
+	}
+	doTests(t, tests, RendererOptions{Flags: NoColor})
+
+	// wide characters are wrapped by columns
+	tests = []string{
+		"```\n日本語のテキスト\n```\n",
+		"┌───────┐\n│ 日本  │\n│ 語の  │\n│ テキ  │\n│ スト  │\n└───────┘\n",
+	}
+	doTests(t, tests, RendererOptions{Width: 9, Flags: NoColor})
+}
+
+// escapePrefix returns the length of the escape codes at the start of s
+func escapePrefix(s string) int {
+	n := 0
+	for loc := escapeRe.FindStringIndex(s[n:]); loc != nil && loc[0] == 0; loc = escapeRe.FindStringIndex(s[n:]) {
+		n += loc[1]
+	}
+	return n
+}
+
+func TestRenderWrapStyles(t *testing.T) {
+	tests := []struct {
+		input  string
+		prefix string // the escape codes each line starts with
+	}{
+		{"**" + strings.Repeat("bold words ", 30) + "end**\n", "\x1b[1m"},
+		{"[" + strings.Repeat("link words ", 30) + "end](http://x.org)\n", "\x1b[4m\x1b]8;;http://x.org\x1b\\"},
+	}
+	for _, test := range tests {
+		got := renderString(test.input, RendererOptions{Width: 20, Theme: &Theme{Strong: "1", Link: "4"}})
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		if len(lines) < 10 {
+			t.Fatalf("expected at least 10 lines, got %d:\n%s", len(lines), got)
+		}
+		for i, line := range lines {
+			if n := escapePrefix(line); n != len(test.prefix) {
+				t.Errorf("line %d starts with %d bytes of escape codes, expected %d: %q", i, n, len(test.prefix), line)
+			}
+		}
+	}
+}
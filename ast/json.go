@@ -0,0 +1,473 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JSON encoding of the AST
+//
+// Every node is a JSON object with its type in "$type", e.g. "Heading", the
+// fields of the node type with the name starting with a lower case letter,
+// e.g. "level", and its children:
+//
+//	{"$type":"Heading","level":1,"children":[{"$type":"Text","literal":"title"}]}
+//
+// Fields with the zero value are left out. []byte is written as a string,
+// or as {"$base64":s} if it isn't valid UTF-8, where s is it in base64.
+// The fields of Container and Leaf are "literal", "content", "source" and
+// "attribute". A field pointing to another node of the tree, like
+// Link.Footnote, is written as {"$ref":n}, where n is the index of the node in
+// the tree, in depth-first order, starting at 0.
+
+var (
+	jsonTypesMu sync.RWMutex
+	jsonTypes   = map[string]reflect.Type{}
+	jsonNames   = map[reflect.Type]string{}
+)
+
+func init() {
+	for _, n := range []Node{
+		&Document{}, &DocumentMatter{}, &BlockQuote{}, &Aside{}, &List{},
+		&ListItem{}, &Paragraph{}, &Math{}, &MathBlock{}, &Heading{},
+		&HorizontalRule{}, &Emph{}, &Strong{}, &Del{}, &Link{},
+		&CrossReference{}, &Citation{}, &Image{}, &Text{}, &HTMLBlock{},
+		&CodeBlock{}, &Softbreak{}, &Hardbreak{}, &NonBlockingSpace{},
+		&Code{}, &HTMLSpan{}, &Table{}, &TableCell{}, &TableHeader{},
+		&TableBody{}, &TableRow{}, &TableFooter{}, &Caption{},
+		&CaptionFigure{}, &Callout{}, &Index{}, &Subscript{},
+		&Superscript{}, &Footnotes{}, &TOC{}, &Section{}, &FrontMatter{},
+		&Admonition{}, &FencedDiv{}, &WikiLink{},
+	} {
+		RegisterNodeType(reflect.TypeOf(n).Elem().Name(), n)
+	}
+}
+
+// RegisterNodeType registers a custom node type for ToJSON and FromJSON.
+// name is the "$type" of the node in JSON. node is a pointer to a struct,
+// which embeds Container or Leaf, e.g. &MyNode{}.
+func RegisterNodeType(name string, node Node) {
+	t := reflect.TypeOf(node)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("ast: node type %s is not a pointer to a struct", t))
+	}
+	jsonTypesMu.Lock()
+	defer jsonTypesMu.Unlock()
+	jsonTypes[name] = t.Elem()
+	jsonNames[t.Elem()] = name
+}
+
+var (
+	nodeType      = reflect.TypeOf((*Node)(nil)).Elem()
+	containerType = reflect.TypeOf(Container{})
+	leafType      = reflect.TypeOf(Leaf{})
+	bytesType     = reflect.TypeOf([]byte(nil))
+)
+
+// jsonFieldName returns the name of field in JSON, e.g. "headingID"
+func jsonFieldName(name string) string {
+	return string(unicode.ToLower(rune(name[0]))) + name[1:]
+}
+
+// ToJSON returns the JSON encoding of node and its children.
+func ToJSON(node Node) ([]byte, error) {
+	refs := map[Node]int{}
+	WalkFunc(node, func(n Node, entering bool) WalkStatus {
+		if entering {
+			refs[n] = len(refs)
+		}
+		return GoToNext
+	})
+	v, err := encodeJSONNode(node, refs)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func encodeJSONNode(node Node, refs map[Node]int) (map[string]interface{}, error) {
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ast: can't encode node type %T", node)
+	}
+	v = v.Elem()
+	jsonTypesMu.RLock()
+	name, ok := jsonNames[v.Type()]
+	jsonTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("ast: node type %T is not registered", node)
+	}
+
+	m := map[string]interface{}{"$type": name}
+	if err := encodeJSONFields(m, v, refs); err != nil {
+		return nil, err
+	}
+	if children := node.GetChildren(); len(children) > 0 {
+		list := make([]interface{}, len(children))
+		for i, child := range children {
+			c, err := encodeJSONNode(child, refs)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = c
+		}
+		m["children"] = list
+	}
+	return m, nil
+}
+
+// encodeJSONFields adds the fields of struct v to m
+func encodeJSONFields(m map[string]interface{}, v reflect.Value, refs map[Node]int) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		fv := v.Field(i)
+		if f.Anonymous && (f.Type == containerType || f.Type == leafType) {
+			if err := encodeJSONFields(m, fv, refs); err != nil {
+				return err
+			}
+			continue
+		}
+		switch f.Name {
+		case "Parent", "Children", "Prev", "Next":
+			if f.Type == nodeType || f.Type == reflect.SliceOf(nodeType) {
+				continue
+			}
+		}
+		if f.Type == nodeType {
+			if fv.IsNil() {
+				continue
+			}
+			if idx, ok := refs[fv.Interface().(Node)]; ok {
+				m[jsonFieldName(f.Name)] = map[string]int{"$ref": idx}
+			}
+			continue
+		}
+		if isZeroValue(fv) {
+			continue
+		}
+		val, err := encodeJSONValue(fv)
+		if err != nil {
+			return fmt.Errorf("ast: field %s of %s: %v", f.Name, t.Name(), err)
+		}
+		m[jsonFieldName(f.Name)] = val
+	}
+	return nil
+}
+
+func isZeroValue(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func encodeJSONValue(v reflect.Value) (interface{}, error) {
+	if v.Type() == bytesType {
+		if !utf8.Valid(v.Bytes()) {
+			return map[string]interface{}{"$base64": base64.StdEncoding.EncodeToString(v.Bytes())}, nil
+		}
+		return string(v.Bytes()), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encodeJSONValue(v.Elem())
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || isZeroValue(v.Field(i)) {
+				continue
+			}
+			val, err := encodeJSONValue(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			m[jsonFieldName(f.Name)] = val
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			val, err := encodeJSONValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = val
+		}
+		return list, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map type %s", v.Type())
+		}
+		m := map[string]interface{}{}
+		iter := v.MapRange()
+		for iter.Next() {
+			val, err := encodeJSONValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = val
+		}
+		return m, nil
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v.Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// FromJSON returns the node encoded in JSON by ToJSON.
+func FromJSON(data []byte) (Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	d := &jsonDecoder{}
+	node, err := d.decodeNode(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range d.refs {
+		if ref.idx < 0 || ref.idx >= len(d.nodes) {
+			return nil, fmt.Errorf("ast: invalid $ref %d", ref.idx)
+		}
+		ref.field.Set(reflect.ValueOf(d.nodes[ref.idx]))
+	}
+	return node, nil
+}
+
+type jsonDecoder struct {
+	nodes []Node // in depth-first order, for references
+	refs  []jsonRef
+}
+
+// jsonRef is a field that references another node
+type jsonRef struct {
+	field reflect.Value
+	idx   int
+}
+
+func (d *jsonDecoder) decodeNode(m map[string]interface{}) (Node, error) {
+	name, _ := m["$type"].(string)
+	jsonTypesMu.RLock()
+	t, ok := jsonTypes[name]
+	jsonTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("ast: unknown node type %q", name)
+	}
+	v := reflect.New(t)
+	node, ok := v.Interface().(Node)
+	if !ok {
+		return nil, fmt.Errorf("ast: type %s is not a node", t)
+	}
+	d.nodes = append(d.nodes, node)
+	if err := d.decodeFields(m, v.Elem()); err != nil {
+		return nil, err
+	}
+
+	children, _ := m["children"].([]interface{})
+	if len(children) > 0 && node.AsContainer() == nil {
+		return nil, fmt.Errorf("ast: leaf node %s has children", name)
+	}
+	for _, c := range children {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ast: child of %s is not a node", name)
+		}
+		child, err := d.decodeNode(cm)
+		if err != nil {
+			return nil, err
+		}
+		AppendChild(node, child)
+	}
+	return node, nil
+}
+
+// decodeFields sets the fields of struct v from m
+func (d *jsonDecoder) decodeFields(m map[string]interface{}, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && (f.Type == containerType || f.Type == leafType) {
+			if err := d.decodeFields(m, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.Type == nodeType || f.Type == reflect.SliceOf(nodeType) {
+			if f.Type == nodeType {
+				if ref, ok := m[jsonFieldName(f.Name)].(map[string]interface{}); ok {
+					idx, err := jsonInt(ref["$ref"])
+					if err != nil {
+						return fmt.Errorf("ast: field %s of %s: %v", f.Name, t.Name(), err)
+					}
+					d.refs = append(d.refs, jsonRef{fv, int(idx)})
+				}
+			}
+			continue
+		}
+		val, ok := m[jsonFieldName(f.Name)]
+		if !ok {
+			continue
+		}
+		if err := decodeJSONValue(val, fv); err != nil {
+			return fmt.Errorf("ast: field %s of %s: %v", f.Name, t.Name(), err)
+		}
+	}
+	return nil
+}
+
+func jsonInt(val interface{}) (int64, error) {
+	n, ok := val.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a number", val)
+	}
+	return n.Int64()
+}
+
+func decodeJSONValue(val interface{}, v reflect.Value) error {
+	if val == nil {
+		return nil
+	}
+	if v.Type() == bytesType {
+		if m, ok := val.(map[string]interface{}); ok {
+			s, ok := m["$base64"].(string)
+			if !ok {
+				return fmt.Errorf("%v is not base64 bytes", val)
+			}
+			d, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(d)
+			return nil
+		}
+		s, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a string", val)
+		}
+		v.SetBytes([]byte(s))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := decodeJSONValue(val, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case reflect.Struct:
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not an object", val)
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if fval, ok := m[jsonFieldName(f.Name)]; ok {
+				if err := decodeJSONValue(fval, v.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case reflect.Slice:
+		list, ok := val.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not an array", val)
+		}
+		s := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := decodeJSONValue(item, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Map:
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not an object", val)
+		}
+		mv := reflect.MakeMapWithSize(v.Type(), len(m))
+		for k, item := range m {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeJSONValue(item, ev); err != nil {
+				return err
+			}
+			mv.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), ev)
+		}
+		v.Set(mv)
+		return nil
+	case reflect.Bool:
+		b, ok := val.(bool)
+		if !ok {
+			return fmt.Errorf("%v is not a bool", val)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a string", val)
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := jsonInt(val)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := jsonInt(val)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%v is not an unsigned number", val)
+		}
+		v.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := val.(json.Number)
+		if !ok {
+			return fmt.Errorf("%v is not a number", val)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", strings.TrimPrefix(v.Type().String(), "ast."))
+}
@@ -0,0 +1,160 @@
+package ast_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../testdata/*.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, "../testdata/mmark.test", "../testdata/roundtrip.md")
+	for _, file := range files {
+		input, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exts := parser.CommonExtensions | parser.Footnotes | parser.Mmark | parser.Attributes
+		doc := parser.NewWithExtensions(exts).Parse(input)
+		data, err := ast.ToJSON(doc)
+		if err != nil {
+			t.Fatalf("%s: ToJSON() failed with %v", file, err)
+		}
+		decoded, err := ast.FromJSON(data)
+		if err != nil {
+			t.Fatalf("%s: FromJSON() failed with %v", file, err)
+		}
+		data2, err := ast.ToJSON(decoded)
+		if err != nil {
+			t.Fatalf("%s: ToJSON() of the decoded document failed with %v", file, err)
+		}
+		if !bytes.Equal(data, data2) {
+			t.Errorf("%s: JSON changed after a round trip", file)
+		}
+		exp := markdown.Render(doc, html.NewRenderer(html.RendererOptions{}))
+		got := markdown.Render(decoded, html.NewRenderer(html.RendererOptions{}))
+		if !bytes.Equal(exp, got) {
+			t.Errorf("%s: decoded document renders differently", file)
+		}
+	}
+}
+
+func TestJSONInvalidUTF8(t *testing.T) {
+	doc := &ast.Document{}
+	para := &ast.Paragraph{}
+	ast.AppendChild(para, &ast.Text{Leaf: ast.Leaf{Literal: []byte("a\xff\xfeb")}})
+	ast.AppendChild(doc, para)
+	data, err := ast.ToJSON(doc)
+	if err != nil {
+		t.Fatalf("ToJSON() failed with %v", err)
+	}
+	exp := `{"$type":"Document","children":[{"$type":"Paragraph","children":[{"$type":"Text","literal":{"$base64":"Yf/+Yg=="}}]}]}`
+	if string(data) != exp {
+		t.Errorf("\nExpected: %s\nGot:      %s", exp, data)
+	}
+	decoded, err := ast.FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON() failed with %v", err)
+	}
+	text := decoded.GetChildren()[0].GetChildren()[0].AsLeaf()
+	if string(text.Literal) != "a\xff\xfeb" {
+		t.Errorf("FromJSON() decoded the literal as %q", text.Literal)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	doc := &ast.Document{}
+	heading := &ast.Heading{Level: 1, HeadingID: "id"}
+	ast.AppendChild(heading, &ast.Text{Leaf: ast.Leaf{Literal: []byte("Title")}})
+	ast.AppendChild(doc, heading)
+	para := &ast.Paragraph{}
+	link := &ast.Link{Destination: []byte("/b?c&d"), Title: []byte("c")}
+	ast.AppendChild(link, &ast.Text{Leaf: ast.Leaf{Literal: []byte("<a>")}})
+	ast.AppendChild(para, link)
+	ast.AppendChild(doc, para)
+	data, err := ast.ToJSON(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"$type":"Document","children":[` +
+		`{"$type":"Heading","children":[{"$type":"Text","literal":"Title"}],"headingID":"id","level":1},` +
+		`{"$type":"Paragraph","children":[{"$type":"Link","children":[{"$type":"Text","literal":"<a>"}],"destination":"/b?c&d","title":"c"}]}` +
+		`]}`
+	if string(data) != exp {
+		t.Errorf("\nExpected: %s\nGot:      %s", exp, data)
+	}
+
+	// footnotes reference their text
+	notes := parser.NewWithExtensions(parser.CommonExtensions | parser.Footnotes).Parse([]byte("a[^1]\n\n[^1]: note\n"))
+	data, err = ast.ToJSON(notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ast.FromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ref *ast.Link
+	ast.WalkFunc(decoded, func(node ast.Node, entering bool) ast.WalkStatus {
+		if l, ok := node.(*ast.Link); ok && l.NoteID != 0 {
+			ref = l
+		}
+		return ast.GoToNext
+	})
+	if ref == nil || ref.Footnote == nil || string(ref.Footnote.(*ast.ListItem).RefLink) != "1" {
+		t.Errorf("footnote not decoded: %+v", ref)
+	}
+
+	for _, input := range []string{
+		`{"$type":"Unknown"}`,
+		`{"$type":"Text","children":[{"$type":"Text"}]}`,
+		`{"$type":"Heading","level":"1"}`,
+		`{"$type":"Link","footnote":{"$ref":5}}`,
+	} {
+		if _, err := ast.FromJSON([]byte(input)); err == nil {
+			t.Errorf("FromJSON(%s) didn't fail", input)
+		}
+	}
+}
+
+type customNode struct {
+	ast.Leaf
+
+	Name  string
+	Count int
+}
+
+func TestJSONCustomNode(t *testing.T) {
+	doc := &ast.Document{}
+	ast.AppendChild(doc, &customNode{Name: "x", Count: 2})
+	if _, err := ast.ToJSON(doc); err == nil {
+		t.Errorf("ToJSON() of an unregistered node didn't fail")
+	}
+
+	ast.RegisterNodeType("Custom", &customNode{})
+	data, err := ast.ToJSON(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"$type":"Document","children":[{"$type":"Custom","count":2,"name":"x"}]}`
+	if string(data) != exp {
+		t.Errorf("\nExpected: %s\nGot:      %s", exp, data)
+	}
+	decoded, err := ast.FromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, ok := decoded.GetChildren()[0].(*customNode)
+	if !ok || node.Name != "x" || node.Count != 2 || node.Parent != decoded {
+		t.Errorf("custom node not decoded: %+v", decoded.GetChildren()[0])
+	}
+}
@@ -1,5 +1,7 @@
 package ast
 
+import "strings"
+
 // An attribute can be attached to block elements. They are specified as
 // {#id .classs key="value"} where quotes for values are mandatory, multiple
 // key/value pairs are separated by whitespace.
@@ -61,6 +63,69 @@ const (
 	DocumentMatterBack
 )
 
+// FrontMatterFormats holds the format of the front matter of a document
+type FrontMatterFormats int
+
+// These are all possible front matter formats, with their delimiters.
+const (
+	FrontMatterNone FrontMatterFormats = iota
+	FrontMatterYAML                    // ---
+	FrontMatterTOML                    // +++
+	FrontMatterJSON                    // ;;;
+)
+
+func (f FrontMatterFormats) String() string {
+	switch f {
+	case FrontMatterYAML:
+		return "YAML"
+	case FrontMatterTOML:
+		return "TOML"
+	case FrontMatterJSON:
+		return "JSON"
+	}
+	return "none"
+}
+
+// AdmonitionKinds holds the kind of an admonition, e.g. a note or a warning
+type AdmonitionKinds int
+
+// These are the kinds of GitHub alerts.
+const (
+	AdmonitionNone AdmonitionKinds = iota
+	AdmonitionNote
+	AdmonitionTip
+	AdmonitionImportant
+	AdmonitionWarning
+	AdmonitionCaution
+)
+
+var admonitionKinds = []string{"none", "note", "tip", "important", "warning", "caution"}
+
+// String returns the kind in lower case, e.g. "note"
+func (k AdmonitionKinds) String() string {
+	if k < 0 || int(k) >= len(admonitionKinds) {
+		return "none"
+	}
+	return admonitionKinds[k]
+}
+
+// Title returns the default title of an admonition of this kind, e.g. "Note"
+func (k AdmonitionKinds) Title() string {
+	s := k.String()
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// AdmonitionKindFromString returns the kind named s, in any case, or
+// AdmonitionNone
+func AdmonitionKindFromString(s string) AdmonitionKinds {
+	for i, kind := range admonitionKinds[1:] {
+		if strings.EqualFold(s, kind) {
+			return AdmonitionKinds(i + 1)
+		}
+	}
+	return AdmonitionNone
+}
+
 // CitationTypes holds the type of a citation, informative, normative or suppressed
 type CitationTypes int
 
@@ -91,6 +156,8 @@ type Container struct {
 	Literal []byte // Text contents of the leaf nodes
 	Content []byte // Markdown content of the block nodes
 
+	Source SourceRange // Where in the source the node was parsed from
+
 	*Attribute // Block level attribute
 }
 
@@ -140,6 +207,8 @@ type Leaf struct {
 	Literal []byte // Text contents of the leaf nodes
 	Content []byte // Markdown content of the block nodes
 
+	Source SourceRange // Where in the source the node was parsed from
+
 	*Attribute // Block level attribute
 }
 
@@ -190,6 +259,14 @@ type DocumentMatter struct {
 	Matter DocumentMatters
 }
 
+// FrontMatter is the metadata block at the start of a document, e.g. in
+// YAML between --- lines. Literal holds it without the delimiters.
+type FrontMatter struct {
+	Leaf
+
+	Format FrontMatterFormats
+}
+
 // BlockQuote represents markdown block quote node
 type BlockQuote struct {
 	Container
@@ -200,6 +277,29 @@ type Aside struct {
 	Container
 }
 
+// Admonition is a GitHub style alert, a block quote that starts with
+// [!NOTE], [!TIP], [!IMPORTANT], [!WARNING] or [!CAUTION]
+type Admonition struct {
+	Container
+
+	Kind  AdmonitionKinds
+	Title []byte // Custom title after the kind, Kind.Title() if empty
+}
+
+// FencedDiv is a container of blocks between ::: fences, like a Pandoc
+// fenced div or a directive. The attribute of the opening fence is the
+// block attribute.
+//
+//	::: warning args {#id .class}
+//	content
+//	:::
+type FencedDiv struct {
+	Container
+
+	Name string // The word after the colons, e.g. "warning"
+	Args string // The rest of the opening fence, without the attribute
+}
+
 // List represents markdown list node
 type List struct {
 	Container
@@ -223,6 +323,8 @@ type ListItem struct {
 	Delimiter       byte   // '.' or ')' after the number in ordered lists
 	RefLink         []byte // If not nil, turns this list item into a footnote item and triggers different rendering
 	IsFootnotesList bool   // This is a list of footnotes
+	IsTask          bool   // Item starts with a [ ] or [x] task marker
+	Checked         bool   // Task is done, i.e. marked with [x]
 }
 
 // Paragraph represents markdown paragraph node
@@ -282,6 +384,27 @@ type Link struct {
 	AdditionalAttributes []string // Defines additional attributes to use during rendering.
 }
 
+// WikiLink is a [[Page]], [[Page|label]] or [[Page#Section]] link. Its
+// children are the label, or the text between the brackets if it has none.
+type WikiLink struct {
+	Container
+
+	Page        []byte // Page is the linked page, empty for [[#Section]]
+	Section     []byte // Section is the heading after #, if any
+	Label       []byte // Label is the text after |, nil if there's none
+	Destination []byte // Destination is what goes into a href
+	Missing     bool   // Missing is true if the linked page doesn't exist
+}
+
+// AsLink returns a Link with the destination and children of the wiki link,
+// for rendering it like any other link.
+func (l *WikiLink) AsLink() *Link {
+	link := &Link{Destination: l.Destination}
+	link.Parent = l.Parent
+	link.Children = l.Children
+	return link
+}
+
 // CrossReference is a reference node.
 type CrossReference struct {
 	Container
@@ -326,10 +449,39 @@ type CodeBlock struct {
 	FenceChar   byte
 	FenceLength int
 	FenceOffset int
+
+	Meta *CodeBlockMeta // metadata from the info string, nil if there is none
+}
+
+// CodeBlockMeta is the metadata of a fenced code block, given in braces
+// after the language in the info string:
+//
+//	```go {linenos=true hl_lines="3-5 8" start=10}
+type CodeBlockMeta struct {
+	LineNumbers bool              // linenos, number the lines
+	Start       int               // start or linenostart, the number of the first line, 0 if not set
+	Highlight   []LineRange       // hl_lines, the lines to highlight
+	Attrs       map[string][]byte // other attributes
+}
+
+// LineRange is a range of lines of a code block, numbered from 1. From and
+// To are inclusive.
+type LineRange struct {
+	From, To int
+}
+
+// IsHighlighted returns true if line, numbered from 1, is in Highlight
+func (m *CodeBlockMeta) IsHighlighted(line int) bool {
+	for _, r := range m.Highlight {
+		if line >= r.From && line <= r.To {
+			return true
+		}
+	}
+	return false
 }
 
 // Softbreak represents markdown softbreak node
-// Note: not used currently
+// It is only produced in CommonMark mode
 type Softbreak struct {
 	Leaf
 }
@@ -427,6 +579,25 @@ type Superscript struct {
 	Leaf
 }
 
+// TOC is a table of contents placeholder, e.g. [TOC]
+type TOC struct {
+	Leaf
+
+	Section  bool // Only the headings in the section of the placeholder
+	MinLevel int  // Lowest heading level, 0 for no limit
+	MaxLevel int  // Highest heading level, 0 for no limit
+}
+
+// Section is a heading and the content that follows it, up to the next
+// heading with the same or a lower level. The heading is the first child.
+// Sections are only in the AST with parser.Sections or after
+// parser.WrapSections.
+type Section struct {
+	Container
+
+	Level int // The level of the heading
+}
+
 // Footnotes is a node that contains all footnotes
 type Footnotes struct {
 	Container
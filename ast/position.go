@@ -0,0 +1,66 @@
+package ast
+
+import "fmt"
+
+// Position is a location in the markdown source.
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in bytes, starting at 1
+}
+
+// IsValid returns true if the position has been set by the parser.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// SourceRange is the part of the source a node was parsed from.
+// End is the position just past the last byte of the node.
+type SourceRange struct {
+	File  string // file the node was included from, "" for the main input
+	Start Position
+	End   Position
+}
+
+// IsValid returns true if the range has been set by the parser.
+func (r SourceRange) IsValid() bool {
+	return r.Start.IsValid()
+}
+
+func (r SourceRange) String() string {
+	s := r.Start.String() + "-" + r.End.String()
+	if r.File != "" {
+		s = r.File + ":" + s
+	}
+	return s
+}
+
+// GetSourceRange returns the source range of node n.
+// It's implemented as stand-alone function to keep Node interface small
+func GetSourceRange(n Node) SourceRange {
+	if c := n.AsContainer(); c != nil {
+		return c.Source
+	}
+	if l := n.AsLeaf(); l != nil {
+		return l.Source
+	}
+	return SourceRange{}
+}
+
+// SetSourceRange sets the source range of node n.
+func SetSourceRange(n Node, r SourceRange) {
+	if c := n.AsContainer(); c != nil {
+		c.Source = r
+		return
+	}
+	if l := n.AsLeaf(); l != nil {
+		l.Source = r
+	}
+}
@@ -127,6 +127,12 @@ func printRecur(w io.Writer, node Node, prefix string, depth int) {
 	case *Link:
 		content := "url=" + string(v.Destination)
 		printDefault(w, indent, typeName, content)
+	case *WikiLink:
+		content := "url=" + string(v.Destination)
+		if v.Missing {
+			content += " missing"
+		}
+		printDefault(w, indent, typeName, content)
 	case *Image:
 		content := "url=" + string(v.Destination)
 		printDefault(w, indent, typeName, content)
@@ -152,6 +158,13 @@ func printRecur(w io.Writer, node Node, prefix string, depth int) {
 		if v.IsFootnotesList {
 			content += "footnotes "
 		}
+		if v.IsTask {
+			if v.Checked {
+				content += "task=[x] "
+			} else {
+				content += "task=[ ] "
+			}
+		}
 		flags := getListFlags(v.ListFlags)
 		if len(flags) > 0 {
 			content += "flags=" + flags + " "
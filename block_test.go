@@ -5,6 +5,7 @@ import (
 	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
+	"strings"
 	"testing"
 )
 
@@ -81,6 +82,11 @@ func TestOrderedList(t *testing.T) {
 	doTestsBlock(t, "OrderedList.tests", 0)
 }
 
+func TestTaskLists(t *testing.T) {
+	doTestsBlock(t, "TaskLists.tests", parser.TaskLists)
+	doTestsBlock(t, "TaskLists.tests", parser.CommonMark|parser.TaskLists)
+}
+
 func TestDefinitionList(t *testing.T) {
 	doTestsBlock(t, "DefinitionList.tests", parser.DefinitionLists)
 }
@@ -163,6 +169,125 @@ func TestTOC(t *testing.T) {
 	})
 }
 
+func TestTOCPlaceholder(t *testing.T) {
+	tests := []string{
+		"# A\n\n[TOC]\n\n## B {#b}\n",
+		"<h1 id=\"toc_0\">A</h1>\n\n<nav class=\"toc\">\n<ul>\n<li><a href=\"#toc_0\">A</a>\n" +
+			"<ul>\n<li><a href=\"#b\">B</a></li>\n</ul>\n</li>\n</ul>\n</nav>\n\n<h2 id=\"b\">B</h2>\n",
+
+		"# A\n\n{{toc section levels=3}}\n\n## B\n\n### C\n\n# D\n\n### E\n",
+		"<h1 id=\"toc_0\">A</h1>\n\n<nav class=\"toc\">\n<ul>\n<li><a href=\"#toc_2\">C</a></li>\n</ul>\n</nav>\n\n" +
+			"<h2 id=\"toc_1\">B</h2>\n\n<h3 id=\"toc_2\">C</h3>\n\n<h1 id=\"toc_3\">D</h1>\n\n<h3 id=\"toc_4\">E</h3>\n",
+
+		// IDs are made unique before the placeholder
+		"[TOC]\n\n# A {#a}\n\n# A {#a}\n",
+		"<nav class=\"toc\">\n<ul>\n<li><a href=\"#a\">A</a></li>\n<li><a href=\"#a-1\">A</a></li>\n</ul>\n</nav>\n\n" +
+			"<h1 id=\"a\">A</h1>\n\n<h1 id=\"a-1\">A</h1>\n",
+
+		"[TOC] text\n\n[toc bad]\n",
+		"<p>[TOC] text</p>\n\n<p>[toc bad]</p>\n",
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.HeadingIDs | parser.TOCPlaceholder})
+}
+
+func TestSections(t *testing.T) {
+	tests := []string{
+		"intro\n\n# A {#a}\n\npara\n\n## B\n\n> quote\n\n# C\n",
+		"<p>intro</p>\n\n<section id=\"a\">\n<h1>A</h1>\n\n<p>para</p>\n\n<section id=\"b\">\n<h2>B</h2>\n\n" +
+			"<blockquote>\n<p>quote</p>\n</blockquote>\n</section>\n</section>\n\n<section id=\"c\">\n<h1>C</h1>\n</section>\n",
+
+		// IDs are made unique on the sections
+		"# A\n\n# A\n",
+		"<section id=\"a\">\n<h1>A</h1>\n</section>\n\n<section id=\"a-1\">\n<h1>A</h1>\n</section>\n",
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.AutoHeadingIDs | parser.HeadingIDs | parser.Sections})
+}
+
+func TestFrontMatter(t *testing.T) {
+	tests := []string{
+		"---\ntitle: x\n---\n# A\n",
+		"<h1>A</h1>\n",
+
+		"+++\ntitle = \"x\"\n+++\n\ntext\n",
+		"<p>text</p>\n",
+
+		"---\nnot closed\n",
+		"<hr>\n\n<p>not closed</p>\n",
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.FrontMatter})
+}
+
+func TestAlerts(t *testing.T) {
+	tests := []string{
+		"> [!NOTE]\n> Useful *info*.\n>\n> More.\n\nafter\n",
+		"<div class=\"markdown-alert markdown-alert-note\">\n<p class=\"markdown-alert-title\">Note</p>\n" +
+			"<p>Useful <em>info</em>.</p>\n\n<p>More.</p>\n</div>\n\n<p>after</p>\n",
+
+		"> [!warning] Be <careful>\n> - x\n",
+		"<div class=\"markdown-alert markdown-alert-warning\">\n<p class=\"markdown-alert-title\">Be &lt;careful&gt;</p>\n" +
+			"<ul>\n<li>x</li>\n</ul>\n</div>\n",
+
+		"> [!TIP]\n",
+		"<div class=\"markdown-alert markdown-alert-tip\">\n<p class=\"markdown-alert-title\">Tip</p>\n</div>\n",
+
+		"> [!OTHER]\n> text\n",
+		"<blockquote>\n<p>[!OTHER]\ntext</p>\n</blockquote>\n",
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.Alerts})
+}
+
+func TestFencedDivs(t *testing.T) {
+	tests := []string{
+		"::: warning\nBe *careful*.\n\n- x\n:::\n\nafter\n",
+		"<div class=\"warning\">\n<p>Be <em>careful</em>.</p>\n\n<ul>\n<li>x</li>\n</ul>\n</div>\n\n<p>after</p>\n",
+
+		":::: note {#n .big}\n::: danger\ninner\n:::\n::::\n",
+		"<div class=\"note big\" id=\"n\">\n<div class=\"danger\">\n<p>inner</p>\n</div>\n</div>\n",
+
+		"::: {#id .sidebar}\ntext\n:::\n",
+		"<div id=\"id\" class=\"sidebar\">\n<p>text</p>\n</div>\n",
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.FencedDivs})
+}
+
+func TestEmoji(t *testing.T) {
+	tests := []string{
+		"Ship it :rocket: :+1:\n",
+		"<p>Ship it \U0001f680 \U0001f44d</p>\n",
+
+		"`:smile:` and <http://example.com/:smile:>\n",
+		"<p><code>:smile:</code> and <a href=\"http://example.com/:smile:\">http://example.com/:smile:</a></p>\n",
+
+		"not:smile: :unknown:\n",
+		"<p>not:smile: :unknown:</p>\n",
+
+		// only GitHub's names
+		":thumbsup: :thumbsup_tone2:\n",
+		"<p>\U0001f44d :thumbsup_tone2:</p>\n",
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.Emoji})
+}
+
+func TestWikiLinks(t *testing.T) {
+	tests := []string{
+		"[[Home Page]] and [[Setup#Install Steps|*installing*]]\n",
+		"<p><a class=\"wikilink\" href=\"Home_Page\">Home Page</a> and <a class=\"wikilink\" href=\"Setup#install-steps\"><em>installing</em></a></p>\n",
+
+		"[[#Notes]] [[Missing]]\n",
+		"<p><a class=\"wikilink\" href=\"#notes\">#Notes</a> <a class=\"wikilink wikilink-missing\" href=\"/wiki/Missing\">Missing</a></p>\n",
+
+		"[[]] [[a\nb]] [not [[wiki]]](/x)\n",
+		"<p>[[]] [[a\nb]] <a href=\"/x\">not [[wiki]]</a></p>\n",
+	}
+	resolver := func(page string) ([]byte, bool) {
+		if page == "Missing" {
+			return []byte("/wiki/" + page), false
+		}
+		return []byte(strings.Replace(page, " ", "_", -1)), true
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.WikiLinks, wikiLinkResolver: resolver})
+}
+
 func TestCompletePage(t *testing.T) {
 	tests := readTestFile2(t, "CompletePage.tests")
 	doTestsParam(t, tests, TestParams{Flags: html.UseXHTML | html.CompletePage})
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// edit is a line of a diff: kept (' '), deleted ('-') or inserted ('+')
+type edit struct {
+	op   byte
+	line string
+}
+
+// splitLines splits s into lines, keeping the newlines
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script turning a into b, with the
+// algorithm of Eugene W. Myers, "An O(ND) Difference Algorithm and Its
+// Variations".
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	// v[max+k] is the furthest x reached on diagonal k. trace[d] holds the
+	// diagonals -d+1 to d-1 of v before step d, which are the ones step d
+	// continues from.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	d := 0
+	for ; d <= max; d++ {
+		var tv []int
+		if d > 0 {
+			tv = append(tv, v[max-d+1:max+d]...)
+		}
+		trace = append(trace, tv)
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	var edits []edit
+	x, y := n, m
+	for ; d >= 0; d-- {
+		tv := trace[d]
+		at := func(k int) int {
+			return tv[k+d-1]
+		}
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			edits = append(edits, edit{'+', b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{'-', a[x-1]})
+			x--
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// unifiedDiff returns the differences between a and b like diff -u, with
+// three lines of context, or nil if there are none
+func unifiedDiff(filename string, a, b []byte) []byte {
+	const context = 3
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+	// the line numbers in a and b before each edit
+	aLine := make([]int, len(edits)+1)
+	bLine := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if e.op != '+' {
+			aLine[i+1]++
+		}
+		if e.op != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s.orig\n+++ %s\n", filename, filename)
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// hunks with up to twice the context between them are joined
+		end := i
+		for j := i + 1; j < len(edits) && j <= end+2*context+1; j++ {
+			if edits[j].op != ' ' {
+				end = j
+			}
+		}
+		stop := end + context + 1
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(aLine[start], aLine[stop]), hunkRange(bLine[start], bLine[stop]))
+		for _, e := range edits[start:stop] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// hunkRange returns the lines from start to end, exclusive and counted
+// from 0, as written in a hunk header
+func hunkRange(start, end int) string {
+	switch end - start {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/md"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// mdfmt formats markdown files in a canonical style, like gofmt does for
+// go files: * bullets, renumbered ordered lists, # headings, * and **
+// emphasis, ``` code fences and padded tables.
+// Usage: mdfmt [-w] [-l] [-d] [-mmark] [path ...]
+// Without paths it formats standard input. Directories are walked for
+// .md and .markdown files.
+
+var (
+	flgWrite bool
+	flgList  bool
+	flgDiff  bool
+	flgMmark bool
+
+	exitCode = 0
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: mdfmt [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+func report(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	exitCode = 2
+}
+
+func isMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// extensions returns the parser extensions. Everything the md renderer
+// writes back is enabled, so formatting doesn't change what a file means,
+// e.g. front matter isn't taken for a rule and a heading.
+func extensions() parser.Extensions {
+	exts := parser.CommonExtensions | parser.Attributes | parser.OrderedListStart |
+		parser.SuperSubscript | parser.Footnotes | parser.TaskLists |
+		parser.FrontMatter | parser.Alerts | parser.FencedDivs | parser.WikiLinks
+	if flgMmark {
+		exts |= parser.Mmark | parser.Titleblock
+	}
+	return exts
+}
+
+// format returns src in the canonical style.
+func format(src []byte) []byte {
+	p := parser.NewWithExtensions(extensions())
+	doc := markdown.Parse(markdown.NormalizeNewlines(src), p)
+	renderer := md.NewRenderer(md.WithNormalizedStyle(true))
+	res := markdown.Render(doc, renderer)
+	res = bytes.TrimLeft(res, "\n")
+	if len(res) == 0 {
+		return res
+	}
+	return append(bytes.TrimRight(res, "\n"), '\n')
+}
+
+func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error {
+	if in == nil {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	res := format(src)
+	if bytes.Equal(src, res) {
+		if !flgList && !flgWrite && !flgDiff {
+			_, err = out.Write(res)
+		}
+		return err
+	}
+
+	if flgList {
+		fmt.Fprintln(out, filename)
+	}
+	if flgWrite {
+		if stdin {
+			return fmt.Errorf("can't use -w on standard input")
+		}
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filename, res, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if flgDiff {
+		fmt.Fprintf(out, "diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
+		out.Write(unifiedDiff(filename, src, res))
+	}
+	if !flgList && !flgWrite && !flgDiff {
+		_, err = out.Write(res)
+	}
+	return err
+}
+
+func walkDir(path string) {
+	filepath.Walk(path, func(path string, f os.FileInfo, err error) error {
+		if err == nil && !f.IsDir() && isMarkdownFile(path) {
+			err = processFile(path, nil, os.Stdout, false)
+		}
+		if err != nil {
+			report(err)
+		}
+		return nil
+	})
+}
+
+func main() {
+	flag.BoolVar(&flgWrite, "w", false, "write result to (source) file instead of stdout")
+	flag.BoolVar(&flgList, "l", false, "list files whose formatting differs from mdfmt's")
+	flag.BoolVar(&flgDiff, "d", false, "display diffs instead of rewriting files")
+	flag.BoolVar(&flgMmark, "mmark", false, "parse mmark syntax")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if flgWrite {
+			report(fmt.Errorf("can't use -w on standard input"))
+		} else if err := processFile("<standard input>", os.Stdin, os.Stdout, true); err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
+	}
+
+	for _, path := range flag.Args() {
+		switch dir, err := os.Stat(path); {
+		case err != nil:
+			report(err)
+		case dir.IsDir():
+			walkDir(path)
+		default:
+			if err := processFile(path, nil, os.Stdout, false); err != nil {
+				report(err)
+			}
+		}
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// setFlags sets the command line flags for a test and returns a function
+// that resets them
+func setFlags(write, list, diff bool) func() {
+	flgWrite, flgList, flgDiff = write, list, diff
+	return func() {
+		flgWrite, flgList, flgDiff = false, false, false
+	}
+}
+
+// testFiles returns the markdown files formatted by the tests
+func testFiles(t *testing.T) []string {
+	paths := []string{filepath.Join("..", "..", "README.md")}
+	for _, pattern := range []string{
+		filepath.Join("..", "..", "testdata", "*.text"),
+		filepath.Join("..", "..", "testdata", "*.md"),
+		filepath.Join("..", "..", "testdata", "*.test"),
+		filepath.Join("..", "..", "testdata", "*.tests"),
+		filepath.Join("testdata", "*.md"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// toHTML returns src as HTML, parsed like format parses it. Smartypants is
+// off, as it leaves escaped punctuation alone, and an empty title is the
+// same as none, but only reference images keep it.
+func toHTML(src []byte) []byte {
+	p := parser.NewWithExtensions(extensions())
+	doc := markdown.Parse(markdown.NormalizeNewlines(src), p)
+	flags := html.CommonFlags &^ (html.Smartypants | html.SmartypantsFractions | html.SmartypantsDashes | html.SmartypantsLatexDashes)
+	out := markdown.Render(doc, html.NewRenderer(html.RendererOptions{Flags: flags}))
+	return bytes.Replace(out, []byte(` title=""`), nil, -1)
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	paths := testFiles(t)
+	defer func() { flgMmark = false }()
+	for _, mmark := range []bool{false, true} {
+		flgMmark = mmark
+		for _, path := range paths {
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			once := format(src)
+			twice := format(once)
+			if !bytes.Equal(once, twice) {
+				t.Errorf("%s (mmark: %v): formatting again changed it:\n%s", path, mmark, unifiedDiff(path, once, twice))
+			}
+		}
+	}
+}
+
+// TestFormatRoundTrip checks that formatting doesn't change what a file
+// means, its HTML
+func TestFormatRoundTrip(t *testing.T) {
+	for _, path := range testFiles(t) {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := toHTML(src)
+		got := toHTML(format(src))
+		if !bytes.Equal(exp, got) {
+			t.Errorf("%s: formatting changed the HTML:\n%s", path, unifiedDiff(path, exp, got))
+		}
+	}
+}
+
+const (
+	unformatted = "Title\n=====\n\n- a\n- b\n"
+	formatted   = "# Title\n\n* a\n* b\n"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcessFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeFile(t, dir, "a.md", unformatted)
+	clean := writeFile(t, dir, "b.md", formatted)
+
+	tests := []struct {
+		write, list, diff bool
+		path              string
+		expected          string
+	}{
+		{path: path, expected: formatted},
+		{path: clean, expected: formatted},
+		{list: true, path: path, expected: path + "\n"},
+		{list: true, path: clean, expected: ""},
+		{diff: true, path: clean, expected: ""},
+		{diff: true, path: path, expected: "diff -u " + filepath.ToSlash(path) + ".orig " + filepath.ToSlash(path) + "\n" +
+			"--- " + path + ".orig\n+++ " + path + "\n@@ -1,5 +1,4 @@\n-Title\n-=====\n+# Title\n \n-- a\n-- b\n+* a\n+* b\n"},
+	}
+	for _, test := range tests {
+		reset := setFlags(test.write, test.list, test.diff)
+		var out bytes.Buffer
+		err := processFile(test.path, nil, &out, false)
+		reset()
+		if err != nil {
+			t.Errorf("processFile(%s) failed: %s", test.path, err)
+		}
+		if got := out.String(); got != test.expected {
+			t.Errorf("processFile(%s) with -w=%v -l=%v -d=%v:\nExpected:\n%s\nGot:\n%s",
+				test.path, test.write, test.list, test.diff, test.expected, got)
+		}
+	}
+}
+
+func TestProcessFileWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeFile(t, dir, "a.md", unformatted)
+
+	defer setFlags(true, false, false)()
+	var out bytes.Buffer
+	if err := processFile(path, nil, &out, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("-w wrote %q to the output", out.String())
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != formatted {
+		t.Errorf("-w wrote %q, expected %q", got, formatted)
+	}
+
+	err = processFile("<standard input>", bytes.NewBufferString(unformatted), &out, true)
+	if err == nil {
+		t.Errorf("-w on standard input didn't fail")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn"
+	expected := "--- f.orig\n+++ f\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -11,3 +11,4 @@\n k\n l\n m\n+n\n\\ No newline at end of file\n"
+	if got := string(unifiedDiff("f", []byte(a), []byte(b))); got != expected {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+	if d := unifiedDiff("f", []byte(a), []byte(a)); d != nil {
+		t.Errorf("diff of equal files is %q, expected nil", d)
+	}
+	if got := string(unifiedDiff("f", nil, []byte("x\n"))); got != "--- f.orig\n+++ f\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("diff of a new file is %q", got)
+	}
+}
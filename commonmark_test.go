@@ -0,0 +1,76 @@
+package markdown
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// commonMarkExample is an example from the CommonMark spec, as found in
+// spec.json that is generated from spec.txt.
+type commonMarkExample struct {
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+	Example  int    `json:"example"`
+	Section  string `json:"section"`
+}
+
+// examples of the spec that we fail because the AST or the HTML renderer
+// can't represent the expected output
+var commonMarkSkip = map[int]string{
+	267: "ast.List can't represent an ordered list starting at 0",
+}
+
+var (
+	reSpaceAroundTags = regexp.MustCompile(`\s*\n\s*(</?[a-z][a-z0-9]*|$)`)
+	reSpaceAfterTags  = regexp.MustCompile(`(<[a-z][a-z0-9]*[^>]*>|</[a-z0-9]+>)\s*\n\s*`)
+)
+
+// normalizeHTML removes differences in whitespace around tags that don't
+// change the meaning of html.
+func normalizeHTML(s string) string {
+	s = strings.Replace(s, "<br>", "<br />", -1)
+	s = strings.Replace(s, "<hr>", "<hr />", -1)
+	s = reSpaceAroundTags.ReplaceAllString(s, "$1")
+	s = reSpaceAfterTags.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(s)
+}
+
+func renderCommonMark(input string) string {
+	p := parser.NewCommonMark()
+	r := html.NewRenderer(html.RendererOptions{Flags: html.UseXHTML})
+	return string(ToHTML([]byte(input), p, r))
+}
+
+func TestCommonMarkSpec(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/commonmark/spec-0.31.2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var examples []commonMarkExample
+	if err := json.Unmarshal(data, &examples); err != nil {
+		t.Fatal(err)
+	}
+	passed := 0
+	for _, ex := range examples {
+		got := renderCommonMark(ex.Markdown)
+		ok := normalizeHTML(got) == normalizeHTML(ex.HTML)
+		if ok {
+			passed++
+		}
+		reason, skip := commonMarkSkip[ex.Example]
+		switch {
+		case ok && skip:
+			t.Errorf("example %d (%s) passes, remove it from commonMarkSkip", ex.Example, reason)
+		case !ok && !skip:
+			t.Errorf("example %d (%s)\nInput:\n%s\nExpected:\n%s\nGot:\n%s",
+				ex.Example, ex.Section, ex.Markdown, ex.HTML, got)
+		}
+	}
+	t.Logf("%d of %d examples passed", passed, len(examples))
+}
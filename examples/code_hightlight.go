@@ -7,8 +7,8 @@ package main
 // requires chroma: go get github.com/alecthomas/chroma/v2
 
 import (
+	"bytes"
 	"fmt"
-	"io"
 
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/ast"
@@ -26,7 +26,8 @@ var (
 )
 
 func init() {
-	htmlFormatter = html.New(html.WithClasses(true), html.TabWidth(2))
+	// PreventSurroundingPre: the markdown renderer writes <pre><code>
+	htmlFormatter = html.New(html.WithClasses(true), html.TabWidth(2), html.PreventSurroundingPre(true))
 	if htmlFormatter == nil {
 		panic("couldn't create html formatter")
 	}
@@ -37,11 +38,17 @@ func init() {
 	}
 }
 
+// chromaHighlighter implements mdhtml.Highlighter using chroma
+type chromaHighlighter struct {
+	defaultLang string
+}
+
 // based on https://github.com/alecthomas/chroma/blob/master/quick/quick.go
-func htmlHighlight(w io.Writer, source, lang, defaultLang string) error {
+func (h *chromaHighlighter) Highlight(lang string, code []byte, attr *ast.Attribute, meta *ast.CodeBlockMeta) ([]byte, error) {
 	if lang == "" {
-		lang = defaultLang
+		lang = h.defaultLang
 	}
+	source := string(code)
 	l := lexers.Get(lang)
 	if l == nil {
 		l = lexers.Analyse(source)
@@ -53,30 +60,17 @@ func htmlHighlight(w io.Writer, source, lang, defaultLang string) error {
 
 	it, err := l.Tokenise(nil, source)
 	if err != nil {
-		return err
-	}
-	return htmlFormatter.Format(w, highlightStyle, it)
-}
-
-// an actual rendering of Paragraph is more complicated
-func renderCode(w io.Writer, codeBlock *ast.CodeBlock, entering bool) {
-	defaultLang := ""
-	lang := string(codeBlock.Info)
-	htmlHighlight(w, string(codeBlock.Literal), lang, defaultLang)
-}
-
-func myRenderHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
-	if code, ok := node.(*ast.CodeBlock); ok {
-		renderCode(w, code, entering)
-		return ast.GoToNext, true
+		return nil, err
 	}
-	return ast.GoToNext, false
+	var buf bytes.Buffer
+	err = htmlFormatter.Format(&buf, highlightStyle, it)
+	return buf.Bytes(), err
 }
 
 func newCustomizedRender() *mdhtml.Renderer {
 	opts := mdhtml.RendererOptions{
-		Flags:          mdhtml.CommonFlags,
-		RenderNodeHook: myRenderHook,
+		Flags:       mdhtml.CommonFlags,
+		Highlighter: &chromaHighlighter{},
 	}
 	return mdhtml.NewRenderer(opts)
 }
@@ -14,6 +14,7 @@ import (
 type TestParams struct {
 	extensions        parser.Extensions
 	referenceOverride parser.ReferenceOverrideFunc
+	wikiLinkResolver  parser.WikiLinkResolverFunc
 	html.Flags
 	html.RendererOptions
 }
@@ -25,6 +26,7 @@ func runMarkdown(input string, params TestParams) string {
 	parser := parser.NewWithExtensions(params.extensions)
 	parser.IsSafeURLOverride = isSafeURL
 	parser.ReferenceOverride = params.referenceOverride
+	parser.WikiLinkResolver = params.wikiLinkResolver
 	renderer := html.NewRenderer(params.RendererOptions)
 	renderer.IsSafeURLOverride = isSafeURL
 
@@ -0,0 +1,207 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// Highlighter highlights the code of code blocks, see
+// RendererOptions.Highlighter.
+type Highlighter interface {
+	// Highlight returns code as HTML. lang is the language from the info
+	// string of the code block, "" if there is none. attr are the block
+	// attributes of the code block and meta the metadata from its info
+	// string, nil if there are none.
+	//
+	// The returned HTML is written between the <pre><code> and
+	// </code></pre> tags written by the renderer. If it returns an error,
+	// the code is written without highlighting.
+	Highlight(lang string, code []byte, attr *ast.Attribute, meta *ast.CodeBlockMeta) ([]byte, error)
+}
+
+// LineHighlighter is a Highlighter that doesn't know any language. It
+// escapes the code and wraps each line in a <span class="line">, so lines
+// can be numbered and styled with CSS.
+//
+// Lines listed in the hl_lines block attribute, e.g. {hl_lines="1 3-4"},
+// or in the hl_lines metadata of the code block get the additional class
+// "hl". With the linenos metadata each line starts with its number in a
+// <span class="ln">.
+type LineHighlighter struct {
+	// LineNumbers adds the line number as a data-line attribute to each line.
+	// Numbering starts at 1 or at the linenostart block attribute.
+	LineNumbers bool
+}
+
+// Highlight implements Highlighter
+func (h *LineHighlighter) Highlight(lang string, code []byte, attr *ast.Attribute, meta *ast.CodeBlockMeta) ([]byte, error) {
+	lines := &ast.CodeBlockMeta{}
+	if attr != nil {
+		if v, ok := attr.Attrs["linenostart"]; ok {
+			n, err := strconv.Atoi(string(v))
+			if err != nil {
+				return nil, fmt.Errorf("html: invalid linenostart %q", v)
+			}
+			lines.Start = n
+		}
+		ranges, err := parser.ParseLineRanges(attr.Attrs["hl_lines"])
+		if err != nil {
+			return nil, err
+		}
+		lines.Highlight = ranges
+	}
+	// the metadata after the language wins over block attributes
+	if meta != nil {
+		lines.LineNumbers = meta.LineNumbers
+		if meta.Start != 0 {
+			lines.Start = meta.Start
+		}
+		if len(meta.Highlight) > 0 {
+			lines.Highlight = meta.Highlight
+		}
+	}
+	return highlightLines(code, lines, h.LineNumbers), nil
+}
+
+// highlightLines returns code escaped with each line wrapped in a
+// <span class="line">, numbered and highlighted as given by meta. If
+// dataLine is true, lines get their number in a data-line attribute.
+func highlightLines(code []byte, meta *ast.CodeBlockMeta, dataLine bool) []byte {
+	start := meta.Start
+	if start == 0 {
+		start = 1
+	}
+	var buf bytes.Buffer
+	code = bytes.TrimSuffix(code, []byte("\n"))
+	for i, line := range bytes.Split(code, []byte("\n")) {
+		buf.WriteString(`<span class="line`)
+		if meta.IsHighlighted(i + 1) {
+			buf.WriteString(` hl`)
+		}
+		buf.WriteString(`"`)
+		if dataLine {
+			fmt.Fprintf(&buf, ` data-line="%d"`, start+i)
+		}
+		buf.WriteString(">")
+		if meta.LineNumbers {
+			fmt.Fprintf(&buf, `<span class="ln">%d</span>`, start+i)
+		}
+		EscapeHTML(&buf, line)
+		buf.WriteString("</span>\n")
+	}
+	return buf.Bytes()
+}
+
+// codeLanguage returns the language from the info string of a code block
+func codeLanguage(info []byte) string {
+	endOfLang := bytes.IndexAny(info, "\t {")
+	if endOfLang < 0 {
+		endOfLang = len(info)
+	}
+	return string(info[:endOfLang])
+}
+
+// highlightCode writes the code of codeBlock highlighted with h. It
+// returns false if highlighting failed and nothing was written.
+//
+// Callouts are removed from the code before highlighting and written at
+// the end of their line.
+func (r *Renderer) highlightCode(w io.Writer, codeBlock *ast.CodeBlock, h Highlighter) bool {
+	code := codeBlock.Literal
+	var callouts map[int][][]byte
+	if r.Opts.Comments != nil {
+		code, callouts = r.extractCallouts(code)
+	}
+	lang := codeLanguage(codeBlock.Info)
+	d, err := h.Highlight(lang, code, codeBlock.Attribute, codeBlock.Meta)
+	if err != nil {
+		return false
+	}
+	if len(callouts) == 0 {
+		r.Out(w, d)
+		return true
+	}
+
+	lines := bytes.SplitAfter(d, []byte("\n"))
+	for i, line := range lines {
+		ids := callouts[i]
+		if i == len(lines)-1 {
+			// callouts of lines the highlighter didn't return
+			var rest []int
+			for j := range callouts {
+				if j > i {
+					rest = append(rest, j)
+				}
+			}
+			sort.Ints(rest)
+			for _, j := range rest {
+				ids = append(ids, callouts[j]...)
+			}
+		}
+		if len(ids) == 0 {
+			r.Out(w, line)
+			continue
+		}
+		end := calloutPosition(line)
+		r.Out(w, line[:end])
+		for _, id := range ids {
+			r.Callout(w, &ast.Callout{ID: id})
+		}
+		r.Out(w, line[end:])
+	}
+	return true
+}
+
+// extractCallouts returns code with callouts removed and the IDs of the
+// removed callouts by line.
+func (r *Renderer) extractCallouts(code []byte) ([]byte, map[int][][]byte) {
+	var buf bytes.Buffer
+	callouts := map[int][][]byte{}
+	line := 0
+Parse:
+	for i := 0; i < len(code); i++ {
+		for _, comment := range r.Opts.Comments {
+			if !bytes.HasPrefix(code[i:], comment) {
+				continue
+			}
+			lc := len(comment)
+			if id, consumed := parser.IsCallout(code[i+lc:]); consumed > 0 {
+				callouts[line] = append(callouts[line], id)
+				i += consumed + lc - 1
+				continue Parse
+			}
+		}
+		if code[i] == '\n' {
+			line++
+		}
+		buf.WriteByte(code[i])
+	}
+	if len(callouts) == 0 {
+		return code, nil
+	}
+	return buf.Bytes(), callouts
+}
+
+// calloutPosition returns the position in a line of highlighted HTML where
+// callouts are inserted: before the newline and the closing tags at the
+// end of the line.
+func calloutPosition(line []byte) int {
+	end := len(line)
+	if end > 0 && line[end-1] == '\n' {
+		end--
+	}
+	for end > 0 && line[end-1] == '>' {
+		i := bytes.LastIndexByte(line[:end], '<')
+		if i < 0 || i+1 >= end || line[i+1] != '/' {
+			break
+		}
+		end = i
+	}
+	return end
+}
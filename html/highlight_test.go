@@ -0,0 +1,136 @@
+package html
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+type upperHighlighter struct {
+	lang string
+	meta *ast.CodeBlockMeta
+	err  error
+}
+
+func (h *upperHighlighter) Highlight(lang string, code []byte, attr *ast.Attribute, meta *ast.CodeBlockMeta) ([]byte, error) {
+	h.lang = lang
+	h.meta = meta
+	if h.err != nil {
+		return nil, h.err
+	}
+	return append([]byte("<b>"), append(bytes.ToUpper(code), "</b>"...)...), nil
+}
+
+func renderHighlighted(opts RendererOptions, input string) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Attributes)
+	doc := p.Parse([]byte(input))
+	r := NewRenderer(opts)
+	var buf bytes.Buffer
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	return buf.String()
+}
+
+func TestHighlighter(t *testing.T) {
+	h := &upperHighlighter{}
+	opts := RendererOptions{Highlighter: h, Comments: [][]byte{[]byte("//")}}
+	got := renderHighlighted(opts, "```go\nfoo() //<<1>>\nbar()\n```\n")
+	exp := "<pre><code class=\"language-go\"><b>FOO() <span class=\"callout\">1</span>\nBAR()\n</b></code></pre>\n"
+	if got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+	if h.lang != "go" {
+		t.Errorf("Highlight() got language %q, expected %q", h.lang, "go")
+	}
+
+	// on error the code is escaped
+	h = &upperHighlighter{err: errors.New("no lexer")}
+	opts = RendererOptions{Highlighter: h}
+	got = renderHighlighted(opts, "```\na < b\n```\n")
+	exp = "<pre><code>a &lt; b\n</code></pre>\n"
+	if got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+}
+
+func TestLineHighlighter(t *testing.T) {
+	tests := []struct {
+		h     *LineHighlighter
+		input string
+		exp   string
+	}{
+		{
+			&LineHighlighter{},
+			"```\na < b\nc\n```\n",
+			"<pre><code><span class=\"line\">a &lt; b</span>\n<span class=\"line\">c</span>\n</code></pre>\n",
+		},
+		{
+			&LineHighlighter{LineNumbers: true},
+			"{linenostart=\"9\" hl_lines=\"2-3\"}\n```\na\nb\nc\n```\n",
+			"<pre><code hl_lines=\"2-3\" linenostart=\"9\"><span class=\"line\" data-line=\"9\">a</span>\n" +
+				"<span class=\"line hl\" data-line=\"10\">b</span>\n<span class=\"line hl\" data-line=\"11\">c</span>\n</code></pre>\n",
+		},
+		{
+			// callouts go into the line
+			&LineHighlighter{},
+			"```\na //<<1>>\nb\n```\n",
+			"<pre><code><span class=\"line\">a <span class=\"callout\">1</span></span>\n<span class=\"line\">b</span>\n</code></pre>\n",
+		},
+	}
+	for _, test := range tests {
+		opts := RendererOptions{Highlighter: test.h, Comments: [][]byte{[]byte("//")}}
+		got := renderHighlighted(opts, test.input)
+		if got != test.exp {
+			t.Errorf("\nInput:    %q\nExpected: %q\nGot:      %q", test.input, test.exp, got)
+		}
+	}
+}
+
+func TestCodeBlockMetaLines(t *testing.T) {
+	input := "{.x}\n```go {linenos=true hl_lines=\"2\" linenostart=10}\na\nb //<<1>>\n```\n"
+	opts := RendererOptions{Comments: [][]byte{[]byte("//")}}
+	got := renderHighlighted(opts, input)
+	exp := "<pre><code class=\"language-go x\"><span class=\"line\"><span class=\"ln\">10</span>a</span>\n" +
+		"<span class=\"line hl\"><span class=\"ln\">11</span>b <span class=\"callout\">1</span></span>\n</code></pre>\n"
+	if got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+
+	// start is linenostart and hl_lines can be in brackets
+	got = renderHighlighted(RendererOptions{Highlighter: &LineHighlighter{LineNumbers: true}}, "```go {start=10 hl_lines=[2]}\na\nb\n```\n")
+	exp = "<pre><code class=\"language-go\"><span class=\"line\" data-line=\"10\">a</span>\n" +
+		"<span class=\"line hl\" data-line=\"11\">b</span>\n</code></pre>\n"
+	if got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+
+	// metadata without a language
+	got = renderHighlighted(RendererOptions{}, "``` {linenos=true}\na\n```\n")
+	exp = "<pre><code><span class=\"line\"><span class=\"ln\">1</span>a</span>\n</code></pre>\n"
+	if got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+
+	// a set highlighter gets the metadata
+	got = renderHighlighted(RendererOptions{Highlighter: &LineHighlighter{}}, "```go {linenos=true hl_lines=\"1\"}\na\n```\n")
+	exp = "<pre><code class=\"language-go\"><span class=\"line hl\"><span class=\"ln\">1</span>a</span>\n</code></pre>\n"
+	if got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+	h := &upperHighlighter{}
+	renderHighlighted(RendererOptions{Highlighter: h}, "```go {linenostart=3}\na\n```\n")
+	if h.meta == nil || h.meta.Start != 3 {
+		t.Errorf("Highlight() got meta %+v, expected linenostart 3", h.meta)
+	}
+
+	// without line numbers or highlighted lines the code is written as is
+	got = renderHighlighted(RendererOptions{}, "```go {title=\"a.go\"}\na\n```\n")
+	exp = "<pre><code class=\"language-go\">a\n</code></pre>\n"
+	if got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/parser"
+	"github.com/gomarkdown/markdown/toc"
 )
 
 // Flags control optional behavior of HTML renderer.
@@ -44,6 +45,7 @@ const (
 	SmartypantsQuotesNBSP                     // Enable « French guillemets » (with Smartypants)
 	TOC                                       // Generate a table of contents
 	LazyLoadImages                            // Include loading="lazy" with images
+	Sanitize                                  // Remove unsafe raw HTML, attributes and links, see SanitizePolicy
 
 	CommonFlags Flags = Smartypants | SmartypantsFractions | SmartypantsDashes | SmartypantsLatexDashes
 )
@@ -77,6 +79,11 @@ const (
 // skip rendering this node and will return WalkStatus
 type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
 
+// DirectiveFunc renders an ast.FencedDiv with a given name, e.g. with
+// custom HTML for "::: youtube id". It's called when entering and leaving
+// the node, like RenderNode.
+type DirectiveFunc func(w io.Writer, div *ast.FencedDiv, entering bool) ast.WalkStatus
+
 // RendererOptions is a collection of supplementary parameters tweaking
 // the behavior of various parts of HTML renderer.
 type RendererOptions struct {
@@ -109,10 +116,24 @@ type RendererOptions struct {
 	// rendering of some nodes
 	RenderNodeHook RenderNodeFunc
 
+	// Directives render fenced divs by their name, instead of the default
+	// <div class="name">
+	Directives map[string]DirectiveFunc
+
 	// Comments is a list of comments the renderer should detect when
 	// parsing code blocks and detecting callouts.
 	Comments [][]byte
 
+	// Highlighter, if set, highlights the code of code blocks. The renderer
+	// still writes the <pre> and <code> tags and callouts, see Highlighter.
+	// If not set, lines are numbered and highlighted as given by
+	// ast.CodeBlock.Meta with a LineHighlighter.
+	Highlighter Highlighter
+
+	// SanitizePolicy is the allowlist used with the Sanitize flag. If nil,
+	// DefaultSanitizePolicy() is used.
+	SanitizePolicy *SanitizePolicy
+
 	// Generator is a meta tag that is inserted in the generated HTML so show what rendered it. It should not include the closing tag.
 	// Defaults (note content quote is not closed) to `  <meta name="GENERATOR" content="github.com/gomarkdown/markdown markdown processor for Go`
 	Generator string
@@ -127,7 +148,7 @@ type Renderer struct {
 	closeTag string // how to end singleton tags: either " />" or ">"
 
 	// Track heading IDs to prevent ID collision in a single generation.
-	headingIDs map[string]int
+	headingIDs toc.UniqueIDs
 
 	lastOutputLen int
 
@@ -142,6 +163,10 @@ type Renderer struct {
 	sr *SPRenderer
 
 	documentMatter ast.DocumentMatters // keep track of front/main/back matter.
+
+	tocs   map[*ast.TOC][]*toc.Entry // the tables of contents of placeholders
+	tocIDs map[*ast.Heading]string   // IDs the tables of contents give headings without one
+	tocDoc ast.Node                  // the document tocs were built for
 }
 
 // Escaper defines how to escape HTML special characters
@@ -204,6 +229,9 @@ func NewRenderer(opts RendererOptions) *Renderer {
 	if opts.CitationFormatString == "" {
 		opts.CitationFormatString = `<sup>[%s]</sup>`
 	}
+	if opts.Flags&Sanitize != 0 && opts.SanitizePolicy == nil {
+		opts.SanitizePolicy = DefaultSanitizePolicy()
+	}
 	if opts.Generator == "" {
 		opts.Generator = `  <meta name="GENERATOR" content="github.com/gomarkdown/markdown markdown processor for Go`
 	}
@@ -212,7 +240,7 @@ func NewRenderer(opts RendererOptions) *Renderer {
 		Opts: opts,
 
 		closeTag:   closeTag,
-		headingIDs: make(map[string]int),
+		headingIDs: toc.UniqueIDs{},
 
 		sr: NewSmartypantsRenderer(opts.Flags),
 	}
@@ -328,22 +356,26 @@ func needSkipLink(r *Renderer, dest []byte) bool {
 	if isSafeURL == nil {
 		isSafeURL = parser.IsSafeURL
 	}
+	if flags&Sanitize != 0 && !r.isSafeSanitizedURL(dest) {
+		return true
+	}
 	return flags&Safelink != 0 && !isSafeURL(dest) && !isMailto(dest)
 }
 
 func appendLanguageAttr(attrs []string, info []byte) []string {
-	if len(info) == 0 {
+	lang := codeLanguage(info)
+	if lang == "" {
 		return attrs
 	}
-	endOfLang := bytes.IndexAny(info, "\t ")
-	if endOfLang < 0 {
-		endOfLang = len(info)
-	}
-	s := `class="language-` + string(info[:endOfLang]) + `"`
+	s := `class="language-` + html.EscapeString(lang) + `"`
 	return append(attrs, s)
 }
 
+// OutTag writes an opening tag with attrs, unless tags are disabled
 func (r *Renderer) OutTag(w io.Writer, name string, attrs []string) {
+	if r.DisableTags > 0 {
+		return
+	}
 	s := name
 	if len(attrs) > 0 {
 		s += " " + strings.Join(attrs, " ")
@@ -485,9 +517,14 @@ func (r *Renderer) OutOneOfCr(w io.Writer, outFirst bool, first string, second s
 
 // HTMLSpan writes ast.HTMLSpan node
 func (r *Renderer) HTMLSpan(w io.Writer, span *ast.HTMLSpan) {
-	if r.Opts.Flags&SkipHTML == 0 {
-		r.Out(w, span.Literal)
+	if r.Opts.Flags&SkipHTML != 0 {
+		return
 	}
+	if r.sanitizing() {
+		r.Out(w, r.sanitizeHTML(span.Literal))
+		return
+	}
+	r.Out(w, span.Literal)
 }
 
 func (r *Renderer) linkEnter(w io.Writer, link *ast.Link) {
@@ -521,6 +558,18 @@ func (r *Renderer) linkExit(w io.Writer, link *ast.Link) {
 	}
 }
 
+// WikiLink writes ast.WikiLink node as a link with the "wikilink" class,
+// and "wikilink-missing" if the page doesn't exist
+func (r *Renderer) WikiLink(w io.Writer, wl *ast.WikiLink, entering bool) {
+	link := wl.AsLink()
+	class := `class="wikilink"`
+	if wl.Missing {
+		class = `class="wikilink wikilink-missing"`
+	}
+	link.AdditionalAttributes = []string{class}
+	r.Link(w, link, entering)
+}
+
 // Link writes ast.Link node
 func (r *Renderer) Link(w io.Writer, link *ast.Link, entering bool) {
 	// mark it but don't link it if it is not a safe link: no smartypants
@@ -543,7 +592,10 @@ func (r *Renderer) imageEnter(w io.Writer, image *ast.Image) {
 	}
 	src := image.Destination
 	src = AddAbsPrefixToImage(src, r.Opts.AbsolutePrefix)
-	attrs := BlockAttrs(image)
+	if r.sanitizing() && !r.isSafeSanitizedURL(src) {
+		src = nil
+	}
+	attrs := r.blockAttrs(image)
 	if r.Opts.Flags&LazyLoadImages != 0 {
 		attrs = append(attrs, `loading="lazy"`)
 	}
@@ -582,7 +634,7 @@ func (r *Renderer) paragraphEnter(w io.Writer, para *ast.Paragraph) {
 	prev := ast.GetPrevNode(para)
 	if prev != nil {
 		switch prev.(type) {
-		case *ast.HTMLBlock, *ast.List, *ast.Paragraph, *ast.Heading, *ast.CaptionFigure, *ast.CodeBlock, *ast.BlockQuote, *ast.Aside, *ast.HorizontalRule:
+		case *ast.HTMLBlock, *ast.List, *ast.Paragraph, *ast.Heading, *ast.CaptionFigure, *ast.CodeBlock, *ast.BlockQuote, *ast.Aside, *ast.Admonition, *ast.FencedDiv, *ast.HorizontalRule:
 			r.CR(w)
 		}
 	}
@@ -596,13 +648,21 @@ func (r *Renderer) paragraphEnter(w io.Writer, para *ast.Paragraph) {
 		if isParentAside {
 			r.CR(w)
 		}
+		_, isParentAdmonition := para.Parent.(*ast.Admonition)
+		if isParentAdmonition {
+			r.CR(w)
+		}
+		_, isParentFencedDiv := para.Parent.(*ast.FencedDiv)
+		if isParentFencedDiv {
+			r.CR(w)
+		}
 	}
 
 	ptag := "<p"
 	if r.Opts.ParagraphTag != "" {
 		ptag = "<" + r.Opts.ParagraphTag
 	}
-	tag := TagWithAttributes(ptag, BlockAttrs(para))
+	tag := TagWithAttributes(ptag, r.blockAttrs(para))
 	r.Outs(w, tag)
 }
 
@@ -619,11 +679,19 @@ func (r *Renderer) paragraphExit(w io.Writer, para *ast.Paragraph) {
 
 // Paragraph writes ast.Paragraph node
 func (r *Renderer) Paragraph(w io.Writer, para *ast.Paragraph, entering bool) {
+	item, isFirstInItem := para.Parent.(*ast.ListItem)
+	isFirstInItem = isFirstInItem && ast.GetFirstChild(item) == para
 	if SkipParagraphTags(para) {
+		if entering && isFirstInItem {
+			r.taskCheckbox(w, item)
+		}
 		return
 	}
 	if entering {
 		r.paragraphEnter(w, para)
+		if isFirstInItem {
+			r.taskCheckbox(w, item)
+		}
 	} else {
 		r.paragraphExit(w, para)
 	}
@@ -642,27 +710,16 @@ func (r *Renderer) HTMLBlock(w io.Writer, node *ast.HTMLBlock) {
 		return
 	}
 	r.CR(w)
-	r.Out(w, node.Literal)
+	if r.sanitizing() {
+		r.Out(w, r.sanitizeHTML(node.Literal))
+	} else {
+		r.Out(w, node.Literal)
+	}
 	r.CR(w)
 }
 
 func (r *Renderer) EnsureUniqueHeadingID(id string) string {
-	for count, found := r.headingIDs[id]; found; count, found = r.headingIDs[id] {
-		tmp := fmt.Sprintf("%s-%d", id, count+1)
-
-		if _, tmpFound := r.headingIDs[tmp]; !tmpFound {
-			r.headingIDs[id] = count + 1
-			id = tmp
-		} else {
-			id = id + "-1"
-		}
-	}
-
-	if _, found := r.headingIDs[id]; !found {
-		r.headingIDs[id] = 0
-	}
-
-	return id
+	return r.headingIDs.Ensure(id)
 }
 
 func (r *Renderer) MakeUniqueHeadingID(hdr *ast.Heading) string {
@@ -680,6 +737,21 @@ func (r *Renderer) MakeUniqueHeadingID(hdr *ast.Heading) string {
 	return id
 }
 
+// headingID returns the unique ID of hdr, or the ID the tables of contents
+// of the document link to if it has none. It's "" if there is neither.
+func (r *Renderer) headingID(hdr *ast.Heading) string {
+	// built before MakeUniqueHeadingID changes the IDs of the headings
+	r.ensureTOCs(hdr)
+	if hdr.HeadingID != "" {
+		return r.MakeUniqueHeadingID(hdr)
+	}
+	id, ok := r.tocIDs[hdr]
+	if !ok {
+		return ""
+	}
+	return r.Opts.HeadingIDPrefix + r.EnsureUniqueHeadingID(id) + r.Opts.HeadingIDSuffix
+}
+
 func (r *Renderer) HeadingEnter(w io.Writer, hdr *ast.Heading) {
 	var attrs []string
 	var class string
@@ -697,12 +769,17 @@ func (r *Renderer) HeadingEnter(w io.Writer, hdr *ast.Heading) {
 		attrs = []string{`class="` + class + `"`}
 	}
 
-	if hdr.HeadingID != "" {
-		id := r.MakeUniqueHeadingID(hdr)
-		attrID := `id="` + id + `"`
-		attrs = append(attrs, attrID)
+	// the ID of a heading that starts a section is written on the <section>
+	if !isSectionHeading(hdr) {
+		if id := r.headingID(hdr); id != "" {
+			if r.sanitizing() {
+				id = html.EscapeString(id)
+			}
+			attrID := `id="` + id + `"`
+			attrs = append(attrs, attrID)
+		}
 	}
-	attrs = append(attrs, BlockAttrs(hdr)...)
+	attrs = append(attrs, r.blockAttrs(hdr)...)
 	attrs = coalesceClassAttrs(attrs)
 	r.CR(w)
 	r.OutTag(w, HeadingOpenTagFromLevel(hdr.Level), attrs)
@@ -724,10 +801,79 @@ func (r *Renderer) Heading(w io.Writer, hdr *ast.Heading, entering bool) {
 	}
 }
 
+// Admonition writes ast.Admonition node as a GitHub style alert, a <div>
+// with its title in the first paragraph
+func (r *Renderer) Admonition(w io.Writer, node *ast.Admonition, entering bool) {
+	if !entering {
+		if len(node.Children) == 0 {
+			r.CR(w)
+		}
+		r.Outs(w, "</div>")
+		r.CR(w)
+		return
+	}
+	attrs := []string{`class="markdown-alert markdown-alert-` + node.Kind.String() + `"`}
+	attrs = append(attrs, r.blockAttrs(node)...)
+	attrs = coalesceClassAttrs(attrs)
+	r.CR(w)
+	r.Outs(w, TagWithAttributes("<div", attrs))
+	r.CR(w)
+	r.Outs(w, `<p class="markdown-alert-title">`)
+	title := node.Title
+	if len(title) == 0 {
+		title = []byte(node.Kind.Title())
+	}
+	EscapeHTML(w, title)
+	r.Outs(w, "</p>")
+}
+
+// FencedDiv writes ast.FencedDiv node as a <div> with the name as class
+func (r *Renderer) FencedDiv(w io.Writer, div *ast.FencedDiv, entering bool) {
+	if !entering {
+		r.Outs(w, "</div>")
+		r.CR(w)
+		return
+	}
+	var attrs []string
+	if div.Name != "" {
+		attrs = append(attrs, `class="`+html.EscapeString(div.Name)+`"`)
+	}
+	attrs = append(attrs, r.blockAttrs(div)...)
+	attrs = coalesceClassAttrs(attrs)
+	r.CR(w)
+	r.Outs(w, TagWithAttributes("<div", attrs))
+}
+
+// Section writes ast.Section node as <section> with the ID of its heading
+func (r *Renderer) Section(w io.Writer, section *ast.Section, entering bool) {
+	if !entering {
+		r.Outs(w, "</section>")
+		r.CR(w)
+		return
+	}
+	var attrs []string
+	if hdr, ok := ast.GetFirstChild(section).(*ast.Heading); ok {
+		if id := r.headingID(hdr); id != "" {
+			if r.sanitizing() {
+				id = html.EscapeString(id)
+			}
+			attrs = append(attrs, `id="`+id+`"`)
+		}
+	}
+	attrs = append(attrs, r.blockAttrs(section)...)
+	r.CR(w)
+	r.Outs(w, TagWithAttributes("<section", attrs))
+}
+
+func isSectionHeading(hdr *ast.Heading) bool {
+	section, ok := hdr.Parent.(*ast.Section)
+	return ok && ast.GetFirstChild(section) == hdr
+}
+
 // HorizontalRule writes ast.HorizontalRule node
 func (r *Renderer) HorizontalRule(w io.Writer, node *ast.HorizontalRule) {
 	r.CR(w)
-	r.OutHRTag(w, BlockAttrs(node))
+	r.OutHRTag(w, r.blockAttrs(node))
 	r.CR(w)
 }
 
@@ -760,7 +906,7 @@ func (r *Renderer) listEnter(w io.Writer, nodeData *ast.List) {
 	if nodeData.ListFlags&ast.ListTypeDefinition != 0 {
 		openTag = "<dl"
 	}
-	attrs = append(attrs, BlockAttrs(nodeData)...)
+	attrs = append(attrs, r.blockAttrs(nodeData)...)
 	r.OutTag(w, openTag, attrs)
 	r.CR(w)
 }
@@ -785,7 +931,7 @@ func (r *Renderer) listExit(w io.Writer, list *ast.List) {
 		if ast.GetNextNode(list) != nil {
 			r.CR(w)
 		}
-	case *ast.Document, *ast.BlockQuote, *ast.Aside:
+	case *ast.Document, *ast.BlockQuote, *ast.Aside, *ast.Admonition, *ast.FencedDiv, *ast.Section:
 		r.CR(w)
 	}
 
@@ -821,6 +967,27 @@ func (r *Renderer) listItemEnter(w io.Writer, listItem *ast.ListItem) {
 		openTag = "<dt>"
 	}
 	r.Outs(w, openTag)
+	// the checkbox goes into the first paragraph if there's one
+	if _, isPara := ast.GetFirstChild(listItem).(*ast.Paragraph); !isPara {
+		r.taskCheckbox(w, listItem)
+	}
+}
+
+// taskCheckbox writes a disabled checkbox if item is a task
+func (r *Renderer) taskCheckbox(w io.Writer, item *ast.ListItem) {
+	if !item.IsTask {
+		return
+	}
+	attrs := []string{`type="checkbox"`}
+	if item.Checked {
+		attrs = append(attrs, `checked=""`)
+	}
+	attrs = append(attrs, `disabled=""`)
+	tag := TagWithAttributes("<input", attrs)
+	if r.Opts.Flags&UseXHTML != 0 {
+		tag = tag[:len(tag)-1] + " />"
+	}
+	r.Outs(w, tag+" ")
 }
 
 func (r *Renderer) listItemExit(w io.Writer, listItem *ast.ListItem) {
@@ -889,17 +1056,23 @@ Parse:
 func (r *Renderer) CodeBlock(w io.Writer, codeBlock *ast.CodeBlock) {
 	var attrs []string
 	attrs = appendLanguageAttr(attrs, codeBlock.Info)
-	attrs = append(attrs, BlockAttrs(codeBlock)...)
+	attrs = append(attrs, r.blockAttrs(codeBlock)...)
 	attrs = coalesceClassAttrs(attrs)
 	r.CR(w)
 
 	r.Outs(w, "<pre>")
 	code := TagWithAttributes("<code", attrs)
 	r.Outs(w, code)
-	if r.Opts.Comments != nil {
-		r.EscapeHTMLCallouts(w, codeBlock.Literal)
-	} else {
-		EscapeHTML(w, codeBlock.Literal)
+	h := r.Opts.Highlighter
+	if meta := codeBlock.Meta; h == nil && meta != nil && (meta.LineNumbers || len(meta.Highlight) > 0) {
+		h = &LineHighlighter{}
+	}
+	if h == nil || !r.highlightCode(w, codeBlock, h) {
+		if r.Opts.Comments != nil {
+			r.EscapeHTMLCallouts(w, codeBlock.Literal)
+		} else {
+			EscapeHTML(w, codeBlock.Literal)
+		}
 	}
 	r.Outs(w, "</code>")
 	r.Outs(w, "</pre>")
@@ -1049,13 +1222,22 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.Del:
 		r.OutOneOf(w, entering, "<del>", "</del>")
 	case *ast.BlockQuote:
-		tag := TagWithAttributes("<blockquote", BlockAttrs(node))
+		tag := TagWithAttributes("<blockquote", r.blockAttrs(node))
 		r.OutOneOfCr(w, entering, tag, "</blockquote>")
 	case *ast.Aside:
-		tag := TagWithAttributes("<aside", BlockAttrs(node))
+		tag := TagWithAttributes("<aside", r.blockAttrs(node))
 		r.OutOneOfCr(w, entering, tag, "</aside>")
+	case *ast.Admonition:
+		r.Admonition(w, node, entering)
+	case *ast.FencedDiv:
+		if fn := r.Opts.Directives[node.Name]; fn != nil {
+			return fn(w, node, entering)
+		}
+		r.FencedDiv(w, node, entering)
 	case *ast.Link:
 		r.Link(w, node, entering)
+	case *ast.WikiLink:
+		r.WikiLink(w, node, entering)
 	case *ast.CrossReference:
 		link := &ast.Link{Destination: append([]byte("#"), node.Destination...)}
 		r.Link(w, link, entering)
@@ -1084,6 +1266,8 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 		r.HTMLBlock(w, node)
 	case *ast.Heading:
 		r.Heading(w, node, entering)
+	case *ast.Section:
+		r.Section(w, node, entering)
 	case *ast.HorizontalRule:
 		r.HorizontalRule(w, node)
 	case *ast.List:
@@ -1091,7 +1275,7 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.ListItem:
 		r.ListItem(w, node, entering)
 	case *ast.Table:
-		tag := TagWithAttributes("<table", BlockAttrs(node))
+		tag := TagWithAttributes("<table", r.blockAttrs(node))
 		r.OutOneOfCr(w, entering, tag, "</table>")
 	case *ast.TableCell:
 		r.TableCell(w, node, entering)
@@ -1132,6 +1316,10 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 		r.OutOneOf(w, false, "<sup>", "</sup>")
 	case *ast.Footnotes:
 		// nothing by default; just output the list.
+	case *ast.TOC:
+		r.TOC(w, node)
+	case *ast.FrontMatter:
+		// front matter is metadata, not content
 	default:
 		panic(fmt.Sprintf("Unknown node %T", node))
 	}
@@ -1141,11 +1329,106 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 // RenderHeader writes HTML document preamble and TOC if requested.
 func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
 	r.writeDocumentHeader(w)
+	r.buildTOCs(ast)
 	if r.Opts.Flags&TOC != 0 {
 		r.writeTOC(w, ast)
 	}
 }
 
+// ensureTOCs builds the tables of contents of the document of node, if
+// RenderHeader didn't, e.g. when the document is rendered with RenderNode
+// only.
+func (r *Renderer) ensureTOCs(node ast.Node) {
+	doc := node
+	for doc.GetParent() != nil {
+		doc = doc.GetParent()
+	}
+	if r.tocs == nil || r.tocDoc != doc {
+		r.buildTOCs(doc)
+	}
+}
+
+// buildTOCs builds the tables of contents of the placeholders in doc,
+// before the IDs of the headings are changed when they are rendered.
+// Headings without an ID get one in r.tocIDs, like with the TOC flag.
+func (r *Renderer) buildTOCs(doc ast.Node) {
+	r.tocDoc = doc
+	r.tocs = map[*ast.TOC][]*toc.Entry{}
+	r.tocIDs = nil
+	var placeholders []*ast.TOC
+	var sections []*ast.Heading // the heading before each placeholder
+	var heading *ast.Heading
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Heading:
+			heading = node
+		case *ast.TOC:
+			placeholders = append(placeholders, node)
+			sections = append(sections, heading)
+		}
+		return ast.GoToNext
+	})
+	if len(placeholders) == 0 && r.Opts.Flags&TOC == 0 {
+		return
+	}
+
+	r.tocIDs = map[*ast.Heading]string{}
+	headingCount := 0
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if hdr, ok := node.(*ast.Heading); ok && entering && !hdr.IsTitleblock {
+			if hdr.HeadingID == "" {
+				r.tocIDs[hdr] = fmt.Sprintf("toc_%d", headingCount)
+			}
+			headingCount++
+		}
+		return ast.GoToNext
+	})
+
+	for i, node := range placeholders {
+		opts := toc.Options{
+			MinLevel:       node.MinLevel,
+			MaxLevel:       node.MaxLevel,
+			SkipTitleblock: true,
+			IDPrefix:       r.Opts.HeadingIDPrefix,
+			IDSuffix:       r.Opts.HeadingIDSuffix,
+		}
+		if node.Section && sections[i] != nil {
+			opts.Section = sections[i]
+		}
+		r.tocs[node] = toc.Build(doc, opts)
+	}
+}
+
+// TOC writes ast.TOC node, a table of contents placeholder, as a <nav>
+func (r *Renderer) TOC(w io.Writer, node *ast.TOC) {
+	r.ensureTOCs(node)
+	entries := r.tocs[node]
+	if len(entries) == 0 {
+		return
+	}
+	r.CR(w)
+	r.Outs(w, "<nav class=\"toc\">\n")
+	r.writeTOCEntries(w, entries)
+	r.Outs(w, "</nav>\n")
+}
+
+func (r *Renderer) writeTOCEntries(w io.Writer, entries []*toc.Entry) {
+	r.Outs(w, "<ul>\n")
+	for _, entry := range entries {
+		r.Outs(w, "<li><a href=\"#")
+		EscapeHTML(w, []byte(entry.ID))
+		r.Outs(w, "\">")
+		EscapeHTML(w, []byte(entry.Title))
+		r.Outs(w, "</a>")
+		if len(entry.Children) > 0 {
+			r.Outs(w, "\n")
+			r.writeTOCEntries(w, entry.Children)
+		}
+		r.Outs(w, "</li>\n")
+	}
+	r.Outs(w, "</ul>\n")
+}
+
 // RenderFooter writes HTML document footer.
 func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
 	if r.documentMatter != ast.DocumentMatterNone {
@@ -1213,7 +1496,23 @@ func (r *Renderer) writeTOC(w io.Writer, doc ast.Node) {
 
 	inHeading := false
 	tocLevel := 0
-	headingCount := 0
+
+	// the IDs the headings get when they're rendered, headings without one
+	// get theirs from r.tocIDs, in the order of the headings
+	r.ensureTOCs(doc)
+	var ids []string
+	var addIDs func(entries []*toc.Entry)
+	addIDs = func(entries []*toc.Entry) {
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+			addIDs(entry.Children)
+		}
+	}
+	addIDs(toc.Build(doc, toc.Options{
+		SkipTitleblock: true,
+		IDPrefix:       r.Opts.HeadingIDPrefix,
+		IDSuffix:       r.Opts.HeadingIDSuffix,
+	}))
 
 	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
 		if nodeData, ok := node.(*ast.Heading); ok && !nodeData.IsTitleblock {
@@ -1222,9 +1521,8 @@ func (r *Renderer) writeTOC(w io.Writer, doc ast.Node) {
 				buf.WriteString("</a>")
 				return ast.GoToNext
 			}
-			if nodeData.HeadingID == "" {
-				nodeData.HeadingID = fmt.Sprintf("toc_%d", headingCount)
-			}
+			id := ids[0]
+			ids = ids[1:]
 			if nodeData.Level == tocLevel {
 				buf.WriteString("</li>\n\n<li>")
 			} else if nodeData.Level < tocLevel {
@@ -1240,8 +1538,9 @@ func (r *Renderer) writeTOC(w io.Writer, doc ast.Node) {
 				}
 			}
 
-			fmt.Fprintf(&buf, `<a href="#%s">`, nodeData.HeadingID)
-			headingCount++
+			buf.WriteString(`<a href="#`)
+			EscapeHTML(&buf, []byte(id))
+			buf.WriteString(`">`)
 			return ast.GoToNext
 		}
 
@@ -1348,17 +1647,30 @@ func BlockAttrs(node ast.Node) []string {
 		s = append(s, fmt.Sprintf(`class="%s"`, classes[1:])) // skip space we added.
 	}
 
-	// sort the attributes so it remain stable between runs
+	for _, k := range sortedAttrKeys(attr) {
+		s = append(s, fmt.Sprintf(`%s="%s"`, k, attr.Attrs[k]))
+	}
+
+	return s
+}
+
+// blockAttrs returns BlockAttrs(node), sanitized with the Sanitize flag
+func (r *Renderer) blockAttrs(node ast.Node) []string {
+	if r.sanitizing() {
+		return r.sanitizeBlockAttrs(node)
+	}
+	return BlockAttrs(node)
+}
+
+// sortedAttrKeys returns the keys of attr.Attrs sorted, so the attributes
+// remain stable between runs
+func sortedAttrKeys(attr *ast.Attribute) []string {
 	var keys = []string{}
 	for k := range attr.Attrs {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
-	for _, k := range keys {
-		s = append(s, fmt.Sprintf(`%s="%s"`, k, attr.Attrs[k]))
-	}
-
-	return s
+	return keys
 }
 
 // coalesceClassAttrs merges multiple class="..." attributes into a single one.
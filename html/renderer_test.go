@@ -0,0 +1,73 @@
+package html
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestDirectives(t *testing.T) {
+	opts := RendererOptions{Directives: map[string]DirectiveFunc{
+		"youtube": func(w io.Writer, div *ast.FencedDiv, entering bool) ast.WalkStatus {
+			if entering {
+				io.WriteString(w, `<iframe src="https://www.youtube.com/embed/`+div.Args+`"></iframe>`+"\n")
+			}
+			return ast.SkipChildren
+		},
+	}}
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.FencedDivs)
+	doc := p.Parse([]byte("::: youtube abc\nignored\n:::\n\n::: note\ntext\n:::\n"))
+	r := NewRenderer(opts)
+	var buf bytes.Buffer
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	exp := "<iframe src=\"https://www.youtube.com/embed/abc\"></iframe>\n<div class=\"note\">\n<p>text</p>\n</div>\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+}
+
+func TestTOCWithRenderNode(t *testing.T) {
+	p := parser.NewWithExtensions(parser.HeadingIDs | parser.TOCPlaceholder)
+	doc := p.Parse([]byte("# A\n\n[TOC]\n\n## B {#b}\n"))
+	r := NewRenderer(RendererOptions{})
+	var buf bytes.Buffer
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	exp := "<h1 id=\"toc_0\">A</h1>\n\n<nav class=\"toc\">\n<ul>\n<li><a href=\"#toc_0\">A</a>\n" +
+		"<ul>\n<li><a href=\"#b\">B</a></li>\n</ul>\n</li>\n</ul>\n</nav>\n\n<h2 id=\"b\">B</h2>\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+	// the headings without an ID keep having none
+	if hdr := doc.GetChildren()[0].(*ast.Heading); hdr.HeadingID != "" {
+		t.Errorf("rendering set the heading ID to %q", hdr.HeadingID)
+	}
+}
+
+func TestTOCFlag(t *testing.T) {
+	p := parser.NewWithExtensions(parser.HeadingIDs)
+	doc := p.Parse([]byte("# A\n\n## B {#b}\n\n# A {#b}\n"))
+	r := NewRenderer(RendererOptions{Flags: TOC, HeadingIDPrefix: "p-"})
+	var buf bytes.Buffer
+	r.RenderHeader(&buf, doc)
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	r.RenderFooter(&buf, doc)
+	exp := "<nav>\n\n<ul>\n<li><a href=\"#p-toc_0\">A</a>\n<ul>\n<li><a href=\"#p-b\">B</a></li>\n</ul></li>\n\n" +
+		"<li><a href=\"#p-b-1\">A</a></li>\n</ul>\n\n</nav>\n\n" +
+		"<h1 id=\"p-toc_0\">A</h1>\n\n<h2 id=\"p-b\">B</h2>\n\n<h1 id=\"p-b-1\">A</h1>\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("\nExpected: %q\nGot:      %q", exp, got)
+	}
+	// the headings without an ID keep having none
+	if hdr := doc.GetChildren()[0].(*ast.Heading); hdr.HeadingID != "" {
+		t.Errorf("rendering set the heading ID to %q", hdr.HeadingID)
+	}
+}
@@ -0,0 +1,262 @@
+package html
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// SanitizePolicy is an allowlist of HTML elements and attributes. With the
+// Sanitize flag it's applied to raw HTML in the document and to the block
+// attributes of the Attributes extension.
+//
+// Event handler attributes (onclick etc.) are never allowed.
+type SanitizePolicy struct {
+	// Elements maps the (lower case) names of allowed elements to the
+	// names of attributes allowed on them.
+	Elements map[string][]string
+	// GlobalAttributes are allowed on all allowed elements.
+	GlobalAttributes []string
+	// URLAttributes have a URL as value. They are dropped if the URL is
+	// not safe, see Renderer.IsSafeURLOverride.
+	URLAttributes []string
+}
+
+// DefaultSanitizePolicy returns the policy used with the Sanitize flag if
+// RendererOptions.SanitizePolicy is not set. It allows the elements
+// markdown generates and common formatting elements.
+func DefaultSanitizePolicy() *SanitizePolicy {
+	cells := []string{"align", "colspan", "rowspan"}
+	return &SanitizePolicy{
+		Elements: map[string][]string{
+			"a": {"href", "name"}, "abbr": nil, "aside": nil, "b": nil,
+			"blockquote": {"cite"}, "br": nil, "caption": nil, "cite": nil,
+			"code": nil, "dd": nil, "del": {"cite"}, "details": {"open"},
+			"dfn": nil, "div": nil, "dl": nil, "dt": nil, "em": nil,
+			"figcaption": nil, "figure": nil, "h1": nil, "h2": nil, "h3": nil,
+			"h4": nil, "h5": nil, "h6": nil, "hr": nil, "i": nil,
+			"img": {"src", "alt", "width", "height", "loading"},
+			"ins": {"cite"}, "kbd": nil, "li": {"value"}, "mark": nil,
+			"ol": {"start", "type", "reversed"}, "p": nil, "pre": nil,
+			"q": {"cite"}, "s": nil, "samp": nil, "section": nil,
+			"small": nil, "span": nil, "strike": nil, "strong": nil,
+			"sub": nil, "summary": nil, "sup": nil, "table": nil,
+			"tbody": nil, "td": cells, "tfoot": nil, "th": append(cells, "scope"),
+			"thead": nil, "tr": nil, "tt": nil, "u": nil, "ul": nil, "var": nil,
+		},
+		GlobalAttributes: []string{"id", "class", "title", "lang", "dir"},
+		URLAttributes:    []string{"href", "src", "cite", "action", "formaction", "poster", "background", "longdesc"},
+	}
+}
+
+// elements whose content is dropped with them
+var sanitizeDropContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true,
+	"embed": true, "textarea": true, "title": true, "noscript": true,
+	"xmp": true, "noembed": true, "noframes": true, "template": true,
+	"svg": true, "math": true,
+}
+
+var (
+	sanitizeTagNameRe = regexp.MustCompile(`^</?(` + tagName + `)`)
+	sanitizeAttrRe    = regexp.MustCompile(`\s+(` + attributeName + `)(?:\s*=\s*(` + attributeValue + `))?`)
+	sanitizeAttrKeyRe = regexp.MustCompile(`^` + attributeName + `$`)
+)
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SanitizePolicy) allowsAttr(tag, attr string) bool {
+	if strings.HasPrefix(attr, "on") {
+		return false
+	}
+	if containsString(p.GlobalAttributes, attr) {
+		return true
+	}
+	return containsString(p.Elements[tag], attr)
+}
+
+// allowsBlockAttr returns true if attr is allowed on some element.
+func (p *SanitizePolicy) allowsBlockAttr(attr string) bool {
+	if strings.HasPrefix(attr, "on") || !sanitizeAttrKeyRe.MatchString(attr) {
+		return false
+	}
+	if containsString(p.GlobalAttributes, attr) {
+		return true
+	}
+	for _, attrs := range p.Elements {
+		if containsString(attrs, attr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Renderer) sanitizing() bool {
+	return r.Opts.Flags&Sanitize != 0
+}
+
+// isSafeSanitizedURL returns true if url, the value of an attribute or a
+// link destination, is allowed when sanitizing. URLs without a scheme are
+// relative and safe, others are checked with IsSafeURLOverride or
+// parser.IsSafeURL.
+func (r *Renderer) isSafeSanitizedURL(url []byte) bool {
+	// browsers ignore entities, whitespace and control characters in schemes
+	u := []byte(html.UnescapeString(string(url)))
+	u = bytes.Map(func(c rune) rune {
+		if c <= ' ' || c == 0x7f {
+			return -1
+		}
+		return c
+	}, u)
+	if i := bytes.IndexAny(u, ":/?#"); i < 0 || u[i] != ':' {
+		return true
+	}
+	isSafeURL := r.IsSafeURLOverride
+	if isSafeURL == nil {
+		isSafeURL = parser.IsSafeURL
+	}
+	return isSafeURL(u)
+}
+
+// sanitizeHTML returns raw HTML with the elements and attributes not
+// allowed by the policy removed. Comments, declarations and processing
+// instructions are removed too.
+func (r *Renderer) sanitizeHTML(d []byte) []byte {
+	policy := r.Opts.SanitizePolicy
+	var buf bytes.Buffer
+	for len(d) > 0 {
+		i := bytes.IndexByte(d, '<')
+		if i < 0 {
+			buf.Write(d)
+			break
+		}
+		buf.Write(d[:i])
+		d = d[i:]
+		tag := htmlTagRe.Find(d)
+		if tag == nil {
+			buf.WriteString("&lt;")
+			d = d[1:]
+			continue
+		}
+		d = d[len(tag):]
+		m := sanitizeTagNameRe.FindSubmatch(tag)
+		if m == nil {
+			// comment, declaration, CDATA or processing instruction
+			continue
+		}
+		name := strings.ToLower(string(m[1]))
+		closing := tag[1] == '/'
+		if sanitizeDropContent[name] {
+			if !closing && !bytes.HasSuffix(tag, []byte("/>")) {
+				d = skipElementContent(d, name)
+			}
+			continue
+		}
+		if _, ok := policy.Elements[name]; !ok {
+			continue
+		}
+		if closing {
+			buf.WriteString("</" + name + ">")
+			continue
+		}
+		buf.WriteString("<" + name)
+		end := len(tag) - 1
+		if bytes.HasSuffix(tag, []byte("/>")) {
+			end--
+		}
+		for _, attr := range sanitizeAttrRe.FindAllSubmatch(tag[len(m[0]):end], -1) {
+			key := strings.ToLower(string(attr[1]))
+			if !policy.allowsAttr(name, key) {
+				continue
+			}
+			if attr[2] == nil {
+				buf.WriteString(" " + key)
+				continue
+			}
+			val := attr[2]
+			if val[0] == '"' || val[0] == '\'' {
+				val = val[1 : len(val)-1]
+			}
+			if containsString(policy.URLAttributes, key) && !r.isSafeSanitizedURL(val) {
+				continue
+			}
+			buf.WriteString(" " + key + `="`)
+			EscapeHTML(&buf, []byte(html.UnescapeString(string(val))))
+			buf.WriteString(`"`)
+		}
+		if end < len(tag)-1 {
+			buf.WriteString(" /")
+		}
+		buf.WriteString(">")
+	}
+	return buf.Bytes()
+}
+
+// skipElementContent returns d after the closing tag of element name.
+func skipElementContent(d []byte, name string) []byte {
+	closeTag := []byte("</" + name)
+	lower := bytes.ToLower(d)
+	for i := 0; ; {
+		j := bytes.Index(lower[i:], closeTag)
+		if j < 0 {
+			return nil
+		}
+		i += j
+		if tag := htmlTagRe.Find(d[i:]); tag != nil {
+			return d[i+len(tag):]
+		}
+		i += len(closeTag)
+	}
+}
+
+// sanitizeBlockAttrs is BlockAttrs with the attributes not allowed by the
+// policy removed and the values escaped.
+func (r *Renderer) sanitizeBlockAttrs(node ast.Node) []string {
+	var attr *ast.Attribute
+	if c := node.AsContainer(); c != nil && c.Attribute != nil {
+		attr = c.Attribute
+	}
+	if l := node.AsLeaf(); l != nil && l.Attribute != nil {
+		attr = l.Attribute
+	}
+	if attr == nil {
+		return nil
+	}
+	policy := r.Opts.SanitizePolicy
+	escape := func(v []byte) string {
+		var buf bytes.Buffer
+		EscapeHTML(&buf, v)
+		return buf.String()
+	}
+
+	var s []string
+	if attr.ID != nil && policy.allowsBlockAttr(IDTag) {
+		s = append(s, IDTag+`="`+escape(attr.ID)+`"`)
+	}
+	if len(attr.Classes) > 0 && policy.allowsBlockAttr("class") {
+		s = append(s, `class="`+escape(bytes.Join(attr.Classes, []byte(" ")))+`"`)
+	}
+	for _, k := range sortedAttrKeys(attr) {
+		key := strings.ToLower(k)
+		if !policy.allowsBlockAttr(key) {
+			continue
+		}
+		v := attr.Attrs[k]
+		if containsString(policy.URLAttributes, key) && !r.isSafeSanitizedURL(v) {
+			continue
+		}
+		s = append(s, key+`="`+escape(v)+`"`)
+	}
+	return s
+}
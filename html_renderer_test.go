@@ -163,3 +163,39 @@ func TestHardLineBreakDoesNotApplyInsideHTMLBlock(t *testing.T) {
 	}
 	doTestsParam(t, tests, params)
 }
+
+func TestSanitize(t *testing.T) {
+	tests := []string{
+		"<div onclick=\"evil()\" class=\"box\">\n<script>alert(1)</script>text\n</div>\n",
+		"<div class=\"box\">\ntext\n</div>\n",
+
+		"a <b onmouseover=alert(1)>bold</b> <span style=\"x\" title='t'>s</span>\n",
+		"<p>a <b>bold</b> <span title=\"t\">s</span></p>\n",
+
+		"<a href=\"javascript:alert(1)\">x</a> <a href=\"java&#x09;script&colon;alert(1)\">y</a> <a href=\"/ok\" rel=x>z</a>\n",
+		"<p><a>x</a> <a>y</a> <a href=\"/ok\">z</a></p>\n",
+
+		"<iframe src=\"http://evil.com\"></iframe><img src=x onerror=alert(1)><!-- comment -->\n",
+		"<p><img src=\"x\"></p>\n",
+
+		"a < b and <unknown>tag</unknown>\n",
+		"<p>a &lt; b and tag</p>\n",
+
+		"[link](javascript:alert(1)) [ok](relative.html) [ok](http://example.com) ![img](javascript:x)\n",
+		"<p><tt>link</tt> <a href=\"relative.html\">ok</a> <a href=\"http://example.com\">ok</a> <img src=\"\" alt=\"img\" /></p>\n",
+
+		"{#id .cls onclick=\"evil()\" title=\"a<b\" href=\"javascript:x\" cite=\"/ok\"}\nparagraph\n",
+		"<p id=\"id\" class=\"cls\" cite=\"/ok\" title=\"a&lt;b\">paragraph</p>\n",
+
+		"<style>\np { color: red }\n</style>\n\ntext\n",
+		"<p>text</p>\n",
+
+		"```go\"onmouseover=\"alert(1)\ncode\n```\n",
+		"<pre><code class=\"language-go&#34;onmouseover=&#34;alert(1)\">code\n</code></pre>\n",
+	}
+	params := TestParams{
+		Flags:      html.Sanitize,
+		extensions: parser.CommonExtensions | parser.Attributes,
+	}
+	doTestsParam(t, tests, params)
+}
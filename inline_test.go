@@ -815,6 +815,74 @@ func TestAutoLink(t *testing.T) {
 	doLinkTestsInline(t, tests)
 }
 
+func TestExtendedAutolink(t *testing.T) {
+	var tests = []string{
+		"www.commonmark.org\n",
+		"<p><a href=\"http://www.commonmark.org\">www.commonmark.org</a></p>\n",
+
+		"Visit www.commonmark.org/help for more information.\n",
+		"<p>Visit <a href=\"http://www.commonmark.org/help\">www.commonmark.org/help</a> for more information.</p>\n",
+
+		"Visit www.commonmark.org.\n\nVisit www.commonmark.org/a.b.\n",
+		"<p>Visit <a href=\"http://www.commonmark.org\">www.commonmark.org</a>.</p>\n\n<p>Visit <a href=\"http://www.commonmark.org/a.b\">www.commonmark.org/a.b</a>.</p>\n",
+
+		"www.google.com/search?q=Markup+(business)\n\nwww.google.com/search?q=Markup+(business)))\n\n(www.google.com/search?q=Markup+(business))\n\n(www.google.com/search?q=Markup+(business)\n",
+		"<p><a href=\"http://www.google.com/search?q=Markup+(business)\">www.google.com/search?q=Markup+(business)</a></p>\n\n" +
+			"<p><a href=\"http://www.google.com/search?q=Markup+(business)\">www.google.com/search?q=Markup+(business)</a>))</p>\n\n" +
+			"<p>(<a href=\"http://www.google.com/search?q=Markup+(business)\">www.google.com/search?q=Markup+(business)</a>)</p>\n\n" +
+			"<p>(<a href=\"http://www.google.com/search?q=Markup+(business)\">www.google.com/search?q=Markup+(business)</a></p>\n",
+
+		"www.google.com/search?q=(business))+ok\n",
+		"<p><a href=\"http://www.google.com/search?q=(business))+ok\">www.google.com/search?q=(business))+ok</a></p>\n",
+
+		"www.google.com/search?q=commonmark&hl=en\n\nwww.google.com/search?q=commonmark&hl;\n",
+		"<p><a href=\"http://www.google.com/search?q=commonmark&amp;hl=en\">www.google.com/search?q=commonmark&amp;hl=en</a></p>\n\n" +
+			"<p><a href=\"http://www.google.com/search?q=commonmark\">www.google.com/search?q=commonmark</a>&amp;hl;</p>\n",
+
+		"www.commonmark.org/he<lp\n",
+		"<p><a href=\"http://www.commonmark.org/he\">www.commonmark.org/he</a>&lt;lp</p>\n",
+
+		"http://commonmark.org\n\n(Visit https://encrypted.google.com/search?q=Markup+(business))\n",
+		"<p><a href=\"http://commonmark.org\">http://commonmark.org</a></p>\n\n" +
+			"<p>(Visit <a href=\"https://encrypted.google.com/search?q=Markup+(business)\">https://encrypted.google.com/search?q=Markup+(business)</a>)</p>\n",
+
+		"foo@bar.baz\n",
+		"<p><a href=\"mailto:foo@bar.baz\">foo@bar.baz</a></p>\n",
+
+		"hello@mail+xyz.example isn't valid, but hello+xyz@mail.example is.\n",
+		"<p>hello@mail+xyz.example isn't valid, but <a href=\"mailto:hello+xyz@mail.example\">hello+xyz@mail.example</a> is.</p>\n",
+
+		"a.b-c_d@a.b\n\na.b-c_d@a.b.\n\na.b-c_d@a.b-\n",
+		"<p><a href=\"mailto:a.b-c_d@a.b\">a.b-c_d@a.b</a></p>\n\n" +
+			"<p><a href=\"mailto:a.b-c_d@a.b\">a.b-c_d@a.b</a>.</p>\n\n" +
+			"<p>a.b-c_d@a.b-</p>\n",
+
+		"mailto:foo@bar.baz\n\nxmpp:foo@bar.baz/txt\n",
+		"<p><a href=\"mailto:foo@bar.baz\">mailto:foo@bar.baz</a></p>\n\n" +
+			"<p><a href=\"xmpp:foo@bar.baz/txt\">xmpp:foo@bar.baz/txt</a></p>\n",
+
+		"www.a_b.c_d.com\n",
+		"<p>www.a_b.c_d.com</p>\n",
+
+		"*www.commonmark.org*\n",
+		"<p><em><a href=\"http://www.commonmark.org\">www.commonmark.org</a></em></p>\n",
+
+		// code spans and existing links are left alone
+		"`www.commonmark.org foo@bar.baz`\n",
+		"<p><code>www.commonmark.org foo@bar.baz</code></p>\n",
+
+		"[www.commonmark.org foo@bar.baz](/url)\n",
+		"<p><a href=\"/url\">www.commonmark.org foo@bar.baz</a></p>\n",
+
+		"<a href=\"/url\">see www.commonmark.org or foo@bar.baz</a>\n",
+		"<p><a href=\"/url\">see www.commonmark.org or foo@bar.baz</a></p>\n",
+	}
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.ExtendedAutolink | parser.NoIntraEmphasis,
+		Flags:      html.UseXHTML,
+	})
+}
+
 var footnoteTests = []string{
 	"testing footnotes.[^a]\n\n[^a]: This is the note\n",
 	`<p>testing footnotes.<sup class="footnote-ref" id="fnref:a"><a href="#fn:a">1</a></sup></p>
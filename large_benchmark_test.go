@@ -2,6 +2,7 @@ package markdown
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -69,3 +70,18 @@ func BenchmarkLargeDocumentToHTML(b *testing.B) {
 		largeDocumentResult = ToHTML(data, p, renderer)
 	}
 }
+
+func BenchmarkLargeDocumentRenderTo(b *testing.B) {
+	data := readLargeBenchmarkDocument(b)
+	opts := html.RendererOptions{Flags: html.CommonFlags}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		doc := parser.NewWithExtensions(parser.CommonExtensions).Parse(data)
+		renderer := html.NewRenderer(opts)
+		if err := RenderTo(ioutil.Discard, doc, renderer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+/*
+Package latex implements LaTeX renderer of parsed markdown document.
+
+	import (
+		"github.com/gomarkdown/markdown"
+		"github.com/gomarkdown/markdown/latex"
+	)
+
+	opts := latex.RendererOptions{
+		Flags: latex.CompleteDocument,
+		Title: "Manual",
+	}
+	renderer := latex.NewRenderer(opts)
+	tex := markdown.Render(doc, renderer)
+
+Headings are written as \section, \subsection etc., footnotes as \footnote,
+mmark citations, index items and cross references as \cite, \index and \ref.
+Tables and figures with a caption are written as table and figure
+environments. Raw HTML is dropped.
+
+A complete document (CompleteDocument flag) uses the amsmath, amssymb,
+graphicx, ulem, makeidx and hyperref packages.
+*/
+package latex
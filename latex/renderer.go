@@ -0,0 +1,702 @@
+package latex
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Flags control optional behavior of LaTeX renderer.
+type Flags int
+
+// LaTeX renderer configuration options.
+const (
+	FlagsNone        Flags = 0
+	CompleteDocument Flags = 1 << iota // Generate a complete LaTeX document with preamble
+)
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of LaTeX renderer.
+type RendererOptions struct {
+	// Used if CompleteDocument is set
+	DocumentClass string // Document class, defaults to "article"
+	Title         string // Document title
+	Author        string // Document author
+	Preamble      []byte // Optional data injected in the preamble, before \begin{document}
+
+	Flags Flags // Flags allow customizing this renderer's behavior
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer implements Renderer interface for LaTeX output.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	listDepth  int // nesting of enumerate lists, for the counter names
+	inFootnote bool
+}
+
+// NewRenderer creates and configures a Renderer object, which
+// satisfies the Renderer interface.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.DocumentClass == "" {
+		opts.DocumentClass = "article"
+	}
+	return &Renderer{Opts: opts}
+}
+
+// latexEscapes are the replacements of the characters with a special
+// meaning in LaTeX
+var latexEscapes = [256]string{
+	'\\': `\textbackslash{}`,
+	'{':  `\{`,
+	'}':  `\}`,
+	'$':  `\$`,
+	'&':  `\&`,
+	'#':  `\#`,
+	'%':  `\%`,
+	'_':  `\_`,
+	'^':  `\textasciicircum{}`,
+	'~':  `\textasciitilde{}`,
+	'<':  `\textless{}`,
+	'>':  `\textgreater{}`,
+	'|':  `\textbar{}`,
+}
+
+// Escape writes d to w with LaTeX special characters escaped.
+func Escape(w io.Writer, d []byte) {
+	start := 0
+	for i, c := range d {
+		esc := latexEscapes[c]
+		if esc == "" {
+			continue
+		}
+		w.Write(d[start:i])
+		io.WriteString(w, esc)
+		start = i + 1
+	}
+	w.Write(d[start:])
+}
+
+// EscapeString returns s with LaTeX special characters escaped.
+func EscapeString(s string) string {
+	var buf bytes.Buffer
+	Escape(&buf, []byte(s))
+	return buf.String()
+}
+
+// escapeURL escapes the characters that are special in the URL arguments
+// of \href and \url
+func escapeURL(d []byte) string {
+	var buf bytes.Buffer
+	for _, c := range d {
+		switch c {
+		case '\\', '#', '%', '{', '}', '~', '^', '&', '_', '$':
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// escapeLabel makes s usable as a label, used with \label and \ref
+func escapeLabel(s []byte) string {
+	return strings.Map(func(c rune) rune {
+		switch c {
+		case '\\', '#', '%', '{', '}', '~', '^', '&', '$', ',':
+			return -1
+		}
+		return c
+	}, string(s))
+}
+
+// Out is a helper to write data to writer
+func (r *Renderer) Out(w io.Writer, d []byte) {
+	w.Write(d)
+}
+
+// Outs is a helper to write data to writer
+func (r *Renderer) Outs(w io.Writer, s string) {
+	io.WriteString(w, s)
+}
+
+// OutOneOf writes first or second depending on outFirst
+func (r *Renderer) OutOneOf(w io.Writer, outFirst bool, first string, second string) {
+	if outFirst {
+		r.Outs(w, first)
+	} else {
+		r.Outs(w, second)
+	}
+}
+
+var sectionCommands = []string{`\section`, `\subsection`, `\subsubsection`, `\paragraph`, `\subparagraph`}
+
+// Heading writes ast.Heading node
+func (r *Renderer) Heading(w io.Writer, hdr *ast.Heading, entering bool) {
+	if hdr.IsTitleblock {
+		r.OutOneOf(w, entering, `\title{`, "}\n\\maketitle\n\n")
+		return
+	}
+	if !entering {
+		r.Outs(w, "}\n")
+		if hdr.HeadingID != "" {
+			r.Outs(w, `\label{`+escapeLabel([]byte(hdr.HeadingID))+"}\n")
+		}
+		r.Outs(w, "\n")
+		return
+	}
+	level := hdr.Level
+	if level > len(sectionCommands) {
+		level = len(sectionCommands)
+	}
+	cmd := sectionCommands[level-1]
+	if hdr.IsSpecial {
+		// unnumbered, like an abstract or a preface
+		cmd += "*"
+	}
+	r.Outs(w, cmd+"{")
+}
+
+// List writes ast.List node
+func (r *Renderer) List(w io.Writer, list *ast.List, entering bool) ast.WalkStatus {
+	if list.IsFootnotesList {
+		// footnotes are written where they are referenced
+		return ast.SkipChildren
+	}
+	env := "itemize"
+	switch {
+	case list.ListFlags&ast.ListTypeDefinition != 0:
+		env = "description"
+	case list.ListFlags&ast.ListTypeOrdered != 0:
+		env = "enumerate"
+	}
+	if !entering {
+		if env == "enumerate" {
+			r.listDepth--
+		}
+		r.Outs(w, `\end{`+env+"}\n\n")
+		return ast.GoToNext
+	}
+	r.Outs(w, `\begin{`+env+"}\n")
+	if env == "enumerate" {
+		r.listDepth++
+		if list.Start > 1 && r.listDepth <= 4 {
+			counter := "enum" + strings.Repeat("i", r.listDepth)
+			r.Outs(w, fmt.Sprintf("\\setcounter{%s}{%d}\n", counter, list.Start-1))
+		}
+	}
+	return ast.GoToNext
+}
+
+// ListItem writes ast.ListItem node
+func (r *Renderer) ListItem(w io.Writer, item *ast.ListItem, entering bool) {
+	if item.ListFlags&ast.ListTypeTerm != 0 {
+		// the term of a definition list item
+		r.OutOneOf(w, entering, `\item[`, "] ")
+		return
+	}
+	if !entering {
+		return
+	}
+	if item.ListFlags&ast.ListTypeDefinition != 0 {
+		// the definition follows the term
+		return
+	}
+	switch {
+	case item.IsTask && item.Checked:
+		r.Outs(w, `\item[$\boxtimes$] `)
+	case item.IsTask:
+		r.Outs(w, `\item[$\square$] `)
+	default:
+		r.Outs(w, `\item `)
+	}
+}
+
+// Paragraph writes ast.Paragraph node
+func (r *Renderer) Paragraph(w io.Writer, para *ast.Paragraph, entering bool) {
+	if entering {
+		return
+	}
+	if item, ok := para.Parent.(*ast.ListItem); ok {
+		switch {
+		case item.ListFlags&ast.ListTypeTerm != 0:
+			// the term is in the brackets of \item[]
+		case isTightList(item.Parent):
+			r.Outs(w, "\n")
+		case ast.GetNextNode(para) == nil:
+			r.Outs(w, "\n")
+		default:
+			r.Outs(w, "\n\n")
+		}
+		return
+	}
+	if isTableCell(para.Parent) {
+		return
+	}
+	r.Outs(w, "\n\n")
+}
+
+func isTightList(node ast.Node) bool {
+	list, ok := node.(*ast.List)
+	return ok && list.Tight
+}
+
+func isTableCell(node ast.Node) bool {
+	_, ok := node.(*ast.TableCell)
+	return ok
+}
+
+// Link writes ast.Link node
+func (r *Renderer) Link(w io.Writer, link *ast.Link, entering bool) ast.WalkStatus {
+	if link.NoteID != 0 {
+		if entering {
+			r.footnote(w, link)
+		}
+		return ast.SkipChildren
+	}
+	dest := link.Destination
+	if len(dest) > 0 && dest[0] == '#' && len(link.GetChildren()) == 0 {
+		if entering {
+			r.Outs(w, `\ref{`+escapeLabel(dest[1:])+`}`)
+		}
+		return ast.GoToNext
+	}
+	if len(dest) > 0 && dest[0] == '#' {
+		r.OutOneOf(w, entering, `\hyperref[`+escapeLabel(dest[1:])+`]{`, "}")
+		return ast.GoToNext
+	}
+	if entering && isAutolink(link) {
+		r.Outs(w, `\url{`+escapeURL(dest)+`}`)
+		return ast.SkipChildren
+	}
+	if !entering {
+		if !isAutolink(link) {
+			r.Outs(w, "}")
+		}
+		return ast.GoToNext
+	}
+	r.Outs(w, `\href{`+escapeURL(dest)+`}{`)
+	return ast.GoToNext
+}
+
+// isAutolink returns true if the text of link is its destination
+func isAutolink(link *ast.Link) bool {
+	children := link.GetChildren()
+	if len(children) != 1 {
+		return false
+	}
+	text, ok := children[0].(*ast.Text)
+	if !ok {
+		return false
+	}
+	return bytes.Equal(text.Literal, link.Destination) ||
+		bytes.Equal(append([]byte("mailto:"), text.Literal...), link.Destination)
+}
+
+// footnote writes the footnote referenced by link as a \footnote
+func (r *Renderer) footnote(w io.Writer, link *ast.Link) {
+	if link.Footnote == nil || r.inFootnote {
+		return
+	}
+	r.inFootnote = true
+	d := r.renderChildren(link.Footnote)
+	r.inFootnote = false
+	r.Outs(w, `\footnote{`)
+	r.Out(w, d)
+	r.Outs(w, "}")
+}
+
+// Image writes ast.Image node
+func (r *Renderer) Image(w io.Writer, image *ast.Image, entering bool) ast.WalkStatus {
+	if !entering {
+		return ast.GoToNext
+	}
+	r.Outs(w, `\includegraphics{`+escapeURL(image.Destination)+`}`)
+	// the alt text has no place in LaTeX
+	return ast.SkipChildren
+}
+
+// CodeBlock writes ast.CodeBlock node
+func (r *Renderer) CodeBlock(w io.Writer, codeBlock *ast.CodeBlock) {
+	r.Outs(w, "\\begin{verbatim}\n")
+	r.Out(w, codeBlock.Literal)
+	if !bytes.HasSuffix(codeBlock.Literal, []byte("\n")) {
+		r.Outs(w, "\n")
+	}
+	r.Outs(w, "\\end{verbatim}\n\n")
+}
+
+// Code writes ast.Code node
+func (r *Renderer) Code(w io.Writer, node *ast.Code) {
+	r.Outs(w, `\texttt{`)
+	Escape(w, node.Literal)
+	r.Outs(w, "}")
+}
+
+// Table writes ast.Table node
+func (r *Renderer) Table(w io.Writer, table *ast.Table, entering bool) {
+	if !entering {
+		r.Outs(w, "\\hline\n\\end{tabular}\n")
+		if !isCaptionFigure(table.Parent) {
+			r.Outs(w, "\n")
+		}
+		return
+	}
+	if isCaptionFigure(table.Parent) {
+		r.Outs(w, "\\centering\n")
+	}
+	r.Outs(w, `\begin{tabular}{|`)
+	for _, cell := range firstTableRow(table) {
+		span := cell.ColSpan
+		if span < 1 {
+			span = 1
+		}
+		for i := 0; i < span; i++ {
+			r.Outs(w, alignColumn(cell.Align)+"|")
+		}
+	}
+	r.Outs(w, "}\n\\hline\n")
+}
+
+// firstTableRow returns the cells of the first row of table
+func firstTableRow(table *ast.Table) []*ast.TableCell {
+	var cells []*ast.TableCell
+	ast.WalkFunc(table, func(node ast.Node, entering bool) ast.WalkStatus {
+		if row, ok := node.(*ast.TableRow); ok && entering {
+			for _, c := range row.GetChildren() {
+				if cell, ok := c.(*ast.TableCell); ok {
+					cells = append(cells, cell)
+				}
+			}
+			return ast.Terminate
+		}
+		return ast.GoToNext
+	})
+	return cells
+}
+
+func alignColumn(align ast.CellAlignFlags) string {
+	switch align {
+	case ast.TableAlignmentCenter:
+		return "c"
+	case ast.TableAlignmentRight:
+		return "r"
+	}
+	return "l"
+}
+
+// TableRow writes ast.TableRow node
+func (r *Renderer) TableRow(w io.Writer, row *ast.TableRow, entering bool) {
+	if entering {
+		return
+	}
+	r.Outs(w, ` \\`+"\n")
+	if _, ok := row.Parent.(*ast.TableHeader); ok {
+		r.Outs(w, "\\hline\n")
+	}
+}
+
+// TableCell writes ast.TableCell node
+func (r *Renderer) TableCell(w io.Writer, cell *ast.TableCell, entering bool) {
+	span := cell.ColSpan
+	if !entering {
+		if cell.IsHeader {
+			r.Outs(w, "}")
+		}
+		if span > 1 {
+			r.Outs(w, "}")
+		}
+		return
+	}
+	if ast.GetPrevNode(cell) != nil {
+		r.Outs(w, " & ")
+	}
+	if span > 1 {
+		r.Outs(w, `\multicolumn{`+strconv.Itoa(span)+`}{|`+alignColumn(cell.Align)+`|}{`)
+	}
+	if cell.IsHeader {
+		r.Outs(w, `\textbf{`)
+	}
+}
+
+func isCaptionFigure(node ast.Node) bool {
+	_, ok := node.(*ast.CaptionFigure)
+	return ok
+}
+
+// CaptionFigure writes ast.CaptionFigure node, as a table environment for
+// tables and as a figure environment for everything else.
+func (r *Renderer) CaptionFigure(w io.Writer, figure *ast.CaptionFigure, entering bool) {
+	env := "figure"
+	for _, child := range figure.GetChildren() {
+		if _, ok := child.(*ast.Table); ok {
+			env = "table"
+		}
+	}
+	if entering {
+		r.Outs(w, `\begin{`+env+"}\n")
+		return
+	}
+	if figure.HeadingID != "" {
+		r.Outs(w, `\label{`+escapeLabel([]byte(figure.HeadingID))+"}\n")
+	}
+	r.Outs(w, `\end{`+env+"}\n\n")
+}
+
+// Caption writes ast.Caption node
+func (r *Renderer) Caption(w io.Writer, caption *ast.Caption, entering bool) ast.WalkStatus {
+	if !entering {
+		return ast.GoToNext
+	}
+	r.Outs(w, `\caption{`)
+	r.Out(w, r.renderChildren(caption))
+	r.Outs(w, "}\n")
+	return ast.SkipChildren
+}
+
+// renderChildren returns the children of node rendered, without the
+// surrounding white space
+func (r *Renderer) renderChildren(node ast.Node) []byte {
+	var buf bytes.Buffer
+	for _, child := range node.GetChildren() {
+		ast.WalkFunc(child, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(&buf, node, entering)
+		})
+	}
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+// Citation writes ast.Citation node
+func (r *Renderer) Citation(w io.Writer, node *ast.Citation) {
+	var dests []string
+	flush := func() {
+		if len(dests) > 0 {
+			r.Outs(w, `\cite{`+strings.Join(dests, ",")+`}`)
+			dests = nil
+		}
+	}
+	for i, dest := range node.Destination {
+		if i < len(node.Suffix) && len(node.Suffix[i]) > 0 {
+			// a suffix is only possible with a single key
+			flush()
+			r.Outs(w, `\cite[`)
+			Escape(w, node.Suffix[i])
+			r.Outs(w, `]{`+escapeLabel(dest)+`}`)
+			continue
+		}
+		dests = append(dests, escapeLabel(dest))
+	}
+	flush()
+}
+
+// Index writes ast.Index node
+func (r *Renderer) Index(w io.Writer, node *ast.Index) {
+	r.Outs(w, `\index{`)
+	Escape(w, node.Item)
+	if len(node.Subitem) > 0 {
+		r.Outs(w, "!")
+		Escape(w, node.Subitem)
+	}
+	if node.Primary {
+		r.Outs(w, "|textbf")
+	}
+	r.Outs(w, "}")
+}
+
+// DocumentMatter writes ast.DocumentMatter node. The divisions only exist
+// in book classes, like book and memoir.
+func (r *Renderer) DocumentMatter(w io.Writer, node *ast.DocumentMatter, entering bool) {
+	if !entering {
+		return
+	}
+	switch r.Opts.DocumentClass {
+	case "book", "memoir", "scrbook":
+	default:
+		return
+	}
+	switch node.Matter {
+	case ast.DocumentMatterFront:
+		r.Outs(w, "\\frontmatter\n\n")
+	case ast.DocumentMatterMain:
+		r.Outs(w, "\\mainmatter\n\n")
+	case ast.DocumentMatterBack:
+		r.Outs(w, "\\backmatter\n\n")
+	}
+}
+
+// Admonition writes ast.Admonition node as a quote starting with its title
+func (r *Renderer) Admonition(w io.Writer, node *ast.Admonition, entering bool) {
+	if !entering {
+		r.Outs(w, "\\end{quote}\n\n")
+		return
+	}
+	title := node.Title
+	if len(title) == 0 {
+		title = []byte(node.Kind.Title())
+	}
+	r.Outs(w, "\\begin{quote}\n\\textbf{")
+	Escape(w, title)
+	r.Outs(w, "}\n\n")
+}
+
+// RenderNode renders a markdown node to LaTeX
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Document, *ast.Section, *ast.FencedDiv:
+		// do nothing
+	case *ast.Text:
+		Escape(w, node.Literal)
+	case *ast.Softbreak:
+		r.Outs(w, "\n")
+	case *ast.Hardbreak:
+		r.Outs(w, "\\\\\n")
+	case *ast.NonBlockingSpace:
+		r.Outs(w, "~")
+	case *ast.Emph:
+		r.OutOneOf(w, entering, `\emph{`, "}")
+	case *ast.Strong:
+		r.OutOneOf(w, entering, `\textbf{`, "}")
+	case *ast.Del:
+		r.OutOneOf(w, entering, `\sout{`, "}")
+	case *ast.BlockQuote:
+		r.OutOneOf(w, entering, "\\begin{quote}\n", "\\end{quote}\n\n")
+	case *ast.Aside:
+		r.OutOneOf(w, entering, "\\begin{quote}\\small\n", "\\end{quote}\n\n")
+	case *ast.Admonition:
+		r.Admonition(w, node, entering)
+	case *ast.Link:
+		return r.Link(w, node, entering)
+	case *ast.WikiLink:
+		return r.Link(w, node.AsLink(), entering)
+	case *ast.CrossReference:
+		if entering {
+			r.Outs(w, `\ref{`+escapeLabel(node.Destination)+`}`)
+		}
+		return ast.SkipChildren
+	case *ast.Citation:
+		r.Citation(w, node)
+	case *ast.Image:
+		return r.Image(w, node, entering)
+	case *ast.Code:
+		r.Code(w, node)
+	case *ast.CodeBlock:
+		r.CodeBlock(w, node)
+	case *ast.Caption:
+		return r.Caption(w, node, entering)
+	case *ast.CaptionFigure:
+		r.CaptionFigure(w, node, entering)
+	case *ast.Paragraph:
+		r.Paragraph(w, node, entering)
+	case *ast.HTMLSpan, *ast.HTMLBlock:
+		// raw HTML has no LaTeX equivalent
+	case *ast.Heading:
+		r.Heading(w, node, entering)
+	case *ast.HorizontalRule:
+		r.Outs(w, "\\begin{center}\\rule{0.5\\linewidth}{0.5pt}\\end{center}\n\n")
+	case *ast.List:
+		return r.List(w, node, entering)
+	case *ast.ListItem:
+		r.ListItem(w, node, entering)
+	case *ast.Table:
+		r.Table(w, node, entering)
+	case *ast.TableCell:
+		r.TableCell(w, node, entering)
+	case *ast.TableHeader, *ast.TableBody, *ast.TableFooter:
+		// the rows are written by TableRow
+	case *ast.TableRow:
+		r.TableRow(w, node, entering)
+	case *ast.Math:
+		r.Outs(w, `\(`)
+		r.Out(w, node.Literal)
+		r.Outs(w, `\)`)
+	case *ast.MathBlock:
+		if entering {
+			r.Outs(w, "\\[\n")
+			r.Out(w, bytes.TrimSpace(node.Literal))
+			r.Outs(w, "\n\\]\n\n")
+		}
+	case *ast.DocumentMatter:
+		r.DocumentMatter(w, node, entering)
+	case *ast.Callout:
+		r.Outs(w, "(")
+		r.Out(w, node.ID)
+		r.Outs(w, ")")
+	case *ast.Index:
+		r.Index(w, node)
+	case *ast.Subscript:
+		r.Outs(w, `\textsubscript{`)
+		Escape(w, node.Literal)
+		r.Outs(w, "}")
+	case *ast.Superscript:
+		r.Outs(w, `\textsuperscript{`)
+		Escape(w, node.Literal)
+		r.Outs(w, "}")
+	case *ast.Footnotes:
+		// footnotes are written where they are referenced
+		return ast.SkipChildren
+	case *ast.TOC:
+		r.Outs(w, "\\tableofcontents\n\n")
+	case *ast.FrontMatter:
+		// front matter is metadata, not content
+	default:
+		panic(fmt.Sprintf("Unknown node %T", node))
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader writes the document preamble if CompleteDocument is set
+func (r *Renderer) RenderHeader(w io.Writer, _ ast.Node) {
+	if r.Opts.Flags&CompleteDocument == 0 {
+		return
+	}
+	r.Outs(w, `\documentclass{`+r.Opts.DocumentClass+"}\n")
+	r.Outs(w, "\\usepackage[utf8]{inputenc}\n")
+	r.Outs(w, "\\usepackage[T1]{fontenc}\n")
+	r.Outs(w, "\\usepackage{amsmath}\n")
+	r.Outs(w, "\\usepackage{amssymb}\n")
+	r.Outs(w, "\\usepackage{graphicx}\n")
+	r.Outs(w, "\\usepackage[normalem]{ulem}\n")
+	r.Outs(w, "\\usepackage{makeidx}\n")
+	r.Outs(w, "\\usepackage{hyperref}\n")
+	r.Outs(w, "\\makeindex\n")
+	if r.Opts.Title != "" {
+		r.Outs(w, `\title{`+EscapeString(r.Opts.Title)+"}\n")
+	}
+	if r.Opts.Author != "" {
+		r.Outs(w, `\author{`+EscapeString(r.Opts.Author)+"}\n")
+	}
+	r.Out(w, r.Opts.Preamble)
+	r.Outs(w, "\n\\begin{document}\n\n")
+	if r.Opts.Title != "" {
+		r.Outs(w, "\\maketitle\n\n")
+	}
+}
+
+// RenderFooter ends the document if CompleteDocument is set
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+	if r.Opts.Flags&CompleteDocument == 0 {
+		return
+	}
+	r.Outs(w, "\\end{document}\n")
+}
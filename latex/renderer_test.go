@@ -0,0 +1,105 @@
+package latex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func renderString(input string, opts RendererOptions) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Footnotes | parser.Mmark | parser.SuperSubscript | parser.OrderedListStart |
+		parser.Alerts)
+	doc := p.Parse([]byte(input))
+	return string(markdown.Render(doc, NewRenderer(opts)))
+}
+
+func TestRender(t *testing.T) {
+	tests := []string{
+		"# Title {#intro}\n\n## Sub\n\n###### Deep\n",
+		"\\section{Title}\n\\label{intro}\n\n\\subsection{Sub}\n\n\\subparagraph{Deep}\n\n",
+
+		"100% of $5 & #1 a_b {x} ~ ^ \\ <|>\n",
+		"100\\% of \\$5 \\& \\#1 a\\_b \\{x\\} \\textasciitilde{} \\textasciicircum{} \\textbackslash{} \\textless{}\\textbar{}\\textgreater{}\n\n",
+
+		"*a* **b** ~~c~~ `d_e` H~2~O 2^10^\n",
+		"\\emph{a} \\textbf{b} \\sout{c} \\texttt{d\\_e} H\\textsubscript{2}O 2\\textsuperscript{10}\n\n",
+
+		"[a](http://x.org/a_b#c) <http://x.org> [b](#sec) [](#sec)\n",
+		"\\href{http://x.org/a\\_b\\#c}{a} \\url{http://x.org} \\hyperref[sec]{b} \\ref{sec}\n\n",
+
+		"* a\n* b\n    1. c\n    2. d\n",
+		"\\begin{itemize}\n\\item a\n\\item b\n\\begin{enumerate}\n\\item c\n\\item d\n\\end{enumerate}\n\n\\end{itemize}\n\n",
+
+		"3. c\n4. d\n",
+		"\\begin{enumerate}\n\\setcounter{enumi}{2}\n\\item c\n\\item d\n\\end{enumerate}\n\n",
+
+		"Term\n: Definition\n",
+		"\\begin{description}\n\\item[Term] Definition\n\\end{description}\n\n",
+
+		"Note[^1].\n\n[^1]: A *footnote*.\n",
+		"Note\\footnote{A \\emph{footnote}.}.\n\n",
+
+		"Math $x^2$\n\n$$\nE = mc^2\n$$\n",
+		"Math \\(x^2\\)\n\n\\[\nE = mc^2\n\\]\n\n",
+
+		"```go\nif a { b }\n```\n",
+		"\\begin{verbatim}\nif a { b }\n\\end{verbatim}\n\n",
+
+		"See [@RFC2119; @!RFC8174, p. 5] and (#intro).\n",
+		"See \\cite{RFC2119}\\cite[p. 5]{RFC8174} and \\ref{intro}.\n\n",
+
+		"(!item, sub) (!!primary)\n",
+		"\\index{item!sub} \\index{primary|textbf}\n\n",
+
+		"| Left | Center | Right |\n|:-----|:------:|------:|\n| a    | b      | c     |\n| d    ||  e     |\n",
+		"\\begin{tabular}{|l|c|r|}\n\\hline\n\\textbf{Left} & \\textbf{Center} & \\textbf{Right} \\\\\n\\hline\n" +
+			"a & b & c \\\\\n\\multicolumn{2}{|l|}{d} & e \\\\\n\\hline\n\\end{tabular}\n\n",
+
+		"| a |\n|---|\n| b |\nTable: A *table*\n",
+		"\\begin{table}\n\\centering\n\\begin{tabular}{|l|}\n\\hline\n\\textbf{a} \\\\\n\\hline\nb \\\\\n\\hline\n\\end{tabular}\n" +
+			"\\caption{A \\emph{table}}\n\\end{table}\n\n",
+
+		"```\ncode\n```\nFigure: Some code\n",
+		"\\begin{figure}\n\\begin{verbatim}\ncode\n\\end{verbatim}\n\n\\caption{Some code}\n\\end{figure}\n\n",
+
+		"> quote\n\n<div>html</div>\n",
+		"\\begin{quote}\nquote\n\n\\end{quote}\n\n",
+
+		"> [!TIP] 100% sure\n> Read this.\n",
+		"\\begin{quote}\n\\textbf{100\\% sure}\n\nRead this.\n\n\\end{quote}\n\n",
+	}
+	for i := 0; i < len(tests); i += 2 {
+		got := renderString(tests[i], RendererOptions{})
+		if got != tests[i+1] {
+			t.Errorf("\nInput:    %q\nExpected: %q\nGot:      %q", tests[i], tests[i+1], got)
+		}
+	}
+}
+
+func TestRenderCompleteDocument(t *testing.T) {
+	opts := RendererOptions{
+		Flags:  CompleteDocument,
+		Title:  "A & B",
+		Author: "Me",
+	}
+	got := renderString("text\n", opts)
+	if !strings.HasPrefix(got, "\\documentclass{article}\n") {
+		t.Errorf("complete document doesn't start with \\documentclass:\n%s", got)
+	}
+	for _, s := range []string{"\\title{A \\& B}\n", "\\author{Me}\n", "\\begin{document}\n\n\\maketitle\n\ntext\n\n\\end{document}\n"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("complete document doesn't contain %q:\n%s", s, got)
+		}
+	}
+
+	// document divisions only with book classes
+	input := "{frontmatter}\n\ntext\n"
+	if got := renderString(input, RendererOptions{}); strings.Contains(got, "\\frontmatter") {
+		t.Errorf("article got \\frontmatter:\n%s", got)
+	}
+	if got := renderString(input, RendererOptions{DocumentClass: "book"}); !strings.Contains(got, "\\frontmatter") {
+		t.Errorf("book doesn't have \\frontmatter:\n%s", got)
+	}
+}
@@ -66,6 +66,58 @@ func Render(doc ast.Node, renderer Renderer) []byte {
 	return buf.Bytes()
 }
 
+// RenderTo uses renderer to convert parsed markdown document into a different
+// format and writes it to w as it goes, without holding the whole output in
+// memory.
+//
+// Rendering stops at the first error writing to w, which is returned.
+// Renderers don't need to check for write errors themselves: the writer
+// they are passed remembers the first error and skips all writes after it.
+func RenderTo(w io.Writer, doc ast.Node, renderer Renderer) error {
+	ew := &errWriter{w: w}
+	renderer.RenderHeader(ew, doc)
+	if ew.err != nil {
+		return ew.err
+	}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		status := renderer.RenderNode(ew, node, entering)
+		if ew.err != nil {
+			return ast.Terminate
+		}
+		return status
+	})
+	if ew.err != nil {
+		return ew.err
+	}
+	renderer.RenderFooter(ew, doc)
+	return ew.err
+}
+
+// errWriter remembers the first error writing to w and skips all writes
+// after it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errWriter) Write(d []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(d)
+	w.err = err
+	return n, err
+}
+
+func (w *errWriter) WriteString(s string) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := io.WriteString(w.w, s)
+	w.err = err
+	return n, err
+}
+
 // ToHTML converts markdownDoc to HTML.
 //
 // You can optionally pass a parser and renderer. This allows to customize
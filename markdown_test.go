@@ -2,7 +2,16 @@ package markdown
 
 import (
 	"bytes"
+	"errors"
 	"testing"
+
+	"github.com/gomarkdown/markdown/ansi"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/latex"
+	"github.com/gomarkdown/markdown/md"
+	"github.com/gomarkdown/markdown/pandoc"
+	"github.com/gomarkdown/markdown/text"
+	"github.com/gomarkdown/markdown/xml2rfc"
 )
 
 func TestDocument(t *testing.T) {
@@ -59,3 +68,73 @@ func TestNormalizeNewlines(t *testing.T) {
 
 	}
 }
+
+// failingWriter fails once n bytes were written.
+type failingWriter struct {
+	n      int
+	failed bool
+	after  int // writes after the failure
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(d []byte) (int, error) {
+	if w.failed {
+		w.after++
+		return 0, errWriteFailed
+	}
+	if len(d) > w.n {
+		n := w.n
+		w.n = 0
+		w.failed = true
+		return n, errWriteFailed
+	}
+	w.n -= len(d)
+	return len(d), nil
+}
+
+func TestRenderTo(t *testing.T) {
+	renderers := map[string]func() Renderer{
+		"html": func() Renderer {
+			return html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags | html.CompletePage})
+		},
+		"md":      func() Renderer { return md.NewRenderer() },
+		"text":    func() Renderer { return text.NewRenderer(text.RendererOptions{}) },
+		"ansi":    func() Renderer { return ansi.NewRenderer(ansi.RendererOptions{}) },
+		"latex":   func() Renderer { return latex.NewRenderer(latex.RendererOptions{Flags: latex.CompleteDocument}) },
+		"xml2rfc": func() Renderer { return xml2rfc.NewRenderer(xml2rfc.RendererOptions{}) },
+		"pandoc":  func() Renderer { return pandoc.NewRenderer(pandoc.RendererOptions{}) },
+	}
+	for name, renderer := range renderers {
+		t.Run(name, func(t *testing.T) {
+			testRenderTo(t, renderer)
+		})
+	}
+}
+
+func testRenderTo(t *testing.T, renderer func() Renderer) {
+	input := []byte("# Title\n\nSome *text* and a [link](http://example.com).\n\n* a\n* b\n")
+	exp := Render(Parse(input, nil), renderer())
+
+	var buf bytes.Buffer
+	if err := RenderTo(&buf, Parse(input, nil), renderer()); err != nil {
+		t.Fatalf("RenderTo() failed with %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), exp) {
+		t.Errorf("RenderTo() wrote:\n%s\nexpected:\n%s", buf.Bytes(), exp)
+	}
+
+	for _, n := range []int{0, 10, len(exp) / 2, len(exp) - 1} {
+		w := &failingWriter{n: n}
+		err := RenderTo(w, Parse(input, nil), renderer())
+		if err != errWriteFailed {
+			t.Errorf("RenderTo() failing after %d bytes returned %v, expected %v", n, err, errWriteFailed)
+		}
+		if w.n != 0 {
+			t.Errorf("RenderTo() failing after %d bytes didn't write them", n)
+		}
+		if w.after != 0 {
+			t.Errorf("RenderTo() failing after %d bytes kept writing", n)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gomarkdown/markdown/ast"
 )
@@ -14,18 +15,28 @@ import (
 // form
 type Renderer struct {
 	orderedListCounter map[int]int
-	// used to keep track of whether a given list item uses a paragraph
-	// for large spacing.
-	paragraph map[int]bool
 
-	lastOutputLen  int
-	listDepth      int
-	indentSize     int
-	lastNormalText string
+	listDepth  int
+	indentSize int
 
 	C *RendererConfig
 
 	linkcache map[string]bool // cache for link definitions to write in the footer, if renderLinksInFooter is set
+
+	// Output is written line by line: every line starts with the prefixes
+	// of the blocks it is nested in, like "> " for a block quote. Newlines
+	// are held back until more output follows, so that a blank line gets
+	// the prefix of the block that follows it, not of the one that ended.
+	prefixes    []string
+	newlines    int    // newlines to write before the next output
+	blankDepth  int    // number of prefixes of the blank lines among them
+	lineStarted bool   // the prefix of the current line has been written
+	line        []byte // output of the current line after the prefix
+	wrote       bool   // anything has been written
+
+	footnotes map[string]*ast.Link // footnote links by destination
+	refs      []*ast.Link          // reference links, defined in the footer
+	refIDs    map[string]bool
 }
 
 type RendererConfig struct {
@@ -34,10 +45,26 @@ type RendererConfig struct {
 
 type Flags int
 
-const renderLinksInFooter Flags = 1 << iota
+const (
+	renderLinksInFooter Flags = 1 << iota
+	normalizeStyle
+)
 
 type RendererOpt func(c *RendererConfig)
 
+// WithNormalizedStyle makes the renderer write lists and code blocks in
+// one style, regardless of how they were written in the source: * bullets,
+// 1. numbering and ``` fences.
+func WithNormalizedStyle(normalize bool) RendererOpt {
+	return func(c *RendererConfig) {
+		if normalize {
+			c.Flags |= normalizeStyle
+		} else {
+			c.Flags &^= normalizeStyle
+		}
+	}
+}
+
 // NewRenderer returns a Markdown renderer.
 func NewRenderer(opts ...RendererOpt) *Renderer {
 	c := &RendererConfig{}
@@ -46,9 +73,10 @@ func NewRenderer(opts ...RendererOpt) *Renderer {
 	}
 	return &Renderer{
 		orderedListCounter: map[int]int{},
-		paragraph:          map[int]bool{},
 		indentSize:         4,
 		C:                  c,
+		footnotes:          map[string]*ast.Link{},
+		refIDs:             map[string]bool{},
 	}
 }
 
@@ -63,20 +91,146 @@ func WithRenderInFooter(renderInFooter bool) RendererOpt {
 }
 
 func (r *Renderer) out(w io.Writer, d []byte) {
-	r.lastOutputLen = len(d)
-	w.Write(d)
+	for len(d) > 0 {
+		if d[0] == '\n' {
+			r.newline(r.newlines + 1)
+			d = d[1:]
+			continue
+		}
+		i := bytes.IndexByte(d, '\n')
+		if i < 0 {
+			i = len(d)
+		}
+		r.startLine(w)
+		w.Write(d[:i])
+		r.line = append(r.line, d[:i]...)
+		r.wrote = true
+		d = d[i:]
+	}
 }
 
 func (r *Renderer) outs(w io.Writer, s string) {
-	r.lastOutputLen = len(s)
-	io.WriteString(w, s)
+	r.out(w, []byte(s))
+}
+
+// startLine writes the pending newlines and the line prefix.
+func (r *Renderer) startLine(w io.Writer) {
+	prefix := strings.Join(r.prefixes, "")
+	if r.newlines > 0 {
+		if r.lineStarted {
+			io.WriteString(w, "\n")
+			r.newlines--
+		}
+		depth := r.blankDepth
+		if depth > len(r.prefixes) {
+			depth = len(r.prefixes)
+		}
+		blank := strings.TrimRight(strings.Join(r.prefixes[:depth], ""), " ") + "\n"
+		for ; r.newlines > 0; r.newlines-- {
+			io.WriteString(w, blank)
+		}
+		r.lineStarted = false
+		r.line = r.line[:0]
+	}
+	if !r.lineStarted {
+		io.WriteString(w, prefix)
+		r.lineStarted = true
+	}
+}
+
+// newline sets the number of pending newlines to n. Blank lines belong
+// to the blocks that are open both where they start and where they end.
+func (r *Renderer) newline(n int) {
+	if r.newlines == 0 || r.blankDepth > len(r.prefixes) {
+		r.blankDepth = len(r.prefixes)
+	}
+	r.newlines = n
+}
+
+// endLine makes sure the next output starts on a new line.
+func (r *Renderer) endLine() {
+	if r.newlines < 1 {
+		r.newline(1)
+	}
+}
+
+// endBlock makes sure the next output is separated by a blank line.
+func (r *Renderer) endBlock() {
+	if r.wrote && r.newlines < 2 {
+		r.newline(2)
+	}
 }
 
 func (r *Renderer) doubleSpace(w io.Writer) {
-	// TODO: need to remember number of written bytes
-	// if out.Len() > 0 {
-	r.outs(w, "\n")
-	//}
+	if !r.wrote {
+		r.outs(w, "\n")
+	}
+}
+
+func (r *Renderer) pushPrefix(prefix string) {
+	r.prefixes = append(r.prefixes, prefix)
+}
+
+func (r *Renderer) popPrefix() {
+	r.prefixes = r.prefixes[:len(r.prefixes)-1]
+	if r.blankDepth > len(r.prefixes) {
+		r.blankDepth = len(r.prefixes)
+	}
+}
+
+// renderChildren renders the children of node on their own, e.g. to
+// measure the cells of a table.
+func (r *Renderer) renderChildren(node ast.Node) []byte {
+	prefixes, newlines, blankDepth, lineStarted, line, wrote := r.prefixes, r.newlines, r.blankDepth, r.lineStarted, r.line, r.wrote
+	r.prefixes, r.newlines, r.lineStarted, r.line, r.wrote = nil, 0, true, nil, true
+
+	var buf bytes.Buffer
+	for _, child := range node.GetChildren() {
+		ast.WalkFunc(child, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(&buf, node, entering)
+		})
+	}
+
+	r.prefixes, r.newlines, r.blankDepth, r.lineStarted, r.line, r.wrote = prefixes, newlines, blankDepth, lineStarted, line, wrote
+	return buf.Bytes()
+}
+
+// attribute writes the block attribute of node, unless it is shared with
+// its parent, which already wrote it.
+func (r *Renderer) attribute(w io.Writer, node ast.Node) {
+	attr := blockAttribute(node)
+	if attr == nil || (node.GetParent() != nil && blockAttribute(node.GetParent()) == attr) {
+		return
+	}
+	r.outs(w, attributeString(attr))
+	r.endLine()
+}
+
+// attributeString returns attr as written in markdown, {#id .class key="value"}
+func attributeString(attr *ast.Attribute) string {
+	var parts []string
+	if len(attr.ID) > 0 {
+		parts = append(parts, "#"+string(attr.ID))
+	}
+	for _, class := range attr.Classes {
+		parts = append(parts, "."+string(class))
+	}
+	keys := make([]string, 0, len(attr.Attrs))
+	for k := range attr.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, attr.Attrs[k]))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+func blockAttribute(node ast.Node) *ast.Attribute {
+	if c := node.AsContainer(); c != nil {
+		return c.Attribute
+	}
+	return node.AsLeaf().Attribute
 }
 
 func (r *Renderer) list(w io.Writer, node *ast.List, entering bool) {
@@ -85,44 +239,90 @@ func (r *Renderer) list(w io.Writer, node *ast.List, entering bool) {
 		flags := node.ListFlags
 		if flags&ast.ListTypeOrdered != 0 {
 			r.orderedListCounter[r.listDepth] = 1
+			if node.Start > 0 {
+				r.orderedListCounter[r.listDepth] = node.Start
+			}
 		}
 	} else {
 		r.listDepth--
 		if _, ok := node.Parent.(*ast.ListItem); !ok {
-			fmt.Fprintf(w, "\n")
+			r.endBlock()
 		}
 	}
 }
 
 func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
 	flags := node.ListFlags
-	bullet := string(node.BulletChar)
+	if !entering {
+		r.popPrefix()
+		r.endLine()
+		return
+	}
 
-	if entering {
-		for i := 1; i < r.listDepth; i++ {
-			for i := 0; i < r.indentSize; i++ {
-				fmt.Fprintf(w, " ")
-			}
+	// a blank line after an item makes all that follow contain blocks,
+	// a new term of a definition list needs one to not continue the
+	// previous definition
+	if prev, ok := ast.GetPrevNode(node).(*ast.ListItem); ok {
+		if prev.ListFlags&ast.ListItemContainsBlock != 0 || flags&ast.ListTypeTerm != 0 {
+			r.endBlock()
 		}
-		if flags&ast.ListTypeOrdered != 0 {
-			fmt.Fprintf(w, "%d. ", r.orderedListCounter[r.listDepth])
-			r.orderedListCounter[r.listDepth]++
+	}
+
+	var marker string
+	switch {
+	case flags&ast.ListTypeTerm != 0:
+		r.pushPrefix("")
+		return
+	case flags&ast.ListTypeDefinition != 0:
+		marker = ":   "
+	case flags&ast.ListTypeOrdered != 0:
+		delim := r.listMarker(node.Parent.(*ast.List), node)
+		marker = fmt.Sprintf("%d%c ", r.orderedListCounter[r.listDepth], delim)
+		r.orderedListCounter[r.listDepth]++
+	default:
+		marker = string(r.listMarker(node.Parent.(*ast.List), node)) + " "
+	}
+	r.outs(w, marker)
+	if node.IsTask {
+		if node.Checked {
+			r.outs(w, "[x] ")
 		} else {
-			fmt.Fprintf(w, "%s ", bullet)
+			r.outs(w, "[ ] ")
 		}
 	}
+	indent := r.indentSize
+	if len(marker) > indent {
+		indent = len(marker)
+	}
+	r.pushPrefix(strings.Repeat(" ", indent))
 }
 
-func (r *Renderer) para(w io.Writer, node *ast.Paragraph, entering bool) {
-	if !entering && r.lastOutputLen > 0 {
-		br := "\n\n"
+// footnotesList writes the definitions of the footnotes. They are written
+// from the source kept in the title of the links to them, which keeps the
+// titles unchanged. Inline footnotes are written where they are used.
+func (r *Renderer) footnotesList(w io.Writer, node *ast.List) {
+	for _, item := range node.GetChildren() {
+		link := r.footnotes[string(item.(*ast.ListItem).RefLink)]
+		if link == nil || link.DeferredID == nil {
+			continue
+		}
+		r.endBlock()
+		r.outs(w, "[^"+string(link.DeferredID)+"]: ")
+		r.pushPrefix(strings.Repeat(" ", r.indentSize))
+		r.out(w, bytes.TrimRight(link.Title, "\n"))
+		r.popPrefix()
+	}
+	r.endBlock()
+}
 
+func (r *Renderer) para(w io.Writer, node *ast.Paragraph, entering bool) {
+	if !entering {
 		// List items don't need the extra line-break.
 		if _, ok := node.Parent.(*ast.ListItem); ok {
-			br = "\n"
+			r.endLine()
+		} else {
+			r.endBlock()
 		}
-
-		r.outs(w, br)
 	}
 }
 
@@ -137,93 +337,202 @@ func isNumber(data []byte) bool {
 			return false
 		}
 	}
-	return true
+	return len(data) > 0
 }
 
-func needsEscaping(text []byte, lastNormalText string) bool {
-	switch string(text) {
-	case `\`,
-		"`",
-		"*",
-		"_",
-		"{", "}",
-		"[", "]",
-		"(", ")",
-		"#",
-		"+",
-		"-":
-		return true
-	case "!":
-		return false
-	case ".":
-		// Return true if number, because a period after a number must be escaped to not get parsed as an ordered list.
-		return isNumber([]byte(lastNormalText))
-	case "<", ">":
-		return true
-	default:
-		return false
+func isAlnum(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isEntity returns true if d starts with something that looks like an
+// html entity.
+func isEntity(d []byte) bool {
+	i := 1
+	if i < len(d) && d[i] == '#' {
+		i++
+	}
+	start := i
+	for i < len(d) && isAlnum(d[i]) {
+		i++
 	}
+	return i > start && i < len(d) && d[i] == ';'
 }
 
-// cleanWithoutTrim is like clean, but doesn't trim blanks.
-func cleanWithoutTrim(s string) string {
-	var b []byte
-	var p byte
-	for i := 0; i < len(s); i++ {
-		q := s[i]
-		if q == '\n' || q == '\r' || q == '\t' {
-			q = ' '
-		}
-		if q != ' ' || p != ' ' {
-			b = append(b, q)
-			p = q
+// followingText returns the text of the text nodes right after node, up to
+// a few bytes. It's parsed together with the text of node, so it decides
+// whether its last characters need escaping, e.g. & in an entity.
+func followingText(node ast.Node) []byte {
+	var buf []byte
+	for next := ast.GetNextNode(node); next != nil && len(buf) < 64; next = ast.GetNextNode(next) {
+		text, ok := next.(*ast.Text)
+		if !ok {
+			break
 		}
+		buf = append(buf, text.Literal...)
 	}
-	return string(b)
+	return buf
 }
 
-func (r *Renderer) skipSpaceIfNeededNormalText(w io.Writer, cleanString string) bool {
-	if cleanString[0] != ' ' {
-		return false
+// maxLineStart is as much of the start of a line as escaping looks at,
+// more than the longest list item number
+const maxLineStart = 16
+
+// lineState is what escaping needs to know about the output on the
+// current line before a character. It's updated with each character, as
+// copying the line for each one would take quadratic time.
+type lineState struct {
+	n     int    // the length of the line
+	last  byte   // the last character of the line
+	start []byte // the start of the line without leading spaces, up to maxLineStart bytes
+}
+
+func newLineState(line []byte) lineState {
+	st := lineState{n: len(line)}
+	if len(line) > 0 {
+		st.last = line[len(line)-1]
+	}
+	line = bytes.TrimLeft(line, " \t")
+	if len(line) > maxLineStart {
+		line = line[:maxLineStart]
 	}
+	st.start = append([]byte(nil), line...)
+	return st
+}
 
+func (st *lineState) add(c byte) {
+	st.n++
+	st.last = c
+	if len(st.start) < maxLineStart && (len(st.start) > 0 || (c != ' ' && c != '\t')) {
+		st.start = append(st.start, c)
+	}
+}
+
+// lineStart returns the start of the line without spaces around it
+func (st *lineState) lineStart() string {
+	return strings.TrimSpace(string(st.start))
+}
+
+// needsEscaping returns true if c, at text[i], would be parsed as markup.
+// line is the output on the current line before it. text is the text of
+// node followed by followingText.
+func needsEscaping(node *ast.Text, text []byte, i int, line *lineState) bool {
+	c := text[i]
+	var next byte
+	if i+1 < len(text) {
+		next = text[i+1]
+	}
+	last := i == len(text)-1
+	lineStart := line.lineStart()
+	switch c {
+	case '\\', '`', '*', '[', ']', '<', '~', '^', '$', '|', '{':
+		return true
+	case '_':
+		// intra word underscores don't start emphasis
+		return line.n == 0 || !isAlnum(line.last) || !isAlnum(next)
+	case '&':
+		return isEntity(text[i:])
+	case '!':
+		if last {
+			// the text nodes after node are empty
+			next := ast.GetNextNode(node)
+			for _, ok := next.(*ast.Text); ok; _, ok = next.(*ast.Text) {
+				next = ast.GetNextNode(next)
+			}
+			_, ok := next.(*ast.Link)
+			return ok
+		}
+		return next == '['
+	case '(':
+		return next == '#' || next == '!'
+	case '>':
+		return lineStart == "" || lineStart == "A"
+	case '#':
+		if lineStart == "" || lineStart == "." {
+			return true
+		}
+		// closing # of a heading
+		_, ok := node.Parent.(*ast.Heading)
+		return ok && last
+	case '-':
+		// a list item, a rule or the underline of a setext heading
+		return lineStart == "" && (next == 0 || next == ' ' || next == '\t' || next == '\n' || onlyDashes(text[i:]))
+	case '+', ':':
+		return lineStart == ""
+	case '.', ')':
+		// a period after a number could start an ordered list
+		return isNumber([]byte(lineStart))
+	}
 	return false
-	//  TODO: what did it mean to do?
-	// we no longer use *bytes.Buffer for out, so whatever this tracked,
-	// it has to be done in a different wy
-	/*
-		if _, ok := r.normalTextMarker[out]; !ok {
-			r.normalTextMarker[out] = -1
+}
+
+// onlyDashes returns true if the line at the start of text has only
+// dashes and spaces.
+func onlyDashes(text []byte) bool {
+	for _, c := range text {
+		switch c {
+		case '\n':
+			return true
+		case '-', ' ', '\t':
+		default:
+			return false
 		}
-		return r.normalTextMarker[out] == out.Len()
-	*/
+	}
+	return true
 }
 
-func (r *Renderer) text(w io.Writer, text *ast.Text) {
-	lit := text.Literal
-	normalText := string(text.Literal)
-	if needsEscaping(lit, r.lastNormalText) {
-		lit = append([]byte("\\"), lit...)
+// entityFor returns the html entity to write c, at text[i], as if it
+// would be parsed as markup but can't be escaped with a backslash.
+func entityFor(node *ast.Text, text []byte, i int, line *lineState) string {
+	if line.lineStart() != "" {
+		return ""
 	}
-	r.lastNormalText = normalText
-	if r.listDepth > 0 && string(lit) == "\n" {
-		// TODO: See if this can be cleaned up... It's needed for lists.
-		return
+	switch text[i] {
+	case '=':
+		// underline of a setext heading
+		if (i > 0 && text[i-1] == '\n') || (i == 0 && endsWithNewline(ast.GetPrevNode(node))) {
+			return "&#61;"
+		}
+	case '%':
+		// line of a title block
+		para, ok := node.Parent.(*ast.Paragraph)
+		if ok && ast.GetPrevNode(para) == nil && para.Parent != nil && para.Parent.GetParent() == nil {
+			return "&#37;"
+		}
 	}
-	cleanString := cleanWithoutTrim(string(lit))
-	if cleanString == "" {
-		return
+	return ""
+}
+
+func endsWithNewline(node ast.Node) bool {
+	t, ok := node.(*ast.Text)
+	return ok && len(t.Literal) > 0 && t.Literal[len(t.Literal)-1] == '\n'
+}
+
+func (r *Renderer) text(w io.Writer, text *ast.Text) {
+	lit := text.Literal
+	merged := append(lit[:len(lit):len(lit)], followingText(text)...)
+	var line lineState
+	if r.newlines == 0 {
+		line = newLineState(r.line)
 	}
-	// Skip first space if last character is already a space (i.e., no need for a 2nd space in a row).
-	if r.skipSpaceIfNeededNormalText(w, cleanString) {
-		cleanString = cleanString[1:]
+	var buf bytes.Buffer
+	for i, c := range lit {
+		if c == '\n' {
+			line = lineState{}
+			buf.WriteByte(c)
+			continue
+		}
+		if e := entityFor(text, merged, i, &line); e != "" {
+			buf.WriteString(e)
+			line.add(c)
+			continue
+		}
+		if needsEscaping(text, merged, i, &line) {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+		line.add(c)
 	}
-	r.outs(w, cleanString)
-	// If it ends with a space, make note of that.
-	//if len(cleanString) >= 1 && cleanString[len(cleanString)-1] == ' ' {
-	// TODO: write equivalent of this
-	// r.normalTextMarker[out] = out.Len()
-	//}
+	r.out(w, buf.Bytes())
 }
 
 func (r *Renderer) surround(w io.Writer, symbol string) {
@@ -237,75 +546,232 @@ func (r *Renderer) htmlSpan(w io.Writer, node *ast.HTMLSpan) {
 func (r *Renderer) htmlBlock(w io.Writer, node *ast.HTMLBlock) {
 	r.doubleSpace(w)
 	r.out(w, node.Literal)
-	r.outs(w, "\n\n")
+	r.endBlock()
 }
 
-func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
-	r.doubleSpace(w)
-	text := node.Literal
-	lang := string(node.Info)
-	// Parse out the language name.
-	count := 0
-	for _, elt := range strings.Fields(lang) {
-		if elt[0] == '.' {
-			elt = elt[1:]
-		}
-		if len(elt) == 0 {
-			continue
+func (r *Renderer) normalized() bool {
+	return r.C != nil && r.C.Flags&normalizeStyle != 0
+}
+
+// listMarker returns the bullet or the delimiter of the numbers of the
+// items of list. When normalized a list that follows another one gets the
+// other marker, as they would be merged otherwise.
+func (r *Renderer) listMarker(list *ast.List, item *ast.ListItem) byte {
+	ordered := list.ListFlags&ast.ListTypeOrdered != 0
+	if !r.normalized() {
+		if ordered && item.Delimiter != 0 {
+			return item.Delimiter
+		}
+		if !ordered && item.BulletChar != 0 {
+			return item.BulletChar
 		}
-		r.outs(w, "```")
-		r.outs(w, elt)
-		count++
-		break
 	}
+	prev, ok := ast.GetPrevNode(list).(*ast.List)
+	follows := ok && r.normalized() && prev.ListFlags&ast.ListTypeOrdered == list.ListFlags&ast.ListTypeOrdered
+	switch {
+	case ordered && follows:
+		return ')'
+	case ordered:
+		return '.'
+	case follows:
+		return '-'
+	}
+	return '*'
+}
 
-	if count == 0 {
+// fence returns a code fence that doesn't occur in the code.
+func (r *Renderer) fence(node *ast.CodeBlock) string {
+	c := node.FenceChar
+	n := node.FenceLength
+	if r.normalized() {
+		c, n = '`', 0
+		if bytes.IndexByte(node.Info, '`') >= 0 {
+			// backticks can't be in the info string of a ``` fence
+			c = '~'
+		}
+	}
+	if c == 0 {
+		c = '`'
+	}
+	if n < 3 {
+		n = 3
+	}
+	for _, line := range bytes.Split(node.Literal, []byte("\n")) {
+		line = bytes.TrimLeft(line, " ")
+		run := 0
+		for run < len(line) && line[run] == c {
+			run++
+		}
+		if run >= n {
+			n = run + 1
+		}
+	}
+	return strings.Repeat(string(c), n)
+}
+
+// isInlineCodeBlock returns true if node is in the text of a paragraph.
+// The parser makes a code block of a code span with ``` delimiters that
+// spans lines.
+func isInlineCodeBlock(node *ast.CodeBlock) bool {
+	switch node.Parent.(type) {
+	case *ast.Paragraph, *ast.Heading, *ast.TableCell, *ast.Caption,
+		*ast.Emph, *ast.Strong, *ast.Del, *ast.Link, *ast.WikiLink:
+		return true
+	}
+	return false
+}
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	if isInlineCodeBlock(node) {
+		// the literal starts after the info and ends before the fence
+		r.outs(w, "```")
+		r.out(w, node.Info)
+		r.out(w, node.Literal)
+		if bytes.HasSuffix(node.Literal, []byte("\n")) {
+			// an indented fence doesn't start a code block, the spaces
+			// before it aren't part of the code
+			r.outs(w, "    ")
+		}
 		r.outs(w, "```")
+		return
 	}
-	r.outs(w, "\n")
-	r.out(w, text)
-	if len(text) == 0 || text[len(text)-1] != '\n' {
-		r.outs(w, "\n")
+	r.doubleSpace(w)
+	text := node.Literal
+	if !node.IsFenced && len(node.Info) == 0 {
+		r.pushPrefix(strings.Repeat(" ", r.indentSize))
+		r.out(w, text)
+		r.popPrefix()
+		r.endBlock()
+		return
 	}
-	r.outs(w, "```\n\n")
+	fence := r.fence(node)
+	r.outs(w, fence)
+	r.out(w, node.Info)
+	r.endLine()
+	r.out(w, text)
+	r.endLine()
+	r.outs(w, fence)
+	r.endBlock()
 }
 
 func (r *Renderer) code(w io.Writer, node *ast.Code) {
-	r.outs(w, "`")
-	r.out(w, node.Literal)
-	r.outs(w, "`")
+	// the fence must be longer than any run of backticks in the code
+	n, run := 1, 0
+	for _, c := range node.Literal {
+		if c == '`' {
+			run++
+			if run >= n {
+				n = run + 1
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", n)
+	lit := node.Literal
+	if len(lit) > 0 && (lit[0] == '`' || lit[len(lit)-1] == '`') {
+		r.outs(w, fence+" "+string(lit)+" "+fence)
+		return
+	}
+	r.outs(w, fence)
+	r.out(w, lit)
+	r.outs(w, fence)
 }
 
 func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
 	if entering {
-		for i := 0; i < node.Level; i++ {
-			r.outs(w, "#")
+		switch {
+		case node.IsTitleblock:
+			r.pushPrefix("% ")
+		case node.IsSpecial:
+			r.outs(w, ".# ")
+		default:
+			r.outs(w, strings.Repeat("#", node.Level)+" ")
 		}
-		r.outs(w, " ")
+		return
+	}
+	if node.IsTitleblock {
+		r.popPrefix()
+	}
+	if node.HeadingID != "" {
+		r.outs(w, " {#"+node.HeadingID+"}")
+	}
+	r.endBlock()
+}
+
+func (r *Renderer) hrule(w io.Writer, node *ast.HorizontalRule) {
+	if len(node.Literal) > 0 {
 		r.out(w, node.Literal)
 	} else {
-		r.outs(w, "\n\n")
+		r.outs(w, "* * *")
 	}
+	r.endBlock()
 }
 
-func (r *Renderer) image(w io.Writer, node *ast.Image, entering bool) {
-	if entering {
-		// alt := node. ??
-		var alt []byte
-		r.outs(w, "![")
-		r.out(w, alt)
+// title writes the destination and the title of a link or an image.
+func (r *Renderer) title(w io.Writer, dest, title []byte) {
+	if bytes.HasPrefix(dest, []byte("<")) || bytes.HasSuffix(dest, []byte(">")) {
+		// the parser removes one angle bracket around the destination
+		r.outs(w, "<")
+		r.out(w, escape(dest))
+		r.outs(w, ">")
 	} else {
-		link := node.Destination
-		title := node.Title
-		r.outs(w, "](")
-		r.out(w, escape(link))
-		if len(title) != 0 {
-			r.outs(w, ` "`)
-			r.out(w, title)
-			r.outs(w, `"`)
+		r.out(w, escape(dest))
+	}
+	if len(title) != 0 {
+		r.outs(w, ` "`)
+		r.out(w, title)
+		r.outs(w, `"`)
+	}
+}
+
+func (r *Renderer) image(w io.Writer, node *ast.Image) {
+	// the alt text is not parsed, write it as is
+	r.outs(w, "![")
+	for _, child := range node.GetChildren() {
+		if t, ok := child.(*ast.Text); ok {
+			r.out(w, t.Literal)
 		}
-		r.outs(w, ")")
 	}
+	r.outs(w, "](")
+	r.title(w, node.Destination, node.Title)
+	r.outs(w, ")")
+}
+
+func (r *Renderer) footnoteLink(w io.Writer, node *ast.Link) {
+	if node.DeferredID != nil {
+		r.outs(w, "[^"+string(node.DeferredID)+"]")
+		return
+	}
+	r.outs(w, "^[")
+	r.out(w, node.Title)
+	r.outs(w, "]")
+}
+
+// isAutolink returns true if node can be written as <link>.
+func isAutolink(node *ast.Link) bool {
+	text, ok := ast.GetFirstChild(node).(*ast.Text)
+	if !ok || len(node.Children) != 1 || len(node.Title) != 0 || node.DeferredID != nil {
+		return false
+	}
+	if bytes.ContainsAny(text.Literal, " \t\n<>") {
+		return false
+	}
+	dest := node.Destination
+	if bytes.HasPrefix(dest, []byte("mailto:")) && bytes.Equal(dest[len("mailto:"):], text.Literal) {
+		return true
+	}
+	// without a scheme <dest> is an html tag
+	return bytes.Equal(dest, text.Literal) && hasScheme(dest)
+}
+
+// hasScheme returns true if link starts with a URL scheme, e.g. https:
+func hasScheme(link []byte) bool {
+	i := 0
+	for i < len(link) && (isAlnum(link[i]) || (i > 0 && bytes.IndexByte([]byte("+.-"), link[i]) >= 0)) {
+		i++
+	}
+	return i > 0 && i < len(link) && link[i] == ':'
 }
 
 func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) {
@@ -315,13 +781,19 @@ func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) {
 		link := string(escape(node.Destination))
 		title := string(node.Title)
 		if r.C == nil || r.C.Flags&renderLinksInFooter == 0 {
-			r.outs(w, "](")
-			r.outs(w, link)
-			if len(title) != 0 {
-				r.outs(w, ` "`)
-				r.outs(w, title)
-				r.outs(w, `"`)
+			if node.DeferredID != nil {
+				r.outs(w, "][")
+				r.out(w, node.DeferredID)
+				r.outs(w, "]")
+				id := strings.ToLower(string(node.DeferredID))
+				if !r.refIDs[id] {
+					r.refIDs[id] = true
+					r.refs = append(r.refs, node)
+				}
+				return
 			}
+			r.outs(w, "](")
+			r.title(w, node.Destination, node.Title)
 			r.outs(w, ")")
 			return
 		}
@@ -340,15 +812,382 @@ func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) {
 	}
 }
 
+func (r *Renderer) wikiLink(w io.Writer, node *ast.WikiLink) {
+	r.outs(w, "[[")
+	r.out(w, node.Page)
+	if len(node.Section) > 0 {
+		r.outs(w, "#")
+		r.out(w, node.Section)
+	}
+	if node.Label != nil {
+		r.outs(w, "|")
+		r.out(w, node.Label)
+	}
+	r.outs(w, "]]")
+}
+
+func (r *Renderer) blockQuote(w io.Writer, prefix string, entering bool) {
+	if entering {
+		r.pushPrefix(prefix)
+	} else {
+		r.popPrefix()
+		r.endBlock()
+	}
+}
+
+func (r *Renderer) admonition(w io.Writer, node *ast.Admonition, entering bool) {
+	r.blockQuote(w, "> ", entering)
+	if !entering {
+		return
+	}
+	r.outs(w, "[!"+strings.ToUpper(node.Kind.String())+"]")
+	if len(node.Title) > 0 {
+		r.outs(w, " ")
+		r.out(w, node.Title)
+	}
+	r.endLine()
+}
+
+// fencedDiv writes the fences of a fenced div, with more colons than the
+// fenced divs nested in it
+func (r *Renderer) fencedDiv(w io.Writer, div *ast.FencedDiv, entering bool) {
+	fence := strings.Repeat(":", 3+fencedDivDepth(div))
+	if !entering {
+		r.endLine()
+		r.outs(w, fence)
+		r.endBlock()
+		return
+	}
+	parts := []string{fence}
+	if div.Name != "" {
+		parts = append(parts, div.Name)
+	}
+	if div.Args != "" {
+		parts = append(parts, div.Args)
+	}
+	if div.Attribute != nil {
+		parts = append(parts, attributeString(div.Attribute))
+	}
+	r.outs(w, strings.Join(parts, " "))
+	r.endLine()
+}
+
+// fencedDivDepth returns how deep fenced divs are nested in div
+func fencedDivDepth(div ast.Node) int {
+	depth := 0
+	for _, child := range div.GetChildren() {
+		d := fencedDivDepth(child)
+		if _, ok := child.(*ast.FencedDiv); ok {
+			d++
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// tableRows returns the rows of a table, header, body or footer.
+func tableRows(node ast.Node) []*ast.TableRow {
+	var rows []*ast.TableRow
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if row, ok := node.(*ast.TableRow); ok && entering {
+			rows = append(rows, row)
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+	})
+	return rows
+}
+
+func (r *Renderer) table(w io.Writer, node *ast.Table) {
+	var header, body, footer []*ast.TableRow
+	for _, child := range node.GetChildren() {
+		switch child.(type) {
+		case *ast.TableHeader:
+			header = append(header, tableRows(child)...)
+		case *ast.TableFooter:
+			footer = append(footer, tableRows(child)...)
+		default:
+			body = append(body, tableRows(child)...)
+		}
+	}
+
+	// the columns are those of the first row, the parser keeps the
+	// extra cells of longer rows
+	var widths []int
+	var aligns []ast.CellAlignFlags
+	if len(header)+len(body) > 0 {
+		for _, child := range append(header, body...)[0].GetChildren() {
+			cell := child.(*ast.TableCell)
+			for i := 0; i == 0 || i < cell.ColSpan; i++ {
+				widths = append(widths, 3)
+				aligns = append(aligns, cell.Align)
+			}
+		}
+	}
+
+	// render all cells first to line up the columns
+	cells := map[*ast.TableCell]string{}
+	for _, rows := range [][]*ast.TableRow{header, body, footer} {
+		for _, row := range rows {
+			col := 0
+			for _, child := range row.GetChildren() {
+				cell := child.(*ast.TableCell)
+				s := string(r.renderChildren(cell))
+				cells[cell] = s
+				if n := utf8.RuneCountInString(s); col < len(widths) && cell.ColSpan < 2 && n > widths[col] {
+					widths[col] = n
+				}
+				if cell.ColSpan > 1 {
+					col += cell.ColSpan
+				} else {
+					col++
+				}
+			}
+		}
+	}
+
+	writeRow := func(row *ast.TableRow) {
+		col := 0
+		r.outs(w, "|")
+		for _, child := range row.GetChildren() {
+			cell := child.(*ast.TableCell)
+			s := cells[cell]
+			if cell.ColSpan > 1 {
+				r.outs(w, " "+s+" "+strings.Repeat("|", cell.ColSpan))
+				col += cell.ColSpan
+				continue
+			}
+			pad := 0
+			if col < len(widths) {
+				pad = widths[col] - utf8.RuneCountInString(s)
+			}
+			r.outs(w, " "+s+strings.Repeat(" ", pad)+" |")
+			col++
+		}
+		r.endLine()
+	}
+
+	for _, row := range header {
+		writeRow(row)
+	}
+	r.outs(w, "|")
+	for i, width := range widths {
+		dashes := strings.Repeat("-", width)
+		switch aligns[i] {
+		case ast.TableAlignmentLeft:
+			dashes = ":" + dashes[1:]
+		case ast.TableAlignmentRight:
+			dashes = dashes[1:] + ":"
+		case ast.TableAlignmentCenter:
+			dashes = ":" + dashes[2:] + ":"
+		}
+		r.outs(w, " "+dashes+" |")
+	}
+	r.endLine()
+	for _, row := range body {
+		writeRow(row)
+	}
+	if len(footer) > 0 {
+		r.outs(w, "|")
+		for _, width := range widths {
+			r.outs(w, strings.Repeat("=", width+2)+"|")
+		}
+		r.endLine()
+		for _, row := range footer {
+			writeRow(row)
+		}
+	}
+	r.endBlock()
+}
+
+// isCaptioned returns true if the caption of figure follows a block that
+// can have one without a figure block around it.
+func isCaptioned(figure *ast.CaptionFigure) bool {
+	children := figure.GetChildren()
+	if len(children) != 2 {
+		return false
+	}
+	if _, ok := children[1].(*ast.Caption); !ok {
+		return false
+	}
+	switch children[0].(type) {
+	case *ast.CodeBlock, *ast.BlockQuote, *ast.Table:
+		return true
+	}
+	return false
+}
+
+func (r *Renderer) captionFigure(w io.Writer, node *ast.CaptionFigure, entering bool) {
+	if isCaptioned(node) {
+		return
+	}
+	if !entering {
+		// the caption writes the closing marker before it
+		if _, ok := ast.GetLastChild(node).(*ast.Caption); !ok {
+			r.newlines = 1
+			r.outs(w, "!---")
+		}
+		r.endBlock()
+		return
+	}
+	r.outs(w, "!---")
+	r.endLine()
+}
+
+func (r *Renderer) caption(w io.Writer, node *ast.Caption, entering bool) {
+	figure, _ := node.Parent.(*ast.CaptionFigure)
+	if !entering {
+		if figure != nil && figure.HeadingID != "" {
+			if n := len(r.line); n == 0 || r.line[n-1] != ' ' {
+				r.outs(w, " ")
+			}
+			r.outs(w, "{#"+figure.HeadingID+"}")
+		}
+		r.endBlock()
+		return
+	}
+	label := "Figure: "
+	if figure != nil {
+		switch figure.Children[0].(type) {
+		case *ast.Table:
+			label = "Table: "
+		case *ast.BlockQuote:
+			label = "Quote: "
+		}
+		if _, ok := figure.Children[0].(*ast.BlockQuote); ok {
+			// a blank line ends the quote
+		} else if isCaptioned(figure) {
+			// the caption must follow the block directly
+			r.newlines = 1
+		} else {
+			// the caption goes after the closing marker
+			r.newlines = 1
+			r.outs(w, "!---")
+		}
+	}
+	r.endLine()
+	r.outs(w, label)
+}
+
+func (r *Renderer) citation(w io.Writer, node *ast.Citation) {
+	var parts []string
+	for i, dest := range node.Destination {
+		s := "@"
+		switch node.Type[i] {
+		case ast.CitationTypeNormative:
+			s += "!"
+		case ast.CitationTypeSuppressed:
+			s += "-"
+		}
+		s += string(dest)
+		if len(node.Suffix[i]) > 0 {
+			s += ", " + string(node.Suffix[i])
+		}
+		parts = append(parts, s)
+	}
+	r.outs(w, "["+strings.Join(parts, "; ")+"]")
+}
+
+func (r *Renderer) index(w io.Writer, node *ast.Index) {
+	r.outs(w, "(!")
+	if node.Primary {
+		r.outs(w, "!")
+	}
+	r.out(w, node.Item)
+	if len(node.Subitem) > 0 {
+		r.outs(w, ", ")
+		r.out(w, node.Subitem)
+	}
+	r.outs(w, ")")
+}
+
+func (r *Renderer) crossReference(w io.Writer, node *ast.CrossReference) {
+	r.outs(w, "(#")
+	r.out(w, node.Destination)
+	if len(node.Suffix) > 0 {
+		r.outs(w, ", ")
+		r.out(w, node.Suffix)
+	}
+	r.outs(w, ")")
+}
+
+func (r *Renderer) documentMatter(w io.Writer, node *ast.DocumentMatter) {
+	switch node.Matter {
+	case ast.DocumentMatterFront:
+		r.outs(w, "{frontmatter}")
+	case ast.DocumentMatterMain:
+		r.outs(w, "{mainmatter}")
+	case ast.DocumentMatterBack:
+		r.outs(w, "{backmatter}")
+	}
+	r.endBlock()
+}
+
+func (r *Renderer) toc(w io.Writer, node *ast.TOC) {
+	r.outs(w, "[TOC")
+	if node.Section {
+		r.outs(w, " section")
+	}
+	switch {
+	case node.MinLevel > 0 && node.MinLevel == node.MaxLevel:
+		r.outs(w, fmt.Sprintf(" levels=%d", node.MinLevel))
+	case node.MinLevel > 0 || node.MaxLevel > 0:
+		r.outs(w, fmt.Sprintf(" levels=%d-%d", node.MinLevel, node.MaxLevel))
+	}
+	r.outs(w, "]")
+	r.endBlock()
+}
+
+var frontMatterDelims = map[ast.FrontMatterFormats]string{
+	ast.FrontMatterYAML: "---",
+	ast.FrontMatterTOML: "+++",
+	ast.FrontMatterJSON: ";;;",
+}
+
+func (r *Renderer) frontMatter(w io.Writer, node *ast.FrontMatter) {
+	delim := frontMatterDelims[node.Format]
+	r.outs(w, delim+"\n")
+	r.out(w, node.Literal)
+	r.outs(w, delim)
+	r.endBlock()
+}
+
+// isBlock returns true if node is a block that starts on a new line.
+func isBlock(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Paragraph, *ast.Heading, *ast.HorizontalRule, *ast.BlockQuote, *ast.Aside, *ast.Admonition, *ast.FencedDiv,
+		*ast.CodeBlock, *ast.HTMLBlock, *ast.List, *ast.Table, *ast.MathBlock,
+		*ast.CaptionFigure, *ast.DocumentMatter, *ast.TOC, *ast.FrontMatter:
+		return true
+	}
+	return false
+}
+
 // RenderNode renders markdown node
 func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if entering && isBlock(node) {
+		// blocks of a list item with a blank line in it are separated by
+		// blank lines too
+		if item, ok := node.GetParent().(*ast.ListItem); ok && ast.GetPrevNode(node) != nil &&
+			item.ListFlags&ast.ListItemContainsBlock != 0 {
+			r.endBlock()
+		}
+		if _, ok := node.(*ast.FencedDiv); !ok {
+			// the attribute of a fenced div is on its opening fence
+			r.attribute(w, node)
+		}
+	}
 	switch node := node.(type) {
 	case *ast.Text:
 		r.text(w, node)
 	case *ast.Softbreak:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.outs(w, "\n")
 	case *ast.Hardbreak:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.outs(w, "\\\n")
+	case *ast.NonBlockingSpace:
+		r.outs(w, "\\ ")
 	case *ast.Emph:
 		r.surround(w, "*")
 	case *ast.Strong:
@@ -356,26 +1195,55 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.Del:
 		r.surround(w, "~~")
 	case *ast.BlockQuote:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.blockQuote(w, "> ", entering)
 	case *ast.Aside:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.blockQuote(w, "A> ", entering)
+	case *ast.Admonition:
+		r.admonition(w, node, entering)
+	case *ast.FencedDiv:
+		r.fencedDiv(w, node, entering)
 	case *ast.Link:
+		if node.NoteID > 0 {
+			if entering {
+				r.footnoteLink(w, node)
+			}
+			return ast.SkipChildren
+		}
+		if (r.C == nil || r.C.Flags&renderLinksInFooter == 0) && isAutolink(node) {
+			if entering {
+				r.outs(w, "<")
+				r.out(w, node.Children[0].AsLeaf().Literal)
+				r.outs(w, ">")
+			}
+			return ast.SkipChildren
+		}
 		r.link(w, node, entering)
+	case *ast.WikiLink:
+		if entering {
+			r.wikiLink(w, node)
+		}
+		return ast.SkipChildren
 	case *ast.CrossReference:
-		panic(fmt.Sprintf("node %T NYI", node))
+		if entering {
+			r.crossReference(w, node)
+		}
+		return ast.SkipChildren
 	case *ast.Citation:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.citation(w, node)
 	case *ast.Image:
-		r.image(w, node, entering)
+		if entering {
+			r.image(w, node)
+		}
+		return ast.SkipChildren
 	case *ast.Code:
 		r.code(w, node)
 	case *ast.CodeBlock:
 		r.codeBlock(w, node)
 	case *ast.Caption:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.caption(w, node, entering)
 	case *ast.CaptionFigure:
-		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.Document:
+		r.captionFigure(w, node, entering)
+	case *ast.Document, *ast.Section:
 		// do nothing
 	case *ast.Paragraph:
 		r.para(w, node, entering)
@@ -386,39 +1254,60 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.Heading:
 		r.heading(w, node, entering)
 	case *ast.HorizontalRule:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.hrule(w, node)
 	case *ast.List:
+		if node.IsFootnotesList {
+			if entering {
+				r.footnotesList(w, node)
+			}
+			return ast.SkipChildren
+		}
 		r.list(w, node, entering)
 	case *ast.ListItem:
 		r.listItem(w, node, entering)
 	case *ast.Table:
-		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.TableCell:
-		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.TableHeader:
-		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.TableBody:
-		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.TableRow:
-		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.TableFooter:
-		panic(fmt.Sprintf("node %T NYI", node))
+		if entering {
+			r.table(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.TableCell, *ast.TableHeader, *ast.TableBody, *ast.TableRow, *ast.TableFooter:
+		// written by table
 	case *ast.Math:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.outs(w, "$")
+		r.out(w, node.Literal)
+		r.outs(w, "$")
 	case *ast.MathBlock:
-		panic(fmt.Sprintf("node %T NYI", node))
+		if entering {
+			r.outs(w, "$$")
+			r.out(w, node.Literal)
+			r.outs(w, "$$")
+			r.endBlock()
+		}
+		return ast.SkipChildren
 	case *ast.DocumentMatter:
-		panic(fmt.Sprintf("node %T NYI", node))
+		if entering {
+			r.documentMatter(w, node)
+		}
 	case *ast.Callout:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.outs(w, "<<")
+		r.out(w, node.ID)
+		r.outs(w, ">>")
 	case *ast.Index:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.index(w, node)
 	case *ast.Subscript:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.outs(w, "~")
+		r.out(w, node.Literal)
+		r.outs(w, "~")
 	case *ast.Superscript:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.outs(w, "^")
+		r.out(w, node.Literal)
+		r.outs(w, "^")
 	case *ast.Footnotes:
 		// nothing by default; just output the list.
+	case *ast.TOC:
+		r.toc(w, node)
+	case *ast.FrontMatter:
+		r.frontMatter(w, node)
 	default:
 		panic(fmt.Sprintf("Unknown node %T", node))
 	}
@@ -426,12 +1315,39 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 }
 
 // RenderHeader renders header
-func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
-	// do nothing
+func (r *Renderer) RenderHeader(w io.Writer, doc ast.Node) {
+	// footnote definitions are written from the links to them
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if link, ok := node.(*ast.Link); ok && entering && link.NoteID > 0 {
+			if r.footnotes[string(link.Destination)] == nil {
+				r.footnotes[string(link.Destination)] = link
+			}
+		}
+		return ast.GoToNext
+	})
 }
 
 // RenderFooter renders footer
-func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+	if r.lineStarted && r.newlines > 0 {
+		io.WriteString(w, "\n")
+		r.newlines--
+	}
+	io.WriteString(w, strings.Repeat("\n", r.newlines))
+	r.newlines = 0
+	r.lineStarted = false
+
+	if len(r.refs) > 0 {
+		for _, link := range r.refs {
+			r.outs(w, "[")
+			r.out(w, link.DeferredID)
+			r.outs(w, "]: ")
+			r.title(w, link.Destination, link.Title)
+			r.endLine()
+		}
+		io.WriteString(w, "\n")
+	}
+
 	if r.C != nil && r.C.Flags&renderLinksInFooter != 0 {
 		if r.linkcache == nil {
 			return
@@ -446,9 +1362,9 @@ func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
 		sort.Strings(links)
 
 		for _, linkdefn := range links {
-			r.outs(w, "\n")
-			r.outs(w, linkdefn)
+			io.WriteString(w, "\n")
+			io.WriteString(w, linkdefn)
 		}
-		r.outs(w, "\n")
+		io.WriteString(w, "\n")
 	}
 }
@@ -1,8 +1,15 @@
 package md
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/ast"
@@ -39,6 +46,53 @@ func TestRenderText(t *testing.T) {
 	testRendering(t, input, expected)
 }
 
+func TestRenderEscapingAcrossText(t *testing.T) {
+	// the parser splits text at entities and escapes, so whether a
+	// character is escaped depends on the text nodes after it
+	tests := []string{
+		"&copy; and \\&copy;\n",
+		"\\&copy; and \\&copy;\n\n",
+
+		"(!item) and \\(!item) \\(#ref)\n",
+		"(!item) and \\(!item) \\(#ref)\n\n",
+	}
+	for i := 0; i < len(tests); i += 2 {
+		p := parser.NewWithExtensions(parser.CommonExtensions | parser.Mmark)
+		input := p.Parse([]byte(tests[i]))
+		testRendering(t, input, tests[i+1])
+	}
+}
+
+func TestRenderDashesAndAutolinks(t *testing.T) {
+	// dashes at the start of a line are only escaped if they'd start a list
+	// item, a rule or a setext underline, and a link without a scheme isn't
+	// written as an autolink, which would be an html tag
+	tests := []string{
+		"a\n\\- b\n\\---\n-----*\n",
+		"a\n\\- b\n\\---\n-----\\*\n\n",
+
+		"[a/b.go](a/b.go) <http://x.org> <me@x.org>\n",
+		"[a/b.go](a/b.go) <http://x.org> <me@x.org>\n\n",
+	}
+	for i := 0; i < len(tests); i += 2 {
+		p := parser.NewWithExtensions(parser.CommonExtensions)
+		input := p.Parse([]byte(tests[i]))
+		testRendering(t, input, tests[i+1])
+	}
+}
+
+// TestRenderLongLineScaling guards against escaping copying the line for
+// each character again, which took quadratic time. The bound is generous so
+// it only fires if that's reintroduced.
+func TestRenderLongLineScaling(t *testing.T) {
+	input := markdown.Parse([]byte(strings.Repeat("a_b ", 50000)), nil)
+	start := time.Now()
+	markdown.Render(input, NewRenderer())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("rendering a long line took %s; escaping may have regressed to O(N^2)", elapsed)
+	}
+}
+
 func TestRenderStrong(t *testing.T) {
 	var input ast.Node = &ast.Strong{}
 	ast.AppendChild(input, &ast.Text{Leaf: ast.Leaf{Literal: []byte(string("Hello"))}})
@@ -74,6 +128,13 @@ func TestRenderLink(t *testing.T) {
 	testRendering(t, input, expected)
 }
 
+func TestRenderLinkAngleBrackets(t *testing.T) {
+	var input ast.Node = &ast.Link{Destination: []byte("http://x.org/a\">")}
+	ast.AppendChild(input, &ast.Text{Leaf: ast.Leaf{Literal: []byte("x")}})
+	expected := "[x](<http://x.org/a\">>)"
+	testRendering(t, input, expected)
+}
+
 func TestRenderImage(t *testing.T) {
 	var input ast.Node = &ast.Image{Title: []byte(string("Hello")), Destination: []byte(string("hello.io"))}
 	ast.AppendChild(input, &ast.Text{Leaf: ast.Leaf{Literal: []byte(string("Hello World !"))}})
@@ -88,6 +149,14 @@ func TestRenderCode(t *testing.T) {
 	testRendering(t, input, expected)
 }
 
+func TestRenderInlineCodeBlock(t *testing.T) {
+	// a ``` code span over lines is a code block in the paragraph
+	source := "text\n    ```oz\nleading spaces\n    ```\nmore\n\n"
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	input := p.Parse([]byte(source))
+	testRendering(t, input, source)
+}
+
 func TestRenderCodeBlock(t *testing.T) {
 	input := &ast.CodeBlock{Info: []byte(string("scala"))}
 	input.Literal = []byte(string("val x : Int = 42"))
@@ -195,6 +264,69 @@ func TestRenderList(t *testing.T) {
 	testRendering(t, input, expected, rendererOpts...)
 }
 
+func TestRenderTaskList(t *testing.T) {
+	source := []byte("- [ ] todo\n- [x] done\n- plain\n")
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.TaskLists)
+	input := p.Parse(source)
+	expected := "- [ ] todo\n- [x] done\n- plain\n\n"
+	testRendering(t, input, expected)
+}
+
+func TestRenderFrontMatter(t *testing.T) {
+	tests := []string{
+		"---\ntitle: x\n\ntags: [a]\n---\n\n# A\n\n",
+		"+++\ntitle = \"x\"\n+++\n\ntext\n\n",
+		";;;\n{\"title\": \"x\"}\n;;;\n\ntext\n\n",
+	}
+	for _, source := range tests {
+		p := parser.NewWithExtensions(parser.CommonExtensions | parser.FrontMatter)
+		input := p.Parse([]byte(source))
+		testRendering(t, input, source)
+	}
+}
+
+func TestRenderAlerts(t *testing.T) {
+	tests := []string{
+		"> [!NOTE]\n> Useful *info*.\n>\n> More.\n\n",
+		"> [!WARNING] Be careful\n> - x\n\n",
+		"> [!TIP]\n\n",
+	}
+	for _, source := range tests {
+		p := parser.NewWithExtensions(parser.CommonExtensions | parser.Alerts)
+		input := p.Parse([]byte(source))
+		testRendering(t, input, source)
+	}
+}
+
+func TestRenderFencedDivs(t *testing.T) {
+	tests := []string{
+		"::: warning\nBe *careful*.\n\n:::\n\n",
+		":::: note {#n .big}\n::: danger\ninner\n\n:::\n\n::::\n\n",
+		"::: youtube abc start=10\n:::\n\n",
+	}
+	for _, source := range tests {
+		p := parser.NewWithExtensions(parser.CommonExtensions | parser.FencedDivs)
+		input := p.Parse([]byte(source))
+		testRendering(t, input, source)
+	}
+}
+
+func TestRenderWikiLinks(t *testing.T) {
+	source := "See [[Home Page]], [[Setup#Install Steps|*installing*]] and [[#Notes]].\n\n"
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.WikiLinks)
+	input := p.Parse([]byte(source))
+	testRendering(t, input, source)
+}
+
+func TestRenderNormalizedStyle(t *testing.T) {
+	source := []byte("Title\n=====\n\n- _a_\n+ __b__\n\n3) x\n7) y\n\n~~~ go\ncode\n~~~\n\n|a|b|\n|-:|:-|\n|long cell|c|\n")
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.OrderedListStart)
+	input := p.Parse(source)
+	expected := "# Title\n\n* *a*\n* **b**\n\n3. x\n4. y\n\n```go\ncode\n```\n\n" +
+		"| a         | b   |\n| --------: | :-- |\n| long cell | c   |\n\n"
+	testRendering(t, input, expected, WithNormalizedStyle(true))
+}
+
 func testRendering(t *testing.T, input ast.Node, expected string, opts ...RendererOpt) {
 	renderer := NewRenderer(opts...)
 	result := string(markdown.Render(input, renderer))
@@ -202,3 +334,118 @@ func testRendering(t *testing.T, input ast.Node, expected string, opts ...Render
 		t.Errorf("[%s] is not equal to [%s]", result, expected)
 	}
 }
+
+// roundTripExtensions are the extensions the testdata corpus is parsed with
+// to check that rendering to markdown preserves the AST.
+const roundTripExtensions = parser.CommonExtensions | parser.Attributes | parser.OrderedListStart |
+	parser.SuperSubscript | parser.Mmark | parser.Footnotes | parser.Titleblock | parser.TaskLists
+
+// roundTripInputs returns the markdown inputs of the testdata corpus.
+func roundTripInputs(t *testing.T) map[string][]byte {
+	inputs := map[string][]byte{}
+	for _, pattern := range []string{"*.text", "*.md", "*.test", "*.tests"} {
+		paths, err := filepath.Glob(filepath.Join("..", "testdata", pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			d, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := filepath.Base(path)
+			if strings.HasSuffix(path, ".text") || strings.HasSuffix(path, ".md") {
+				inputs[name] = d
+				continue
+			}
+			// .test(s) files alternate between markdown and the expected html
+			parts := bytes.Split(d, []byte("+++\n"))
+			for i := 0; i < len(parts); i += 2 {
+				inputs[fmt.Sprintf("%s#%d", name, i/2+1)] = parts[i]
+			}
+		}
+	}
+	return inputs
+}
+
+// dumpAST prints the type and the fields of every node in the tree that
+// matter for rendering. Positions are ignored and adjacent text nodes are
+// merged, as the way text is split into nodes depends on the markup.
+func dumpAST(w io.Writer, node ast.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	v := reflect.ValueOf(node).Elem()
+	fmt.Fprintf(w, "%s%s", indent, v.Type().Name())
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		fv := v.Field(i)
+		if f.Anonymous {
+			// ast.Container or ast.Leaf
+			if attr := fv.FieldByName("Attribute").Interface().(*ast.Attribute); attr != nil {
+				fmt.Fprintf(w, " Attribute=%q/%q/%q", attr.ID, attr.Classes, attr.Attrs)
+			}
+			if lit := fv.FieldByName("Literal").Bytes(); len(lit) > 0 {
+				fmt.Fprintf(w, " Literal=%q", lit)
+			}
+			continue
+		}
+		if f.Type == reflect.TypeOf((*ast.Node)(nil)).Elem() {
+			continue
+		}
+		switch x := fv.Interface().(type) {
+		case []byte:
+			fmt.Fprintf(w, " %s=%q", f.Name, x)
+		case [][]byte:
+			fmt.Fprintf(w, " %s=%q", f.Name, x)
+		default:
+			fmt.Fprintf(w, " %s=%v", f.Name, x)
+		}
+	}
+	fmt.Fprintln(w)
+
+	var text []byte
+	inText := false
+	for _, child := range node.GetChildren() {
+		if t, ok := child.(*ast.Text); ok {
+			text = append(text, t.Literal...)
+			inText = true
+			continue
+		}
+		if inText && len(text) > 0 {
+			fmt.Fprintf(w, "%s  Text Literal=%q\n", indent, text)
+		}
+		text, inText = nil, false
+		dumpAST(w, child, depth+1)
+	}
+	if inText && len(text) > 0 {
+		fmt.Fprintf(w, "%s  Text Literal=%q\n", indent, text)
+	}
+}
+
+func astString(doc ast.Node) string {
+	var buf bytes.Buffer
+	dumpAST(&buf, doc, 0)
+	return buf.String()
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	for name, input := range roundTripInputs(t) {
+		doc := parser.NewWithExtensions(roundTripExtensions).Parse(input)
+		output := markdown.Render(doc, NewRenderer())
+		// blank lines at the end of a caption are a part of it
+		output = append(bytes.TrimRight(output, "\n"), '\n')
+		doc2 := parser.NewWithExtensions(roundTripExtensions).Parse(output)
+		want, got := astString(doc), astString(doc2)
+		if want != got {
+			t.Errorf("%s: AST changed after rendering to markdown\nInput:\n%s\nOutput:\n%s\nWant:\n%s\nGot:\n%s",
+				name, input, output, want, got)
+		}
+		// astString merges adjacent text, so check how the text is
+		// escaped by rendering again
+		output2 := markdown.Render(doc2, NewRenderer())
+		output2 = append(bytes.TrimRight(output2, "\n"), '\n')
+		if !bytes.Equal(output, output2) {
+			t.Errorf("%s: markdown changed after rendering it again\nInput:\n%s\nOutput:\n%s\nAgain:\n%s",
+				name, input, output, output2)
+		}
+	}
+}
@@ -13,10 +13,6 @@ import (
 )
 
 func TestMd(t *testing.T) {
-	if true {
-		// disabled for now because md render is not finished
-		return
-	}
 	files := []string{
 		"md1",
 		"md2",
@@ -0,0 +1,23 @@
+/*
+Package pandoc implements a renderer of parsed markdown document to the JSON
+format of Pandoc AST.
+
+	import (
+		"github.com/gomarkdown/markdown"
+		"github.com/gomarkdown/markdown/pandoc"
+	)
+
+	renderer := pandoc.NewRenderer(pandoc.RendererOptions{})
+	json := markdown.Render(doc, renderer)
+
+The output can be converted by pandoc to other formats, e.g. docx or epub:
+
+	pandoc -f json -o doc.docx doc.json
+
+Mmark citations are written as Cite, index items as empty Spans with the
+"index" class and the "item", "subitem" and "primary" attributes, asides as
+Divs with the "aside" class and captioned code blocks and quotes as Figures.
+A title block is the "title" of the document meta data. Document divisions
+({frontmatter} etc.) have no equivalent and are dropped.
+*/
+package pandoc
@@ -0,0 +1,639 @@
+package pandoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// APIVersion is the version of the pandoc-types JSON format written by
+// Renderer.
+const APIVersion = "1.23.1"
+
+// Element is an element of Pandoc AST: a block, an inline or a meta value.
+// T is the name of its constructor, e.g. "Para", C its contents.
+type Element struct {
+	T string      `json:"t"`
+	C interface{} `json:"c,omitempty"`
+}
+
+// Citation is a citation in a Cite inline.
+type Citation struct {
+	ID      string    `json:"citationId"`
+	Prefix  []Element `json:"citationPrefix"`
+	Suffix  []Element `json:"citationSuffix"`
+	Mode    Element   `json:"citationMode"`
+	NoteNum int       `json:"citationNoteNum"`
+	Hash    int       `json:"citationHash"`
+}
+
+// Document is a complete Pandoc document.
+type Document struct {
+	APIVersion []int              `json:"pandoc-api-version"`
+	Meta       map[string]Element `json:"meta"`
+	Blocks     []Element          `json:"blocks"`
+}
+
+// NodeFunc allows converting custom nodes or replacing the conversion of
+// some nodes. It returns the Pandoc blocks or inlines, depending on where
+// node is, and true, or false if Renderer should convert node.
+type NodeFunc func(node ast.Node) ([]Element, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of Pandoc renderer.
+type RendererOptions struct {
+	// Meta is written as meta strings of the document, e.g. "author".
+	// A title block of the document is the "title".
+	Meta map[string]string
+
+	// if set, called before converting a node
+	NodeHook NodeFunc
+}
+
+// Renderer implements Renderer interface for Pandoc JSON output.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	meta map[string]Element
+}
+
+// NewRenderer creates and configures a Renderer object, which
+// satisfies the Renderer interface.
+func NewRenderer(opts RendererOptions) *Renderer {
+	return &Renderer{Opts: opts}
+}
+
+// RenderHeader writes nothing, the whole document is written by RenderNode
+func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
+}
+
+// RenderFooter writes nothing, the whole document is written by RenderNode
+func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
+}
+
+// RenderNode writes the Pandoc JSON document of node, which usually is
+// the ast.Document, and skips its children. It returns ast.Terminate if
+// writing to w fails, markdown.RenderTo returns the error.
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if !entering {
+		return ast.GoToNext
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.Convert(node)); err != nil {
+		return ast.Terminate
+	}
+	return ast.SkipChildren
+}
+
+// Convert returns the Pandoc document of node.
+func (r *Renderer) Convert(node ast.Node) *Document {
+	r.meta = map[string]Element{}
+	doc := &Document{APIVersion: apiVersion(), Meta: r.meta}
+	if _, ok := node.(*ast.Document); ok {
+		doc.Blocks = r.blocks(node.GetChildren(), false)
+	} else {
+		doc.Blocks = r.blocks([]ast.Node{node}, false)
+	}
+	for k, v := range r.Opts.Meta {
+		r.meta[k] = Element{"MetaString", v}
+	}
+	return doc
+}
+
+// apiVersion returns the numbers of APIVersion
+func apiVersion() []int {
+	var version []int
+	for _, s := range strings.Split(APIVersion, ".") {
+		n, _ := strconv.Atoi(s)
+		version = append(version, n)
+	}
+	return version
+}
+
+func isBlock(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Paragraph, *ast.Heading, *ast.List, *ast.ListItem,
+		*ast.BlockQuote, *ast.Aside, *ast.Admonition, *ast.FencedDiv, *ast.CodeBlock, *ast.HTMLBlock,
+		*ast.HorizontalRule, *ast.Table, *ast.CaptionFigure,
+		*ast.MathBlock, *ast.DocumentMatter, *ast.Footnotes, *ast.TOC,
+		*ast.Section, *ast.FrontMatter:
+		return true
+	}
+	return false
+}
+
+// blocks converts nodes to blocks. Inline nodes among them, e.g. the
+// content of a footnote, are put in a Plain if tight, Para otherwise
+func (r *Renderer) blocks(nodes []ast.Node, tight bool) []Element {
+	res := []Element{}
+	var inlines []ast.Node
+	flush := func() {
+		if len(inlines) > 0 {
+			res = append(res, paragraph(r.inlines(inlines), tight))
+			inlines = nil
+		}
+	}
+	for _, node := range nodes {
+		if !isBlock(node) {
+			inlines = append(inlines, node)
+			continue
+		}
+		flush()
+		res = append(res, r.block(node, tight)...)
+	}
+	flush()
+	return res
+}
+
+func paragraph(inlines []Element, tight bool) Element {
+	if tight {
+		return Element{"Plain", inlines}
+	}
+	return Element{"Para", inlines}
+}
+
+func (r *Renderer) block(node ast.Node, tight bool) []Element {
+	if r.Opts.NodeHook != nil {
+		if res, ok := r.Opts.NodeHook(node); ok {
+			return res
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Paragraph:
+		return []Element{paragraph(r.inlines(node.Children), tight)}
+	case *ast.Heading:
+		if node.IsTitleblock {
+			r.meta["title"] = Element{"MetaInlines", r.inlines(node.Children)}
+			return nil
+		}
+		a := attr(node.Attribute)
+		if a.ID == "" {
+			a.ID = node.HeadingID
+		}
+		if node.IsSpecial {
+			a.Classes = append(a.Classes, "unnumbered")
+		}
+		return []Element{{"Header", []interface{}{node.Level, a, r.inlines(node.Children)}}}
+	case *ast.BlockQuote:
+		return []Element{{"BlockQuote", r.blocks(node.Children, false)}}
+	case *ast.Aside:
+		a := attr(node.Attribute)
+		a.Classes = append([]string{"aside"}, a.Classes...)
+		return []Element{{"Div", []interface{}{a, r.blocks(node.Children, false)}}}
+	case *ast.Admonition:
+		return []Element{r.admonition(node)}
+	case *ast.FencedDiv:
+		a := attr(node.Attribute)
+		if node.Name != "" {
+			a.Classes = append([]string{node.Name}, a.Classes...)
+		}
+		return []Element{{"Div", []interface{}{a, r.blocks(node.Children, false)}}}
+	case *ast.List:
+		return r.list(node)
+	case *ast.ListItem:
+		return r.blocks(node.Children, tight)
+	case *ast.CodeBlock:
+		return []Element{r.codeBlock(node)}
+	case *ast.HTMLBlock:
+		return []Element{{"RawBlock", []interface{}{"html", string(bytes.TrimRight(node.Literal, "\n"))}}}
+	case *ast.HorizontalRule:
+		return []Element{{T: "HorizontalRule"}}
+	case *ast.MathBlock:
+		math := Element{"Math", []interface{}{Element{T: "DisplayMath"}, string(bytes.TrimSpace(node.Literal))}}
+		return []Element{paragraph([]Element{math}, tight)}
+	case *ast.Table:
+		return []Element{r.table(node, Attr{}, nil)}
+	case *ast.CaptionFigure:
+		return []Element{r.captionFigure(node)}
+	case *ast.Section:
+		return r.blocks(node.Children, false)
+	case *ast.DocumentMatter, *ast.Footnotes, *ast.TOC, *ast.FrontMatter:
+		// footnotes are written with their references, pandoc writes
+		// the table of contents with --toc
+		return nil
+	}
+	panic(fmt.Sprintf("Unknown block node %T", node))
+}
+
+func (r *Renderer) list(list *ast.List) []Element {
+	if list.IsFootnotesList {
+		return nil
+	}
+	if list.ListFlags&ast.ListTypeDefinition != 0 {
+		items := []interface{}{}
+		var term []Element
+		var defs [][]Element
+		for _, item := range list.Children {
+			if item.(*ast.ListItem).ListFlags&ast.ListTypeTerm != 0 {
+				if term != nil {
+					items = append(items, []interface{}{term, defs})
+				}
+				term = []Element{}
+				defs = [][]Element{}
+				for _, child := range item.GetChildren() {
+					if p, ok := child.(*ast.Paragraph); ok {
+						term = append(term, r.inlines(p.Children)...)
+					} else {
+						term = append(term, r.inlines([]ast.Node{child})...)
+					}
+				}
+				continue
+			}
+			if term == nil {
+				term, defs = []Element{}, [][]Element{}
+			}
+			defs = append(defs, r.blocks(item.GetChildren(), list.Tight))
+		}
+		if term != nil {
+			items = append(items, []interface{}{term, defs})
+		}
+		return []Element{{"DefinitionList", items}}
+	}
+
+	items := [][]Element{}
+	for _, child := range list.Children {
+		item := child.(*ast.ListItem)
+		blocks := r.blocks(item.Children, list.Tight)
+		if item.IsTask {
+			box := "☐"
+			if item.Checked {
+				box = "☒"
+			}
+			task := []Element{{"Str", box}, {T: "Space"}}
+			if len(blocks) > 0 && (blocks[0].T == "Plain" || blocks[0].T == "Para") {
+				blocks[0].C = append(task, blocks[0].C.([]Element)...)
+			} else {
+				blocks = append([]Element{paragraph(task[:1], list.Tight)}, blocks...)
+			}
+		}
+		items = append(items, blocks)
+	}
+	if list.ListFlags&ast.ListTypeOrdered == 0 {
+		return []Element{{"BulletList", items}}
+	}
+	start := list.Start
+	if start == 0 {
+		start = 1
+	}
+	delim := "Period"
+	if list.Delimiter == ')' {
+		delim = "OneParen"
+	}
+	attrs := []interface{}{start, Element{T: "Decimal"}, Element{T: delim}}
+	return []Element{{"OrderedList", []interface{}{attrs, items}}}
+}
+
+func (r *Renderer) codeBlock(node *ast.CodeBlock) Element {
+	a := attr(node.Attribute)
+	info := node.Info
+	if i := bytes.IndexAny(info, "\t {"); i >= 0 {
+		info = info[:i]
+	}
+	if len(info) > 0 {
+		a.Classes = append([]string{string(info)}, a.Classes...)
+	}
+	if m := node.Meta; m != nil {
+		if m.LineNumbers {
+			a.Classes = append(a.Classes, "numberLines")
+		}
+		if m.Start != 0 {
+			a.Keys = append(a.Keys, [2]string{"startFrom", strconv.Itoa(m.Start)})
+		}
+		keys := make([]string, 0, len(m.Attrs))
+		for k := range m.Attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			a.Keys = append(a.Keys, [2]string{k, string(m.Attrs[k])})
+		}
+	}
+	code := string(bytes.TrimSuffix(node.Literal, []byte("\n")))
+	return Element{"CodeBlock", []interface{}{a, code}}
+}
+
+// captionFigure returns a Table with the caption, or a Figure
+func (r *Renderer) captionFigure(node *ast.CaptionFigure) Element {
+	// the attribute is the one of the content
+	a := Attr{ID: node.HeadingID}
+	var content []ast.Node
+	var caption []Element
+	for _, child := range node.Children {
+		if c, ok := child.(*ast.Caption); ok {
+			caption = trimSpaces(r.inlines(c.Children))
+			continue
+		}
+		content = append(content, child)
+	}
+	if len(content) == 1 {
+		if table, ok := content[0].(*ast.Table); ok {
+			return r.table(table, a, caption)
+		}
+	}
+	return Element{"Figure", []interface{}{a, captionValue(caption), r.blocks(content, false)}}
+}
+
+// admonition returns a Div with the kind as class, starting with a Div
+// with the title, like pandoc's alerts extension
+func (r *Renderer) admonition(node *ast.Admonition) Element {
+	a := attr(node.Attribute)
+	a.Classes = append([]string{node.Kind.String()}, a.Classes...)
+	title := node.Title
+	if len(title) == 0 {
+		title = []byte(node.Kind.Title())
+	}
+	titleDiv := Element{"Div", []interface{}{Attr{Classes: []string{"title"}}, []Element{{"Para", words(title)}}}}
+	blocks := append([]Element{titleDiv}, r.blocks(node.Children, false)...)
+	return Element{"Div", []interface{}{a, blocks}}
+}
+
+func captionValue(caption []Element) []interface{} {
+	blocks := []Element{}
+	if len(caption) > 0 {
+		blocks = append(blocks, Element{"Plain", caption})
+	}
+	return []interface{}{nil, blocks}
+}
+
+func alignment(a ast.CellAlignFlags) Element {
+	switch a {
+	case ast.TableAlignmentLeft:
+		return Element{T: "AlignLeft"}
+	case ast.TableAlignmentRight:
+		return Element{T: "AlignRight"}
+	case ast.TableAlignmentCenter:
+		return Element{T: "AlignCenter"}
+	}
+	return Element{T: "AlignDefault"}
+}
+
+func (r *Renderer) table(node *ast.Table, a Attr, caption []Element) Element {
+	if node.Attribute != nil {
+		id := a.ID
+		a = attr(node.Attribute)
+		if id != "" {
+			a.ID = id
+		}
+	}
+	var head, foot []interface{}
+	bodies := []interface{}{}
+	colSpecs := []interface{}{}
+	for _, part := range node.Children {
+		rows := []interface{}{}
+		for _, row := range part.GetChildren() {
+			cells := []interface{}{}
+			for _, c := range row.GetChildren() {
+				cell := c.(*ast.TableCell)
+				span := cell.ColSpan
+				if span < 1 {
+					span = 1
+				}
+				content := []Element{}
+				if inlines := r.inlines(cell.Children); len(inlines) > 0 {
+					content = append(content, Element{"Plain", inlines})
+				}
+				cells = append(cells, []interface{}{Attr{}, alignment(cell.Align), 1, span, content})
+				if len(head) == 0 && len(bodies) == 0 && len(rows) == 0 {
+					for i := 0; i < span; i++ {
+						colSpecs = append(colSpecs, []interface{}{alignment(cell.Align), Element{T: "ColWidthDefault"}})
+					}
+				}
+			}
+			rows = append(rows, []interface{}{Attr{}, cells})
+		}
+		switch part.(type) {
+		case *ast.TableHeader:
+			head = []interface{}{Attr{}, rows}
+		case *ast.TableBody:
+			bodies = append(bodies, []interface{}{Attr{}, 0, []interface{}{}, rows})
+		case *ast.TableFooter:
+			foot = []interface{}{Attr{}, rows}
+		}
+	}
+	if head == nil {
+		head = []interface{}{Attr{}, []interface{}{}}
+	}
+	if foot == nil {
+		foot = []interface{}{Attr{}, []interface{}{}}
+	}
+	return Element{"Table", []interface{}{a, captionValue(caption), colSpecs, head, bodies, foot}}
+}
+
+// inlines converts inline nodes, merging adjacent strings
+func (r *Renderer) inlines(nodes []ast.Node) []Element {
+	res := []Element{}
+	for _, node := range nodes {
+		for _, el := range r.inline(node) {
+			if n := len(res); n > 0 && el.T == "Str" && res[n-1].T == "Str" {
+				res[n-1].C = res[n-1].C.(string) + el.C.(string)
+				continue
+			}
+			res = append(res, el)
+		}
+	}
+	return res
+}
+
+func (r *Renderer) inline(node ast.Node) []Element {
+	if r.Opts.NodeHook != nil {
+		if res, ok := r.Opts.NodeHook(node); ok {
+			return res
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Text:
+		return words(node.Literal)
+	case *ast.Softbreak:
+		return []Element{{T: "SoftBreak"}}
+	case *ast.Hardbreak:
+		return []Element{{T: "LineBreak"}}
+	case *ast.NonBlockingSpace:
+		return []Element{{"Str", " "}}
+	case *ast.Emph:
+		return []Element{{"Emph", r.inlines(node.Children)}}
+	case *ast.Strong:
+		return []Element{{"Strong", r.inlines(node.Children)}}
+	case *ast.Del:
+		return []Element{{"Strikeout", r.inlines(node.Children)}}
+	case *ast.Subscript:
+		return []Element{{"Subscript", words(node.Literal)}}
+	case *ast.Superscript:
+		return []Element{{"Superscript", words(node.Literal)}}
+	case *ast.Code:
+		return []Element{{"Code", []interface{}{Attr{}, string(node.Literal)}}}
+	case *ast.Math:
+		return []Element{{"Math", []interface{}{Element{T: "InlineMath"}, string(node.Literal)}}}
+	case *ast.HTMLSpan:
+		return []Element{{"RawInline", []interface{}{"html", string(node.Literal)}}}
+	case *ast.Link:
+		if node.NoteID != 0 {
+			var blocks []Element
+			if node.Footnote != nil {
+				blocks = r.blocks(node.Footnote.GetChildren(), false)
+			}
+			return []Element{{"Note", blocks}}
+		}
+		target := []string{string(node.Destination), string(node.Title)}
+		return []Element{{"Link", []interface{}{Attr{}, r.inlines(node.Children), target}}}
+	case *ast.Image:
+		target := []string{string(node.Destination), string(node.Title)}
+		return []Element{{"Image", []interface{}{Attr{}, r.inlines(node.Children), target}}}
+	case *ast.WikiLink:
+		a := Attr{Classes: []string{"wikilink"}}
+		if node.Missing {
+			a.Classes = append(a.Classes, "wikilink-missing")
+		}
+		target := []string{string(node.Destination), ""}
+		return []Element{{"Link", []interface{}{a, r.inlines(node.Children), target}}}
+	case *ast.CrossReference:
+		target := []string{"#" + string(node.Destination), ""}
+		return []Element{{"Link", []interface{}{Attr{}, r.inlines(node.Children), target}}}
+	case *ast.Citation:
+		return []Element{r.citation(node)}
+	case *ast.Index:
+		a := Attr{ID: node.ID, Classes: []string{"index"}}
+		a.Keys = append(a.Keys, [2]string{"item", string(node.Item)})
+		if len(node.Subitem) > 0 {
+			a.Keys = append(a.Keys, [2]string{"subitem", string(node.Subitem)})
+		}
+		if node.Primary {
+			a.Keys = append(a.Keys, [2]string{"primary", "true"})
+		}
+		return []Element{{"Span", []interface{}{a, []Element{}}}}
+	case *ast.Callout:
+		a := Attr{Classes: []string{"callout"}}
+		return []Element{{"Span", []interface{}{a, []Element{{"Str", string(node.ID)}}}}}
+	}
+	if isBlock(node) {
+		// e.g. a paragraph in a footnote or a definition term
+		return r.inlines(node.GetChildren())
+	}
+	panic(fmt.Sprintf("Unknown inline node %T", node))
+}
+
+// citation returns a Cite. Suppressed citations don't show the author,
+// the others are normal citations.
+func (r *Renderer) citation(node *ast.Citation) Element {
+	citations := []Citation{}
+	var text bytes.Buffer
+	text.WriteString("[")
+	for i, dest := range node.Destination {
+		c := Citation{ID: string(dest), Prefix: []Element{}, Suffix: []Element{}, Mode: Element{T: "NormalCitation"}}
+		if i > 0 {
+			text.WriteString("; ")
+		}
+		text.WriteString("@")
+		if i < len(node.Type) {
+			switch node.Type[i] {
+			case ast.CitationTypeSuppressed:
+				c.Mode = Element{T: "SuppressAuthor"}
+				text.WriteString("-")
+			case ast.CitationTypeNormative:
+				text.WriteString("!")
+			}
+		}
+		text.Write(dest)
+		if i < len(node.Suffix) && len(node.Suffix[i]) > 0 {
+			c.Suffix = append(words([]byte(", ")), words(node.Suffix[i])...)
+			text.WriteString(", ")
+			text.Write(node.Suffix[i])
+		}
+		citations = append(citations, c)
+	}
+	text.WriteString("]")
+	return Element{"Cite", []interface{}{citations, words(text.Bytes())}}
+}
+
+// words splits text in Str, Space and SoftBreak
+func words(text []byte) []Element {
+	res := []Element{}
+	for len(text) > 0 {
+		i := bytes.IndexAny(text, " \t\n")
+		if i < 0 {
+			i = len(text)
+		}
+		if i > 0 {
+			res = append(res, Element{"Str", string(text[:i])})
+			text = text[i:]
+			continue
+		}
+		sp := Element{T: "Space"}
+		for len(text) > 0 && (text[0] == ' ' || text[0] == '\t' || text[0] == '\n') {
+			if text[0] == '\n' {
+				sp = Element{T: "SoftBreak"}
+			}
+			text = text[1:]
+		}
+		if n := len(res); n > 0 && (res[n-1].T == "Space" || res[n-1].T == "SoftBreak") {
+			if sp.T == "SoftBreak" {
+				res[n-1] = sp
+			}
+			continue
+		}
+		res = append(res, sp)
+	}
+	return res
+}
+
+// trimSpaces removes spaces at the start and at the end of inlines
+func trimSpaces(inlines []Element) []Element {
+	isSpace := func(el Element) bool {
+		return el.T == "Space" || el.T == "SoftBreak"
+	}
+	for len(inlines) > 0 && isSpace(inlines[0]) {
+		inlines = inlines[1:]
+	}
+	for len(inlines) > 0 && isSpace(inlines[len(inlines)-1]) {
+		inlines = inlines[:len(inlines)-1]
+	}
+	return inlines
+}
+
+// Attr is the Pandoc attribute of an element: an identifier, classes and
+// key/value pairs.
+type Attr struct {
+	ID      string
+	Classes []string
+	Keys    [][2]string
+}
+
+// MarshalJSON writes a as [id, [classes], [[key, value]]]
+func (a Attr) MarshalJSON() ([]byte, error) {
+	classes := a.Classes
+	if classes == nil {
+		classes = []string{}
+	}
+	keys := a.Keys
+	if keys == nil {
+		keys = [][2]string{}
+	}
+	return json.Marshal([]interface{}{a.ID, classes, keys})
+}
+
+func attr(a *ast.Attribute) Attr {
+	if a == nil {
+		return Attr{}
+	}
+	res := Attr{ID: string(a.ID)}
+	for _, c := range a.Classes {
+		res.Classes = append(res.Classes, string(c))
+	}
+	keys := make([]string, 0, len(a.Attrs))
+	for k := range a.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		res.Keys = append(res.Keys, [2]string{k, string(a.Attrs[k])})
+	}
+	return res
+}
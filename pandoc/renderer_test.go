@@ -0,0 +1,94 @@
+package pandoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+const extensions = parser.CommonExtensions | parser.Footnotes | parser.Mmark |
+	parser.Attributes | parser.SuperSubscript | parser.TaskLists | parser.OrderedListStart | parser.Titleblock
+
+func render(input []byte, opts RendererOptions) []byte {
+	p := parser.NewWithExtensions(extensions)
+	doc := p.Parse(input)
+	return markdown.Render(doc, NewRenderer(opts))
+}
+
+// TestFixtures compares the output with testdata/*.json. They can be checked
+// with pandoc -f json testdata/basic.json
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		input, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, err := ioutil.ReadFile(strings.TrimSuffix(file, ".md") + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, exp); err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		buf.WriteString("\n")
+		got := render(input, RendererOptions{})
+		if !bytes.Equal(got, buf.Bytes()) {
+			t.Errorf("%s:\nExpected: %s\nGot:      %s", file, buf.Bytes(), got)
+		}
+	}
+}
+
+func TestMeta(t *testing.T) {
+	opts := RendererOptions{Meta: map[string]string{"author": "Me"}}
+	got := string(render([]byte("x\n"), opts))
+	exp := `{"pandoc-api-version":[1,23,1],"meta":{"author":{"t":"MetaString","c":"Me"}},"blocks":[{"t":"Para","c":[{"t":"Str","c":"x"}]}]}` + "\n"
+	if got != exp {
+		t.Errorf("\nExpected: %s\nGot:      %s", exp, got)
+	}
+}
+
+func TestNodeHook(t *testing.T) {
+	opts := RendererOptions{
+		NodeHook: func(node ast.Node) ([]Element, bool) {
+			if _, ok := node.(*ast.Code); ok {
+				return []Element{{"Str", "code"}}, true
+			}
+			return nil, false
+		},
+	}
+	got := string(render([]byte("a `b`\n"), opts))
+	exp := `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Space"},{"t":"Str","c":"code"}]}]}` + "\n"
+	if got != exp {
+		t.Errorf("\nExpected: %s\nGot:      %s", exp, got)
+	}
+}
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write(d []byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestRenderNodeWriteError(t *testing.T) {
+	doc := parser.New().Parse([]byte("text\n"))
+	r := NewRenderer(RendererOptions{})
+	if status := r.RenderNode(brokenWriter{}, doc, true); status != ast.Terminate {
+		t.Errorf("RenderNode() returned %v, expected Terminate", status)
+	}
+	if err := markdown.RenderTo(brokenWriter{}, doc, r); err == nil {
+		t.Errorf("RenderTo() didn't return the write error")
+	}
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// alert returns the admonition of a block quote with content data, if its
+// first line is [!NOTE], [!TIP], [!IMPORTANT], [!WARNING] or [!CAUTION],
+// and the content after that line. Text after the kind is a custom title.
+//
+//	> [!WARNING] Read this first
+//	> It's important.
+func (p *Parser) alert(data []byte) (*ast.Admonition, []byte) {
+	end := skipUntilChar(data, 0, '\n')
+	line := bytes.TrimSpace(data[:end])
+	if len(line) < 4 || line[0] != '[' || line[1] != '!' {
+		return nil, nil
+	}
+	i := bytes.IndexByte(line, ']')
+	if i < 0 {
+		return nil, nil
+	}
+	kind := ast.AdmonitionKindFromString(string(line[2:i]))
+	if kind == ast.AdmonitionNone {
+		return nil, nil
+	}
+	block := &ast.Admonition{Kind: kind}
+	if title := bytes.TrimSpace(line[i+1:]); len(title) > 0 {
+		block.Title = title
+	}
+	return block, data[skipCharN(data, end, '\n', 1):]
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestAlerts(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  ast.AdmonitionKinds // AdmonitionNone if it's a block quote
+		title string
+	}{
+		{"> [!NOTE]\n> text\n", ast.AdmonitionNote, ""},
+		{"> [!TIP]\n> text\n", ast.AdmonitionTip, ""},
+		{"> [!IMPORTANT]\n> text\n", ast.AdmonitionImportant, ""},
+		{"> [!Warning]\n> text\n", ast.AdmonitionWarning, ""},
+		{"> [!caution]  \n> text\n", ast.AdmonitionCaution, ""},
+		{"> [!NOTE] Read *this*\n> text\n", ast.AdmonitionNote, "Read *this*"},
+		{"> [!NOTE]\n", ast.AdmonitionNote, ""},
+
+		{"> [!OTHER]\n> text\n", ast.AdmonitionNone, ""},
+		{"> [NOTE]\n> text\n", ast.AdmonitionNone, ""},
+		{"> text\n> [!NOTE]\n", ast.AdmonitionNone, ""},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions | Alerts)
+		doc := p.Parse([]byte(test.input))
+		node := doc.GetChildren()[0]
+		alert, ok := node.(*ast.Admonition)
+		if test.kind == ast.AdmonitionNone {
+			if _, isQuote := node.(*ast.BlockQuote); !isQuote {
+				t.Errorf("%q: expected a block quote, got %T", test.input, node)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%q: expected an admonition, got %T", test.input, node)
+			continue
+		}
+		if alert.Kind != test.kind || string(alert.Title) != test.title {
+			t.Errorf("%q: got %s %q, expected %s %q", test.input, alert.Kind, alert.Title, test.kind, test.title)
+		}
+	}
+}
+
+func TestAlertsDisabled(t *testing.T) {
+	p := NewWithExtensions(CommonExtensions)
+	doc := p.Parse([]byte("> [!NOTE]\n> text\n"))
+	if _, ok := doc.GetChildren()[0].(*ast.BlockQuote); !ok {
+		t.Errorf("expected a block quote, got %T", doc.GetChildren()[0])
+	}
+}
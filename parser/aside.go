@@ -1,8 +1,6 @@
 package parser
 
 import (
-	"bytes"
-
 	"github.com/gomarkdown/markdown/ast"
 )
 
@@ -36,7 +34,7 @@ func (p *Parser) terminateAside(data []byte, beg, end int) bool {
 
 // parse a aside fragment
 func (p *Parser) aside(data []byte) int {
-	var raw bytes.Buffer
+	raw := p.newSourceBuffer()
 	beg, end := 0, 0
 	// identical to quote
 	for beg < len(data) {
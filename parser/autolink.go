@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Extended autolinks
+//
+// With the ExtendedAutolink extension links are recognized the way GitHub
+// does it: text starting with www., http://, https:// or ftp:// and email
+// addresses become links, see https://github.github.com/gfm/#autolinks-extension-
+//
+// URLs are found by inline callbacks. Email addresses can't be, the local
+// part is already consumed as text when the @ is seen, so they are split out
+// of the text nodes as those are added.
+
+var extendedAutolinkSchemes = [][]byte{
+	[]byte("http://"),
+	[]byte("https://"),
+	[]byte("ftp://"),
+}
+
+// extendedAutolink is the inline callback for www., http://, https://
+// and ftp:// links.
+func extendedAutolink(p *Parser, data []byte, offset int) (int, ast.Node) {
+	if p.InsideLink {
+		return 0, nil
+	}
+	d := data[offset:]
+	var prefix []byte
+	end := 0
+	if hasPrefixCaseInsensitive(d, []byte("www.")) {
+		// www. links must follow whitespace, the start of the line or one
+		// of the delimiters *, _, ~ and (
+		if offset > 0 {
+			c := data[offset-1]
+			if !IsSpace(c) && c != '*' && c != '_' && c != '~' && c != '(' {
+				return 0, nil
+			}
+		}
+		end = checkDomain(d, false)
+		if end == 0 {
+			return 0, nil
+		}
+		prefix = []byte("http://")
+	} else {
+		if offset > 0 && IsLetter(data[offset-1]) {
+			return 0, nil
+		}
+		for _, scheme := range extendedAutolinkSchemes {
+			if hasPrefixCaseInsensitive(d, scheme) {
+				if n := checkDomain(d[len(scheme):], true); n > 0 {
+					end = len(scheme) + n
+				}
+				break
+			}
+		}
+		if end == 0 {
+			return 0, nil
+		}
+	}
+
+	for end < len(d) && !IsSpace(d[end]) && d[end] != '<' {
+		end++
+	}
+	end = autolinkDelim(d, end)
+	if end == 0 || insideAnchor(data[:offset]) {
+		return 0, nil
+	}
+
+	text := d[:end]
+	link := &ast.Link{
+		Destination: append(append([]byte{}, prefix...), text...),
+	}
+	ast.AppendChild(link, newTextNode(text))
+	return end, link
+}
+
+// isHostChar returns true if the rune at the start of d can be a part of
+// a domain name and its size.
+func isHostChar(d []byte) (bool, int) {
+	if d[0] < utf8.RuneSelf {
+		return IsAlnum(d[0]), 1
+	}
+	r, size := utf8.DecodeRune(d)
+	return !unicode.IsSpace(r) && !unicode.IsPunct(r), size
+}
+
+// checkDomain returns the length of the domain at the start of d, or 0
+// if there is no valid domain. Unless allowShort is true the domain must
+// have at least one period.
+func checkDomain(d []byte, allowShort bool) int {
+	// underscores are not allowed in the last two segments
+	periods, uscore1, uscore2 := 0, 0, 0
+	i := 0
+loop:
+	for i < len(d) {
+		switch c := d[i]; {
+		case c == '\\' && i+2 < len(d):
+			// skip the escaped char
+			i += 2
+		case c == '_':
+			uscore2++
+			i++
+		case c == '.':
+			uscore1 = uscore2
+			uscore2 = 0
+			periods++
+			i++
+		case c == '-':
+			i++
+		default:
+			ok, size := isHostChar(d[i:])
+			if !ok {
+				break loop
+			}
+			i += size
+		}
+	}
+	if (uscore1 > 0 || uscore2 > 0) && periods <= 10 {
+		return 0
+	}
+	if i == 0 || (periods == 0 && !allowShort) {
+		return 0
+	}
+	return i
+}
+
+// autolinkDelim returns the end of an autolink in d[:end] once trailing
+// punctuation and unbalanced closing parens are removed.
+func autolinkDelim(d []byte, end int) int {
+	if i := bytes.IndexByte(d[:end], '<'); i >= 0 {
+		end = i
+	}
+	for end > 0 {
+		c := d[end-1]
+		switch c {
+		case ')':
+			// strip the paren only if it isn't balanced within the link
+			opening, closing := 0, 0
+			for _, c := range d[:end] {
+				if c == '(' {
+					opening++
+				} else if c == ')' {
+					closing++
+				}
+			}
+			if closing <= opening {
+				return end
+			}
+			end--
+		case '?', '!', '.', ',', ':', '*', '_', '~', '\'', '"':
+			end--
+		case ';':
+			// an entity-like &name; at the end is excluded as a whole
+			i := end - 2
+			for i >= 0 && IsLetter(d[i]) {
+				i--
+			}
+			if i >= 0 && i < end-2 && d[i] == '&' {
+				end = i
+			} else {
+				end--
+			}
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// appendText adds data[beg:] as a text node to block. With ExtendedAutolink
+// email addresses in it are turned into links.
+func (p *Parser) appendText(block ast.Node, data []byte, beg int) {
+	if p.extensions&ExtendedAutolink != 0 && !p.InsideLink {
+		for at := beg; at < len(data); at++ {
+			if data[at] != '@' {
+				continue
+			}
+			start, end, dest := findEmail(data[beg:], at-beg)
+			if end == 0 || insideAnchor(data[:beg+start]) {
+				continue
+			}
+			start += beg
+			end += beg
+			p.appendTextNode(block, data[beg:start])
+			link := &ast.Link{Destination: dest}
+			p.setRange(link, data[start:end])
+			ast.AppendChild(link, newTextNode(data[start:end]))
+			ast.AppendChild(block, link)
+			beg = end
+			at = end - 1
+		}
+	}
+	p.appendTextNode(block, data[beg:])
+}
+
+// insideAnchor returns true if the last <a> or </a> tag in before opens
+// an anchor, so text that follows is already a link.
+func insideAnchor(before []byte) bool {
+	isTagEnd := func(d []byte) bool {
+		return len(d) == 0 || d[0] == '>' || IsSpace(d[0])
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		if before[i] != '<' || i+2 > len(before) {
+			continue
+		}
+		tag := before[i+1:]
+		if tag[0] == '/' && len(tag) > 1 && (tag[1] == 'a' || tag[1] == 'A') && isTagEnd(tag[2:]) {
+			return false
+		}
+		if (tag[0] == 'a' || tag[0] == 'A') && isTagEnd(tag[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) appendTextNode(block ast.Node, d []byte) {
+	text := newTextNode(d)
+	p.countNodes(1)
+	p.setRange(text, d)
+	ast.AppendChild(block, text)
+}
+
+// findEmail checks for an email address around the @ at d[at].
+// It returns its start and end in d and the link destination; end is 0
+// if there is no address.
+func findEmail(d []byte, at int) (int, int, []byte) {
+	// scan backward over the local part and an optional mailto: or xmpp:
+	start := at
+	var scheme []byte
+	for start > 0 {
+		c := d[start-1]
+		if IsAlnum(c) || c == '.' || c == '+' || c == '-' || c == '_' {
+			start--
+			continue
+		}
+		if c == ':' && start < at {
+			if s := emailScheme(d[:start]); s != nil {
+				start -= len(s)
+				scheme = s
+			}
+		}
+		break
+	}
+	if start == at || (scheme != nil && start+len(scheme) == at) {
+		return 0, 0, nil
+	}
+
+	// the domain: at least one period, no more @ and it ends with a letter
+	xmpp := bytes.Equal(scheme, []byte("xmpp:"))
+	ats, periods := 0, 0
+	end := at
+loop:
+	for ; end < len(d); end++ {
+		switch c := d[end]; {
+		case IsAlnum(c):
+		case c == '@':
+			ats++
+		case c == '.' && end+1 < len(d) && IsAlnum(d[end+1]):
+			periods++
+		case c == '/' && xmpp:
+		case c == '-' || c == '_':
+		default:
+			break loop
+		}
+	}
+	if end-at < 2 || ats != 1 || periods == 0 || !(IsLetter(d[end-1]) || d[end-1] == '.') {
+		return 0, 0, nil
+	}
+	end = autolinkDelim(d, end)
+	if end <= at {
+		return 0, 0, nil
+	}
+	if scheme != nil {
+		return start, end, d[start:end]
+	}
+	dest := append([]byte("mailto:"), d[start:end]...)
+	return start, end, dest
+}
+
+// emailScheme returns the scheme d ends with, if it is mailto: or xmpp:
+// that doesn't follow a letter or digit.
+func emailScheme(d []byte) []byte {
+	for _, s := range [][]byte{[]byte("mailto:"), []byte("xmpp:")} {
+		if !bytes.HasSuffix(d, s) {
+			continue
+		}
+		if n := len(d) - len(s); n == 0 || !IsAlnum(d[n-1]) {
+			return s
+		}
+	}
+	return nil
+}
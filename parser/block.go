@@ -104,20 +104,33 @@ func sanitizeHeadingID(text string) string {
 // Note: this function and many that it calls assume that
 // the input buffer ends with a newline.
 func (p *Parser) Block(data []byte) {
+	// checked before the maximum depth below, which would silently drop
+	// blocks nested deeper than it
+	p.checkNesting(p.blockDepth)
 	// this is called recursively: enforce a maximum depth
 	if p.nesting >= p.maxNesting {
 		return
 	}
 	p.nesting++
+	p.blockDepth++
+
+	// blocks added while parsing a construct get the range of input it
+	// consumed, unless they set a more precise one themselves
+	mark := len(p.newBlocks)
+	start := data
 
 	// parse out one block-level construct at a time
 	for len(data) > 0 {
+		p.checkContext()
+		p.endBlocks(mark, start, data)
+
 		// attributes that can be specific before a block element:
 		//
 		// {#id .class1 .class2 key="value"}
 		if p.extensions&Attributes != 0 {
 			data = p.attribute(data)
 		}
+		start = data
 
 		if p.extensions&Includes != 0 {
 			f := p.readInclude
@@ -133,11 +146,15 @@ func (p *Parser) Block(data []byte) {
 				// that the caption will be part of the include text. (+1 to skip newline)
 				for _, caption := range []string{captionFigure, captionTable, captionQuote} {
 					if _, _, capcon := p.caption(data[consumed+1:], []byte(caption)); capcon > 0 {
-						included = append(included, data[consumed+1:consumed+1+capcon]...)
+						buf := p.newSourceBuffer()
+						buf.Write(included)
+						buf.Write(data[consumed+1 : consumed+1+capcon])
+						included = buf.Bytes()
 						consumed += 1 + capcon
 						break // there can only be 1 caption.
 					}
 				}
+				p.checkIncludeDepth(len(p.includeStack.stack) + 1)
 				p.includeStack.Push(path)
 				p.Block(included)
 				p.includeStack.Pop()
@@ -250,6 +267,18 @@ func (p *Parser) Block(data []byte) {
 			}
 		}
 
+		// fenced div:
+		//
+		// ::: warning
+		// Be careful.
+		// :::
+		if p.extensions&FencedDivs != 0 {
+			if i := p.fencedDiv(data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// horizontal rule:
 		//
 		// ------
@@ -326,7 +355,7 @@ func (p *Parser) Block(data []byte) {
 			delim := byte('.')
 			if i > 2 {
 				if p.extensions&OrderedListStart != 0 {
-					s := string(data[:i-2])
+					s := string(bytes.TrimSpace(data[:i-2]))
 					start, _ = strconv.Atoi(s)
 					if start == 1 {
 						start = 0
@@ -370,12 +399,24 @@ func (p *Parser) Block(data []byte) {
 			}
 		}
 
+		// table of contents placeholder:
+		//
+		// [TOC] or {{toc}}
+		if p.extensions&TOCPlaceholder != 0 {
+			if i := p.tocPlaceholder(data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// anything else must look like a normal paragraph
 		// note: this finds underlined headings, too
 		idx := p.paragraph(data)
 		data = data[idx:]
 	}
+	p.endBlocks(mark, start, data)
 
+	p.blockDepth--
 	p.nesting--
 }
 
@@ -974,6 +1015,14 @@ func syntaxRange(data []byte, iout *int) (int, int) {
 			return 0, 0
 		}
 
+		// code block metadata without a language, e.g. {linenos=true},
+		// keeps its braces so it's not taken for the language
+		if bytes.IndexByte(data[syntaxStart:i], '=') >= 0 {
+			i++
+			*iout = i
+			return syntaxStart - 1, syn + 2
+		}
+
 		// strip all whitespace at the beginning and the end
 		// of the {} block
 		for syn > 0 && IsSpace(data[syntaxStart]) {
@@ -1052,10 +1101,12 @@ func (p *Parser) fencedCodeBlock(data []byte, doRender bool) int {
 		caption := &ast.Caption{}
 		figure.HeadingID = id
 		p.Inline(caption, captionContent)
+		p.setRange(caption, captionContent)
 
 		p.AddBlock(figure)
 		codeBlock.AsLeaf().Attribute = figure.AsContainer().Attribute
 		p.addChild(codeBlock)
+		p.setRange(codeBlock, data[:beg])
 		finalizeCodeBlock(codeBlock)
 		p.addChild(caption)
 		p.Finalize(figure)
@@ -1185,6 +1236,7 @@ func finalizeCodeBlock(code *ast.CodeBlock) {
 		firstLine := c[:newlinePos]
 		rest := c[newlinePos+1:]
 		code.Info = unescapeString(bytes.Trim(firstLine, "\n"))
+		code.Meta = codeBlockMeta(code.Info)
 		code.Literal = rest
 	} else {
 		code.Literal = c
@@ -1222,7 +1274,7 @@ func (p *Parser) terminateBlockquote(data []byte, beg, end int) bool {
 
 // parse a blockquote fragment
 func (p *Parser) quote(data []byte) int {
-	var raw bytes.Buffer
+	raw := p.newSourceBuffer()
 	beg, end := 0, 0
 	fenceMarker := ""
 	for beg < len(data) {
@@ -1255,9 +1307,19 @@ func (p *Parser) quote(data []byte) int {
 		beg = end
 	}
 
+	content := raw.Bytes()
+	if p.extensions&Alerts != 0 {
+		if block, rest := p.alert(content); block != nil {
+			p.AddBlock(block)
+			p.Block(rest)
+			p.Finalize(block)
+			return end
+		}
+	}
+
 	if p.extensions&Mmark == 0 {
 		block := p.AddBlock(&ast.BlockQuote{})
-		p.Block(raw.Bytes())
+		p.Block(content)
 		p.Finalize(block)
 		return end
 	}
@@ -1267,12 +1329,14 @@ func (p *Parser) quote(data []byte) int {
 		caption := &ast.Caption{}
 		figure.HeadingID = id
 		p.Inline(caption, captionContent)
+		p.setRange(caption, captionContent)
 
 		p.AddBlock(figure) // this discard any attributes
 		block := &ast.BlockQuote{}
 		block.AsContainer().Attribute = figure.AsContainer().Attribute
 		p.addChild(block)
-		p.Block(raw.Bytes())
+		p.setRange(block, data[:backChar(data, end, '\n')])
+		p.Block(content)
 		p.Finalize(block)
 
 		p.addChild(caption)
@@ -1284,7 +1348,7 @@ func (p *Parser) quote(data []byte) int {
 	}
 
 	block := p.AddBlock(&ast.BlockQuote{})
-	p.Block(raw.Bytes())
+	p.Block(content)
 	p.Finalize(block)
 
 	return end
@@ -1420,6 +1484,30 @@ func (p *Parser) dliPrefix(data []byte) int {
 }
 */
 
+// returns the length of a task marker, [ ], [x] or [X] followed by
+// white-space, at the start of a list item
+func taskMarker(data []byte) int {
+	if len(data) < 3 || data[0] != '[' || data[2] != ']' {
+		return 0
+	}
+	if data[1] != ' ' && data[1] != 'x' && data[1] != 'X' {
+		return 0
+	}
+	if len(data) > 3 && !IsSpace(data[3]) {
+		return 0
+	}
+	return 3
+}
+
+// skipTaskSpace skips the spaces and tabs after a task marker, up to the
+// end of the line, which an empty task item ends with
+func skipTaskSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	return i
+}
+
 // parse ordered or unordered list block
 func (p *Parser) list(data []byte, flags ast.ListType, start int, delim byte) int {
 	i := 0
@@ -1550,6 +1638,15 @@ func (p *Parser) listItem(data []byte, flags *ast.ListType) int {
 	// skip leading whitespace on first line
 	i = skipChar(data, i, ' ')
 
+	isTask, checked := false, false
+	if p.extensions&TaskLists != 0 && !isDefinitionList {
+		if n := taskMarker(data[i:]); n > 0 {
+			isTask = true
+			checked = data[i+1] != ' '
+			i = skipTaskSpace(data, i+n)
+		}
+	}
+
 	// find the end of the line
 	line := i
 	for i > 0 && i < len(data) && data[i-1] != '\n' {
@@ -1557,7 +1654,7 @@ func (p *Parser) listItem(data []byte, flags *ast.ListType) int {
 	}
 
 	// get working buffer
-	var raw bytes.Buffer
+	raw := p.newSourceBuffer()
 
 	// put the first line into the working buffer
 	raw.Write(data[line:i])
@@ -1749,8 +1846,11 @@ gatherlines:
 		Tight:      false,
 		BulletChar: bulletChar,
 		Delimiter:  delimiter,
+		IsTask:     isTask,
+		Checked:    checked,
 	}
 	p.AddBlock(listItem)
+	p.setRange(listItem, data[:backChar(data, line, '\n')])
 
 	// render the contents of the list item
 	if *flags&ast.ListItemContainsBlock != 0 && *flags&ast.ListTypeTerm == 0 {
@@ -1770,6 +1870,7 @@ gatherlines:
 			para.Content = rawBytes
 		}
 		p.addChild(para)
+		p.setRange(para, bytes.TrimRight(para.Content, "\n"))
 		if sublist > 0 {
 			p.Block(rawBytes[sublist:])
 		}
@@ -1799,6 +1900,7 @@ func (p *Parser) renderParagraph(data []byte) {
 	para := &ast.Paragraph{}
 	para.Content = data[beg:end]
 	p.AddBlock(para)
+	p.setRange(para, para.Content)
 }
 
 // blockMath handle block surround with $$
@@ -1853,7 +1955,7 @@ func (p *Parser) paragraph(data []byte) int {
 		if n := IsEmpty(current); n > 0 {
 			// did this blank line followed by a definition list item?
 			if p.extensions&DefinitionLists != 0 {
-				if i < len(data)-1 && data[i+1] == ':' {
+				if i < len(data)-1 && data[i+1] == ':' && !p.isFencedDivFence(data[i+1:]) {
 					listLen := p.list(data[prev:], ast.ListTypeDefinition, 0, '.')
 					if listLen > 0 {
 						return prev + listLen
@@ -1889,7 +1991,9 @@ func (p *Parser) paragraph(data []byte) int {
 				p.AddBlock(block)
 
 				// find the end of the underline
-				return skipUntilChar(data, i, '\n')
+				end := skipUntilChar(data, i, '\n')
+				p.setRange(block, data[prev:end])
+				return end
 			}
 		}
 
@@ -1,6 +1,10 @@
 package parser
 
-import "github.com/gomarkdown/markdown/ast"
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
 
 // check if the specified position is preceded by an odd number of backslashes
 func isBackslashEscaped(data []byte, i int) bool {
@@ -12,7 +16,8 @@ func isBackslashEscaped(data []byte, i int) bool {
 }
 
 func (p *Parser) tableRow(data []byte, columns []ast.CellAlignFlags, header bool) {
-	p.AddBlock(&ast.TableRow{})
+	row := p.AddBlock(&ast.TableRow{})
+	p.setRange(row, bytes.TrimRight(data, "\n"))
 	col := 0
 
 	i := skipChar(data, 0, '|')
@@ -64,6 +69,7 @@ func (p *Parser) tableRow(data []byte, columns []ast.CellAlignFlags, header bool
 			colspans--
 		} else {
 			p.AddBlock(block)
+			p.setRange(block, block.Content)
 		}
 
 		if colspan > 0 {
@@ -310,6 +316,7 @@ func (p *Parser) table(data []byte) int {
 	if captionContent, id, consumed := p.caption(data[i:], []byte(captionTable)); consumed > 0 {
 		caption := &ast.Caption{}
 		p.Inline(caption, captionContent)
+		p.setRange(caption, captionContent)
 
 		// Some switcheroo to re-insert the parsed table as a child of the captionfigure.
 		figure := &ast.CaptionFigure{}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// codeBlockMeta parses the metadata in braces after the language in the
+// info string of a fenced code block, e.g. `go {linenos=true start=10}`.
+// start and linenostart are the same, and hl_lines can be given in
+// brackets, e.g. `go {hl_lines=[2,4-5]}`. It returns nil if there is none
+// or it's not valid.
+func codeBlockMeta(info []byte) *ast.CodeBlockMeta {
+	info = bytes.TrimRight(info, " \t")
+	start := bytes.IndexByte(info, '{')
+	if start < 0 || info[len(info)-1] != '}' {
+		return nil
+	}
+	// the language in braces, e.g. ```{go}, has no metadata
+	if start == 0 && bytes.IndexByte(info, '=') < 0 {
+		return nil
+	}
+
+	meta := &ast.CodeBlockMeta{}
+	d := info[start+1 : len(info)-1]
+	for len(d) > 0 {
+		d = bytes.TrimLeft(d, " \t,")
+		if len(d) == 0 {
+			break
+		}
+		i := 0
+		for i < len(d) && d[i] != '=' && d[i] != ' ' && d[i] != '\t' && d[i] != ',' {
+			i++
+		}
+		key := string(d[:i])
+		if key == "" {
+			return nil
+		}
+		d = d[i:]
+		val := []byte("true")
+		if len(d) > 0 && d[0] == '=' {
+			d = d[1:]
+			var ok bool
+			if val, d, ok = metaValue(d); !ok {
+				return nil
+			}
+		}
+
+		switch key {
+		case "linenos":
+			meta.LineNumbers = string(val) != "false"
+		case "start", "linenostart":
+			n, err := strconv.Atoi(string(val))
+			if err != nil {
+				return nil
+			}
+			meta.Start = n
+		case "hl_lines":
+			ranges, err := ParseLineRanges(val)
+			if err != nil {
+				return nil
+			}
+			meta.Highlight = ranges
+		default:
+			if meta.Attrs == nil {
+				meta.Attrs = map[string][]byte{}
+			}
+			meta.Attrs[key] = val
+		}
+	}
+	return meta
+}
+
+// metaValue returns the (optionally quoted or bracketed) value at the start
+// of d and the rest of d.
+func metaValue(d []byte) ([]byte, []byte, bool) {
+	if len(d) > 0 && (d[0] == '"' || d[0] == '\'' || d[0] == '[') {
+		closing := d[0]
+		if closing == '[' {
+			closing = ']'
+		}
+		end := bytes.IndexByte(d[1:], closing)
+		if end < 0 {
+			return nil, nil, false
+		}
+		return d[1 : end+1], d[end+2:], true
+	}
+	i := 0
+	for i < len(d) && d[i] != ' ' && d[i] != '\t' && d[i] != ',' {
+		i++
+	}
+	return d[:i], d[i:], true
+}
+
+// ParseLineRanges parses a list of line numbers and ranges separated by
+// spaces or commas, e.g. "1 3-5".
+func ParseLineRanges(s []byte) ([]ast.LineRange, error) {
+	var ranges []ast.LineRange
+	for _, f := range bytes.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' }) {
+		from, to := f, f
+		if i := bytes.IndexByte(f, '-'); i >= 0 {
+			from, to = f[:i], f[i+1:]
+		}
+		r := ast.LineRange{}
+		var err1, err2 error
+		r.From, err1 = strconv.Atoi(string(from))
+		r.To, err2 = strconv.Atoi(string(to))
+		if err1 != nil || err2 != nil || r.From < 1 || r.To < r.From {
+			return nil, fmt.Errorf("markdown: invalid line range %q", f)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestCodeBlockMeta(t *testing.T) {
+	tests := []struct {
+		info string
+		meta *ast.CodeBlockMeta
+	}{
+		{"go", nil},
+		{"{go}", nil},
+		{"go {linenos}", &ast.CodeBlockMeta{LineNumbers: true}},
+		{"go {linenos=false}", &ast.CodeBlockMeta{}},
+		{
+			`go {linenos=true hl_lines="3-5 8" linenostart=10}`,
+			&ast.CodeBlockMeta{LineNumbers: true, Start: 10, Highlight: []ast.LineRange{{From: 3, To: 5}, {From: 8, To: 8}}},
+		},
+		{
+			`{hl_lines='1,2', title="main.go"}`,
+			&ast.CodeBlockMeta{Highlight: []ast.LineRange{{From: 1, To: 1}, {From: 2, To: 2}}, Attrs: map[string][]byte{"title": []byte("main.go")}},
+		},
+		{
+			"go {start=10 hl_lines=[2]}",
+			&ast.CodeBlockMeta{Start: 10, Highlight: []ast.LineRange{{From: 2, To: 2}}},
+		},
+		{"go {hl_lines=[1, 3-4]}", &ast.CodeBlockMeta{Highlight: []ast.LineRange{{From: 1, To: 1}, {From: 3, To: 4}}}},
+		{"go {linenostart=x}", nil},
+		{"go {hl_lines=[2}", nil},
+		{"{linenos=true}", &ast.CodeBlockMeta{LineNumbers: true}},
+		{`go {hl_lines="5-3"}`, nil},
+		{`go {title="main.go}`, nil},
+	}
+	for _, test := range tests {
+		meta := codeBlockMeta([]byte(test.info))
+		if !reflect.DeepEqual(meta, test.meta) {
+			t.Errorf("codeBlockMeta(%q) = %+v, expected %+v", test.info, meta, test.meta)
+		}
+	}
+}
+
+func TestCodeBlockMetaParsed(t *testing.T) {
+	for _, newParser := range []func() *Parser{New, NewCommonMark} {
+		doc := newParser().Parse([]byte("```go {linenos=true}\ncode\n```\n"))
+		code, ok := doc.GetChildren()[0].(*ast.CodeBlock)
+		if !ok || code.Meta == nil || !code.Meta.LineNumbers {
+			t.Errorf("code block meta not parsed: %+v", doc.GetChildren()[0])
+		}
+
+		// metadata without a language
+		doc = newParser().Parse([]byte("``` {linenos=true}\ncode\n```\n"))
+		code, ok = doc.GetChildren()[0].(*ast.CodeBlock)
+		if !ok || code.Meta == nil || !code.Meta.LineNumbers || string(code.Info) != "{linenos=true}" {
+			t.Errorf("code block meta without language not parsed: %+v", doc.GetChildren()[0])
+		}
+	}
+}
@@ -0,0 +1,950 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// CommonMark mode
+//
+// With the CommonMark extension the input is parsed by a separate parser
+// that follows the CommonMark spec (https://spec.commonmark.org/0.31.2/).
+// Blocks are parsed line by line with the two phase strategy described in
+// the appendix of the spec and inlines with the delimiter stack algorithm.
+// The result is made of the same AST nodes the default parser produces.
+
+type cmBlockType int
+
+const (
+	cmDocument cmBlockType = iota
+	cmBlockQuote
+	cmList
+	cmItem
+	cmParagraph
+	cmHeading
+	cmThematicBreak
+	cmCodeBlock
+	cmHTMLBlock
+)
+
+// results of continuing a block and of starting a new one
+const (
+	cmMatched  = 0
+	cmNoMatch  = 1
+	cmLineDone = 2
+
+	cmNoStart        = 0
+	cmContainerStart = 1
+	cmLeafStart      = 2
+)
+
+const cmCodeIndent = 4
+
+type cmListData struct {
+	ordered      bool
+	bulletChar   byte
+	start        int
+	delimiter    byte
+	padding      int
+	markerOffset int
+}
+
+// cmBlock is a block while the document is being parsed. The tree is
+// converted to AST nodes once all blocks are closed.
+type cmBlock struct {
+	typ      cmBlockType
+	parent   *cmBlock
+	children []*cmBlock
+	open     bool
+
+	// lines are 0 based, columns are byte offsets in the line
+	startLine, startCol int
+	endLine, endCol     int
+
+	content []byte       // raw content of leaf blocks
+	segs    []srcSegment // where content came from, if it is a copy
+	literal []byte
+
+	level       int // heading level
+	list        cmListData
+	task        bool // list item with a task marker
+	checked     bool // the task marker is [x]
+	tight       bool
+	fenced      bool
+	fenceChar   byte
+	fenceLength int
+	fenceOffset int
+	info        []byte
+	htmlType    int
+}
+
+func (b *cmBlock) lastChild() *cmBlock {
+	if len(b.children) == 0 {
+		return nil
+	}
+	return b.children[len(b.children)-1]
+}
+
+func (b *cmBlock) canContain(t cmBlockType) bool {
+	switch b.typ {
+	case cmDocument, cmBlockQuote, cmItem:
+		return t != cmItem
+	case cmList:
+		return t == cmItem
+	}
+	return false
+}
+
+func (b *cmBlock) acceptsLines() bool {
+	return b.typ == cmParagraph || b.typ == cmCodeBlock || b.typ == cmHTMLBlock
+}
+
+type cmParser struct {
+	p      *Parser
+	src    *source
+	lines  [][]byte
+	starts []int // offsets of lines in the input
+
+	doc, tip, oldTip, lastMatched *cmBlock
+	allClosed                     bool
+
+	line                 []byte
+	lineNum              int
+	offset, column       int
+	nextNonspace         int
+	nextNonspaceColumn   int
+	indent               int
+	indented, blank      bool
+	partiallyConsumedTab bool
+	lastLineLength       int
+}
+
+// parseCommonMark parses input according to the CommonMark spec into p.Doc.
+func (p *Parser) parseCommonMark(input []byte) {
+	buf, _ := p.findBuffer(input)
+	c := &cmParser{
+		p:         p,
+		src:       buf.segs[0].src,
+		doc:       &cmBlock{typ: cmDocument, open: true},
+		allClosed: true,
+	}
+	c.tip = c.doc
+	c.oldTip = c.doc
+	c.lastMatched = c.doc
+
+	start := 0
+	for i := 0; i <= len(input); i++ {
+		if i == len(input) || input[i] == '\n' {
+			// ignore the empty line after the final newline
+			if i == len(input) && start == i && i > 0 {
+				break
+			}
+			c.lines = append(c.lines, input[start:i])
+			c.starts = append(c.starts, start)
+			start = i + 1
+		}
+	}
+	for i, line := range c.lines {
+		c.lineNum = i
+		c.p.checkContext()
+		if bytes.IndexByte(line, 0) >= 0 {
+			line = bytes.Replace(line, []byte{0}, []byte("\uFFFD"), -1)
+		}
+		c.incorporateLine(line)
+	}
+	for c.tip != nil {
+		c.finalize(c.tip, c.lineNum)
+	}
+	c.convert(p.Doc, c.doc)
+}
+
+func (c *cmParser) incorporateLine(line []byte) {
+	container := c.doc
+	c.oldTip = c.tip
+	c.offset = 0
+	c.column = 0
+	c.blank = false
+	c.partiallyConsumedTab = false
+	c.line = line
+
+	// for each open block check if the line continues it, container ends
+	// up as the last block that matched
+	for {
+		last := container.lastChild()
+		if last == nil || !last.open {
+			break
+		}
+		container = last
+		c.findNextNonspace()
+		res := c.continueBlock(container)
+		if res == cmLineDone {
+			return
+		}
+		if res == cmNoMatch {
+			container = container.parent
+			break
+		}
+	}
+
+	c.allClosed = container == c.oldTip
+	c.lastMatched = container
+
+	// unless the last matched container is a code or html block, try to
+	// start new blocks
+	matchedLeaf := container.typ != cmParagraph && container.acceptsLines()
+	for !matchedLeaf {
+		c.findNextNonspace()
+		if !c.indented && !cmMaybeSpecial(line[c.nextNonspace:]) {
+			c.advanceNextNonspace()
+			break
+		}
+		res := c.blockStart(container)
+		if res == cmNoStart {
+			c.advanceNextNonspace()
+			break
+		}
+		container = c.tip
+		if res == cmLeafStart {
+			matchedLeaf = true
+		}
+	}
+
+	// what remains at offset is a text line
+	if !c.allClosed && !c.blank && c.tip.typ == cmParagraph {
+		// lazy paragraph continuation
+		c.addLine()
+	} else {
+		c.closeUnmatchedBlocks()
+		if container.acceptsLines() {
+			c.addLine()
+			t := container.htmlType
+			if container.typ == cmHTMLBlock && t >= 1 && t <= 5 && cmHTMLBlockClose[t].Match(line[c.offset:]) {
+				c.lastLineLength = len(line)
+				c.finalize(container, c.lineNum)
+			}
+		} else if c.offset < len(line) && !c.blank {
+			c.addChild(cmParagraph, c.offset)
+			c.advanceNextNonspace()
+			c.addLine()
+		}
+	}
+	c.lastLineLength = len(line)
+}
+
+func (c *cmParser) continueBlock(b *cmBlock) int {
+	line := c.line
+	switch b.typ {
+	case cmBlockQuote:
+		if c.indented || peek(line, c.nextNonspace) != '>' {
+			return cmNoMatch
+		}
+		c.advanceNextNonspace()
+		c.advanceOffset(1, false)
+		if isSpaceOrTab(peek(line, c.offset)) {
+			c.advanceOffset(1, true)
+		}
+	case cmItem:
+		if c.blank {
+			if len(b.children) == 0 {
+				// blank line after an empty list item
+				return cmNoMatch
+			}
+			c.advanceNextNonspace()
+		} else if c.indent >= b.list.markerOffset+b.list.padding {
+			c.advanceOffset(b.list.markerOffset+b.list.padding, true)
+		} else {
+			return cmNoMatch
+		}
+	case cmHeading, cmThematicBreak:
+		return cmNoMatch
+	case cmCodeBlock:
+		if b.fenced {
+			if c.indent <= 3 && peek(line, c.nextNonspace) == b.fenceChar {
+				n := cmClosingFence(line[c.nextNonspace:])
+				if n >= b.fenceLength {
+					c.lastLineLength = len(line)
+					c.finalize(b, c.lineNum)
+					return cmLineDone
+				}
+			}
+			// skip optional spaces of fence offset
+			for i := b.fenceOffset; i > 0 && isSpaceOrTab(peek(line, c.offset)); i-- {
+				c.advanceOffset(1, true)
+			}
+		} else {
+			if c.indent >= cmCodeIndent {
+				c.advanceOffset(cmCodeIndent, true)
+			} else if c.blank {
+				c.advanceNextNonspace()
+			} else {
+				return cmNoMatch
+			}
+		}
+	case cmHTMLBlock:
+		if c.blank && (b.htmlType == 6 || b.htmlType == 7) {
+			return cmNoMatch
+		}
+	case cmParagraph:
+		if c.blank {
+			return cmNoMatch
+		}
+	}
+	return cmMatched
+}
+
+// blockStart tries to start a new block in container.
+func (c *cmParser) blockStart(container *cmBlock) int {
+	line := c.line
+	rest := line[c.nextNonspace:]
+
+	if !c.indented {
+		// block quote
+		if peek(line, c.nextNonspace) == '>' {
+			c.advanceNextNonspace()
+			c.advanceOffset(1, false)
+			if isSpaceOrTab(peek(line, c.offset)) {
+				c.advanceOffset(1, true)
+			}
+			c.closeUnmatchedBlocks()
+			c.addChild(cmBlockQuote, c.nextNonspace)
+			return cmContainerStart
+		}
+
+		// ATX heading
+		if n := cmATXHeadingMarker(rest); n > 0 {
+			c.advanceNextNonspace()
+			c.advanceOffset(n, false)
+			c.closeUnmatchedBlocks()
+			b := c.addChild(cmHeading, c.nextNonspace)
+			b.level = bytes.Count(bytes.TrimRight(rest[:n], " \t"), []byte{'#'})
+			b.content = cmHeadingContent(line[c.offset:])
+			c.advanceOffset(len(line)-c.offset, false)
+			return cmLeafStart
+		}
+
+		// fenced code block
+		if n := cmCodeFence(rest); n > 0 {
+			c.closeUnmatchedBlocks()
+			b := c.addChild(cmCodeBlock, c.nextNonspace)
+			b.fenced = true
+			b.fenceLength = n
+			b.fenceChar = rest[0]
+			b.fenceOffset = c.indent
+			c.advanceNextNonspace()
+			c.advanceOffset(n, false)
+			return cmLeafStart
+		}
+
+		// HTML block
+		if peek(line, c.nextNonspace) == '<' {
+			for t := 1; t <= 7; t++ {
+				if !cmHTMLBlockOpen[t].Match(rest) {
+					continue
+				}
+				// type 7 can't interrupt a paragraph
+				if t == 7 && (container.typ == cmParagraph || (!c.allClosed && !c.blank && c.tip.typ == cmParagraph)) {
+					continue
+				}
+				c.closeUnmatchedBlocks()
+				b := c.addChild(cmHTMLBlock, c.offset)
+				b.htmlType = t
+				return cmLeafStart
+			}
+		}
+
+		// setext heading
+		if container.typ == cmParagraph && cmSetextHeadingLine(rest) {
+			c.closeUnmatchedBlocks()
+			c.parseReferences(container)
+			if len(container.content) > 0 {
+				container.typ = cmHeading
+				container.level = 2
+				if rest[0] == '=' {
+					container.level = 1
+				}
+				c.advanceOffset(len(line)-c.offset, false)
+				return cmLeafStart
+			}
+		}
+
+		// thematic break
+		if cmIsThematicBreak(rest) {
+			c.closeUnmatchedBlocks()
+			c.addChild(cmThematicBreak, c.nextNonspace)
+			c.advanceOffset(len(line)-c.offset, false)
+			return cmLeafStart
+		}
+	}
+
+	// list item
+	if !c.indented || container.typ == cmList {
+		if data, ok := c.parseListMarker(container); ok {
+			c.closeUnmatchedBlocks()
+			if c.tip.typ != cmList || !cmListsMatch(c.tip.list, data) {
+				list := c.addChild(cmList, c.nextNonspace)
+				list.list = data
+				list.tight = true
+			}
+			item := c.addChild(cmItem, c.nextNonspace)
+			item.list = data
+			return cmContainerStart
+		}
+	}
+
+	// indented code block
+	if c.indented && c.tip.typ != cmParagraph && !c.blank {
+		c.advanceOffset(cmCodeIndent, true)
+		c.closeUnmatchedBlocks()
+		c.addChild(cmCodeBlock, c.offset)
+		return cmLeafStart
+	}
+	return cmNoStart
+}
+
+func (c *cmParser) parseListMarker(container *cmBlock) (cmListData, bool) {
+	data := cmListData{markerOffset: c.indent}
+	if c.indent >= cmCodeIndent {
+		return data, false
+	}
+	line := c.line
+	rest := line[c.nextNonspace:]
+	n := 0
+	switch {
+	case len(rest) > 0 && (rest[0] == '*' || rest[0] == '+' || rest[0] == '-'):
+		data.bulletChar = rest[0]
+		n = 1
+	default:
+		for n < len(rest) && n < 10 && isDigit(rest[n]) {
+			data.start = data.start*10 + int(rest[n]-'0')
+			n++
+		}
+		if n == 0 || n > 9 || n >= len(rest) || (rest[n] != '.' && rest[n] != ')') {
+			return data, false
+		}
+		// only lists starting with 1 can interrupt a paragraph
+		if container.typ == cmParagraph && data.start != 1 {
+			return data, false
+		}
+		data.ordered = true
+		data.delimiter = rest[n]
+		n++
+	}
+	// the marker must be followed by a space, a tab or the end of the line
+	next := peek(line, c.nextNonspace+n)
+	if next != 0 && next != ' ' && next != '\t' {
+		return data, false
+	}
+	// an empty list item can't interrupt a paragraph
+	if container.typ == cmParagraph && isBlankLine(line[c.nextNonspace+n:]) {
+		return data, false
+	}
+
+	c.advanceNextNonspace()
+	c.advanceOffset(n, true)
+	spacesStartCol := c.column
+	spacesStartOffset := c.offset
+	for {
+		c.advanceOffset(1, true)
+		if c.column-spacesStartCol >= 5 || !isSpaceOrTab(peek(line, c.offset)) {
+			break
+		}
+	}
+	blankItem := c.offset >= len(line)
+	spacesAfterMarker := c.column - spacesStartCol
+	if spacesAfterMarker >= 5 || spacesAfterMarker < 1 || blankItem {
+		data.padding = n + 1
+		c.column = spacesStartCol
+		c.offset = spacesStartOffset
+		if isSpaceOrTab(peek(line, c.offset)) {
+			c.advanceOffset(1, true)
+		}
+	} else {
+		data.padding = n + spacesAfterMarker
+	}
+	return data, true
+}
+
+func cmListsMatch(list, item cmListData) bool {
+	return list.ordered == item.ordered && list.delimiter == item.delimiter &&
+		list.bulletChar == item.bulletChar
+}
+
+func (c *cmParser) findNextNonspace() {
+	line := c.line
+	i := c.offset
+	cols := c.column
+	for i < len(line) {
+		if line[i] == ' ' {
+			i++
+			cols++
+		} else if line[i] == '\t' {
+			i++
+			cols += 4 - cols%4
+		} else {
+			break
+		}
+	}
+	c.blank = i >= len(line)
+	c.nextNonspace = i
+	c.nextNonspaceColumn = cols
+	c.indent = cols - c.column
+	c.indented = c.indent >= cmCodeIndent
+}
+
+func (c *cmParser) advanceNextNonspace() {
+	c.offset = c.nextNonspace
+	c.column = c.nextNonspaceColumn
+	c.partiallyConsumedTab = false
+}
+
+// advanceOffset advances by count bytes or, if columns is true, by count
+// columns, which matters for tabs.
+func (c *cmParser) advanceOffset(count int, columns bool) {
+	line := c.line
+	for count > 0 && c.offset < len(line) {
+		if line[c.offset] == '\t' {
+			charsToTab := 4 - c.column%4
+			if columns {
+				c.partiallyConsumedTab = charsToTab > count
+				n := charsToTab
+				if n > count {
+					n = count
+				}
+				c.column += n
+				if !c.partiallyConsumedTab {
+					c.offset++
+				}
+				count -= n
+			} else {
+				c.partiallyConsumedTab = false
+				c.column += charsToTab
+				c.offset++
+				count--
+			}
+		} else {
+			c.partiallyConsumedTab = false
+			c.offset++
+			c.column++
+			count--
+		}
+	}
+}
+
+func (c *cmParser) addLine() {
+	b := c.tip
+	if c.partiallyConsumedTab {
+		// skip over the tab and add the spaces that remain of it
+		c.offset++
+		b.content = append(b.content, "    "[:4-c.column%4]...)
+	}
+	b.segs = append(b.segs, srcSegment{start: len(b.content), src: c.src, offset: c.starts[c.lineNum] + c.offset})
+	b.content = append(b.content, c.line[c.offset:]...)
+	b.content = append(b.content, '\n')
+}
+
+func (c *cmParser) addChild(typ cmBlockType, offset int) *cmBlock {
+	for !c.tip.canContain(typ) {
+		c.finalize(c.tip, c.lineNum-1)
+	}
+	if typ == cmBlockQuote || typ == cmItem {
+		depth := 1
+		for b := c.tip; b != nil; b = b.parent {
+			if b.typ == cmBlockQuote || b.typ == cmItem {
+				depth++
+			}
+		}
+		c.p.checkNesting(depth)
+	}
+	b := &cmBlock{
+		typ:       typ,
+		parent:    c.tip,
+		open:      true,
+		startLine: c.lineNum,
+		startCol:  offset,
+	}
+	c.tip.children = append(c.tip.children, b)
+	c.tip = b
+	return b
+}
+
+func (c *cmParser) closeUnmatchedBlocks() {
+	if c.allClosed {
+		return
+	}
+	for c.oldTip != c.lastMatched {
+		parent := c.oldTip.parent
+		c.finalize(c.oldTip, c.lineNum-1)
+		c.oldTip = parent
+	}
+	c.allClosed = true
+}
+
+// finalize closes block b which ends at line lineNum.
+func (c *cmParser) finalize(b *cmBlock, lineNum int) {
+	above := b.parent
+	b.open = false
+	b.endLine = lineNum
+	b.endCol = c.lastLineLength
+
+	switch b.typ {
+	case cmParagraph:
+		if c.parseReferences(b) && isBlankLine(b.content) {
+			// only reference definitions, drop the paragraph
+			above.children = above.children[:len(above.children)-1]
+		}
+	case cmCodeBlock:
+		if b.fenced {
+			// the first line is the info string
+			nl := bytes.IndexByte(b.content, '\n')
+			b.info = unescapeString(bytes.TrimSpace(b.content[:nl]))
+			b.literal = b.content[nl+1:]
+		} else {
+			// remove trailing blank lines
+			lines := bytes.Split(b.content, []byte{'\n'})
+			for len(lines) > 0 && isBlankLine(lines[len(lines)-1]) {
+				lines = lines[:len(lines)-1]
+			}
+			b.literal = append(bytes.Join(lines, []byte{'\n'}), '\n')
+		}
+	case cmHTMLBlock:
+		b.literal = bytes.TrimRight(b.content, " \n")
+	case cmList:
+		c.finalizeList(b)
+		last := b.lastChild()
+		b.endLine, b.endCol = last.endLine, last.endCol
+	case cmItem:
+		if c.p.extensions&TaskLists != 0 {
+			c.parseTaskMarker(b)
+		}
+		if last := b.lastChild(); last != nil {
+			b.endLine, b.endCol = last.endLine, last.endCol
+		} else {
+			b.endLine = b.startLine
+			b.endCol = b.list.markerOffset + b.list.padding
+		}
+	}
+	c.tip = above
+}
+
+// parseTaskMarker removes the task marker, [ ] or [x], from the start of
+// the first paragraph of item b. The paragraph is empty if the marker is
+// all there is, e.g. "- [ ]".
+func (c *cmParser) parseTaskMarker(b *cmBlock) {
+	if len(b.children) == 0 || b.children[0].typ != cmParagraph {
+		return
+	}
+	para := b.children[0]
+	n := taskMarker(para.content)
+	if n == 0 {
+		return
+	}
+	b.task, b.checked = true, para.content[1] != ' '
+	n = skipTaskSpace(para.content, n)
+	para.content = para.content[n:]
+	for i := range para.segs {
+		para.segs[i].start -= n
+	}
+}
+
+// finalizeList makes the list loose if any of its items, or blocks
+// directly inside of them, are separated by a blank line.
+func (c *cmParser) finalizeList(list *cmBlock) {
+	endsWithBlankLine := func(blocks []*cmBlock, i int) bool {
+		return i+1 < len(blocks) && blocks[i].endLine != blocks[i+1].startLine-1
+	}
+	for i, item := range list.children {
+		if endsWithBlankLine(list.children, i) {
+			list.tight = false
+			return
+		}
+		for j := range item.children {
+			if endsWithBlankLine(item.children, j) {
+				list.tight = false
+				return
+			}
+		}
+	}
+}
+
+// parseReferences parses the link reference definitions at the start of
+// the content of b and removes them from it. It returns true if there were
+// any.
+func (c *cmParser) parseReferences(b *cmBlock) bool {
+	found := false
+	for len(b.content) > 0 && b.content[0] == '[' {
+		ip := &cmInlineParser{c: c, subject: b.content}
+		n := ip.parseReference()
+		if n == 0 {
+			break
+		}
+		found = true
+		b.content = b.content[n:]
+		for i := range b.segs {
+			b.segs[i].start -= n
+		}
+	}
+	return found
+}
+
+// convert creates AST nodes for children of b and adds them to node.
+func (c *cmParser) convert(node ast.Node, b *cmBlock) {
+	c.setRange(node, b)
+	if b.typ == cmParagraph || b.typ == cmHeading {
+		c.registerContent(b)
+		c.parseInlines(node, b.content)
+		return
+	}
+	for _, child := range b.children {
+		var n ast.Node
+		switch child.typ {
+		case cmBlockQuote:
+			n = &ast.BlockQuote{}
+		case cmList:
+			list := &ast.List{
+				Tight:      child.tight,
+				BulletChar: child.list.bulletChar,
+				Delimiter:  child.list.delimiter,
+			}
+			if child.list.ordered {
+				list.ListFlags = ast.ListTypeOrdered
+				if child.list.start != 1 {
+					list.Start = child.list.start
+				}
+			}
+			n = list
+		case cmItem:
+			item := &ast.ListItem{
+				Tight:      b.tight,
+				BulletChar: child.list.bulletChar,
+				Delimiter:  child.list.delimiter,
+				IsTask:     child.task,
+				Checked:    child.checked,
+			}
+			if child.list.ordered {
+				item.ListFlags = ast.ListTypeOrdered
+			}
+			n = item
+		case cmParagraph:
+			n = &ast.Paragraph{}
+		case cmHeading:
+			n = &ast.Heading{Level: child.level}
+		case cmThematicBreak:
+			n = &ast.HorizontalRule{}
+		case cmCodeBlock:
+			code := &ast.CodeBlock{
+				IsFenced:    child.fenced,
+				Info:        child.info,
+				FenceChar:   child.fenceChar,
+				FenceLength: child.fenceLength,
+				FenceOffset: child.fenceOffset,
+				Meta:        codeBlockMeta(child.info),
+			}
+			code.Literal = child.literal
+			n = code
+		case cmHTMLBlock:
+			html := &ast.HTMLBlock{}
+			html.Literal = child.literal
+			n = html
+		}
+		c.p.countNodes(1)
+		ast.AppendChild(node, n)
+		c.convert(n, child)
+	}
+}
+
+// setRange sets the source range of node to the lines of b.
+func (c *cmParser) setRange(node ast.Node, b *cmBlock) {
+	if len(c.lines) == 0 {
+		return
+	}
+	start := c.starts[b.startLine] + b.startCol
+	end := start
+	if b.endLine >= 0 && c.starts[b.endLine]+b.endCol > start {
+		end = c.starts[b.endLine] + b.endCol
+	}
+	ast.SetSourceRange(node, ast.SourceRange{
+		File:  c.src.file,
+		Start: c.src.position(start),
+		End:   c.src.position(end),
+	})
+}
+
+// registerContent registers the content of b if it was copied together
+// from the lines of the block.
+func (c *cmParser) registerContent(b *cmBlock) {
+	if len(b.segs) > 0 && len(b.content) > 0 {
+		c.p.registerBuffer(b.content, b.segs)
+	}
+}
+
+func peek(d []byte, i int) byte {
+	if i < len(d) {
+		return d[i]
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSpaceOrTab(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isBlankLine(d []byte) bool {
+	for _, c := range d {
+		if !IsSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// cmMaybeSpecial returns false if d can't start a block other than a
+// paragraph.
+func cmMaybeSpecial(d []byte) bool {
+	if len(d) == 0 {
+		return false
+	}
+	switch d[0] {
+	case '#', '`', '~', '*', '+', '_', '=', '<', '>', '-':
+		return true
+	}
+	return isDigit(d[0])
+}
+
+// cmATXHeadingMarker returns the length of the opening sequence of an ATX
+// heading and the spaces after it, or 0.
+func cmATXHeadingMarker(d []byte) int {
+	n := 0
+	for n < len(d) && d[n] == '#' {
+		n++
+	}
+	if n == 0 || n > 6 || (n < len(d) && !isSpaceOrTab(d[n])) {
+		return 0
+	}
+	for n < len(d) && isSpaceOrTab(d[n]) {
+		n++
+	}
+	return n
+}
+
+// cmHeadingContent strips the optional closing sequence from the content
+// of an ATX heading.
+func cmHeadingContent(d []byte) []byte {
+	d = bytes.TrimRight(d, " \t")
+	end := len(d)
+	for end > 0 && d[end-1] == '#' {
+		end--
+	}
+	if end == len(d) {
+		return d
+	}
+	if end == 0 {
+		return d[:0]
+	}
+	if isSpaceOrTab(d[end-1]) {
+		return bytes.TrimRight(d[:end], " \t")
+	}
+	return d
+}
+
+// cmCodeFence returns the length of the opening code fence at the start
+// of d, or 0.
+func cmCodeFence(d []byte) int {
+	if len(d) == 0 || (d[0] != '`' && d[0] != '~') {
+		return 0
+	}
+	n := 0
+	for n < len(d) && d[n] == d[0] {
+		n++
+	}
+	if n < 3 {
+		return 0
+	}
+	// the info string of a backtick fence can't contain backticks
+	if d[0] == '`' && bytes.IndexByte(d[n:], '`') >= 0 {
+		return 0
+	}
+	return n
+}
+
+// cmClosingFence returns the length of the closing code fence that makes
+// up d, or 0.
+func cmClosingFence(d []byte) int {
+	n := 0
+	for n < len(d) && d[n] == d[0] {
+		n++
+	}
+	if n < 3 || (d[0] != '`' && d[0] != '~') || !isBlankLine(d[n:]) {
+		return 0
+	}
+	return n
+}
+
+func cmSetextHeadingLine(d []byte) bool {
+	if len(d) == 0 || (d[0] != '=' && d[0] != '-') {
+		return false
+	}
+	n := 0
+	for n < len(d) && d[n] == d[0] {
+		n++
+	}
+	return isBlankLine(d[n:])
+}
+
+func cmIsThematicBreak(d []byte) bool {
+	if len(d) == 0 || (d[0] != '*' && d[0] != '-' && d[0] != '_') {
+		return false
+	}
+	n := 0
+	for _, c := range d {
+		if c == d[0] {
+			n++
+		} else if !isSpaceOrTab(c) {
+			return false
+		}
+	}
+	return n >= 3
+}
+
+const (
+	cmTagName        = `[A-Za-z][A-Za-z0-9-]*`
+	cmAttributeName  = `[a-zA-Z_:][a-zA-Z0-9:._-]*`
+	cmUnquotedValue  = "[^\"'=<>`\\x00-\\x20]+"
+	cmAttributeValue = `(?:` + cmUnquotedValue + `|'[^']*'|"[^"]*")`
+	cmAttribute      = `(?:\s+` + cmAttributeName + `(?:\s*=\s*` + cmAttributeValue + `)?)`
+	cmOpenTag        = `<` + cmTagName + cmAttribute + `*\s*/?>`
+	cmCloseTag       = `</` + cmTagName + `\s*[>]`
+	cmHTMLComment    = `<!-->|<!--->|<!--[\s\S]*?-->`
+	cmProcessing     = `[<][?][\s\S]*?[?][>]`
+	cmDeclaration    = `<![A-Za-z]+[^>]*>`
+	cmCDATA          = `<!\[CDATA\[[\s\S]*?\]\]>`
+	cmHTMLTag        = `(?:` + cmOpenTag + `|` + cmCloseTag + `|` + cmHTMLComment + `|` + cmProcessing + `|` + cmDeclaration + `|` + cmCDATA + `)`
+	cmBlockTagNames  = `address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[123456]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul`
+)
+
+// conditions that start and end the 7 kinds of HTML blocks
+var (
+	cmHTMLBlockOpen = []*regexp.Regexp{
+		nil,
+		regexp.MustCompile(`(?i)^<(?:script|pre|textarea|style)(?:\s|>|$)`),
+		regexp.MustCompile(`^<!--`),
+		regexp.MustCompile(`^<[?]`),
+		regexp.MustCompile(`^<![A-Za-z]`),
+		regexp.MustCompile(`^<!\[CDATA\[`),
+		regexp.MustCompile(`(?i)^</?(?:` + cmBlockTagNames + `)(?:\s|/?>|$)`),
+		regexp.MustCompile(`(?i)^(?:` + cmOpenTag + `|` + cmCloseTag + `)\s*$`),
+	}
+	cmHTMLBlockClose = []*regexp.Regexp{
+		nil,
+		regexp.MustCompile(`(?i)</(?:script|pre|textarea|style)>`),
+		regexp.MustCompile(`-->`),
+		regexp.MustCompile(`\?>`),
+		regexp.MustCompile(`>`),
+		regexp.MustCompile(`\]\]>`),
+	}
+)
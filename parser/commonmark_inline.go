@@ -0,0 +1,846 @@
+package parser
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// cmInline is an inline node while the content of a block is being parsed.
+// Emphasis and links are created after their content so the nodes are kept
+// in a linked list that is easy to rearrange and converted to AST at the
+// end.
+type cmInline struct {
+	node        ast.Node
+	start, end  int // range in the subject
+	parent      *cmInline
+	prev, next  *cmInline
+	first, last *cmInline
+}
+
+func (n *cmInline) unlink() {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else if n.parent != nil {
+		n.parent.first = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else if n.parent != nil {
+		n.parent.last = n.prev
+	}
+	n.parent, n.prev, n.next = nil, nil, nil
+}
+
+func (n *cmInline) appendChild(child *cmInline) {
+	child.unlink()
+	child.parent = n
+	if n.last != nil {
+		n.last.next = child
+		child.prev = n.last
+		n.last = child
+	} else {
+		n.first = child
+		n.last = child
+	}
+}
+
+func (n *cmInline) insertAfter(sibling *cmInline) {
+	sibling.unlink()
+	sibling.next = n.next
+	if sibling.next != nil {
+		sibling.next.prev = sibling
+	}
+	sibling.prev = n
+	n.next = sibling
+	sibling.parent = n.parent
+	if sibling.next == nil && sibling.parent != nil {
+		sibling.parent.last = sibling
+	}
+}
+
+// cmDelimiter is an entry on the stack of emphasis delimiter runs.
+type cmDelimiter struct {
+	c          byte
+	numDelims  int
+	origDelims int
+	inl        *cmInline
+	prev, next *cmDelimiter
+	canOpen    bool
+	canClose   bool
+}
+
+// cmBracket is an entry on the stack of link and image openers.
+type cmBracket struct {
+	inl          *cmInline
+	prev         *cmBracket
+	prevDelim    *cmDelimiter
+	index        int
+	image        bool
+	active       bool
+	bracketAfter bool
+}
+
+type cmInlineParser struct {
+	c          *cmParser
+	subject    []byte
+	pos        int
+	root       *cmInline
+	delimiters *cmDelimiter
+	brackets   *cmBracket
+}
+
+// parseInlines parses content as inlines and adds them to node.
+func (c *cmParser) parseInlines(node ast.Node, content []byte) {
+	ip := &cmInlineParser{
+		c:       c,
+		subject: bytes.Trim(content, " \t\n"),
+		root:    &cmInline{node: node},
+	}
+	for ip.pos < len(ip.subject) {
+		ip.parseInline()
+	}
+	ip.processEmphasis(nil)
+	ip.convert(ip.root)
+}
+
+func (ip *cmInlineParser) convert(parent *cmInline) {
+	for n := parent.first; n != nil; n = n.next {
+		ast.AppendChild(parent.node, n.node)
+		ip.c.p.setRange(n.node, ip.subject[n.start:n.end])
+		ip.convert(n)
+	}
+}
+
+func (ip *cmInlineParser) peek() byte {
+	return peek(ip.subject, ip.pos)
+}
+
+// add appends node created from subject[start:ip.pos] to the block.
+func (ip *cmInlineParser) add(node ast.Node, start int) *cmInline {
+	ip.c.p.countNodes(1)
+	inl := &cmInline{node: node, start: start, end: ip.pos}
+	ip.root.appendChild(inl)
+	return inl
+}
+
+// addText appends a text node with subject[start:ip.pos] as content.
+func (ip *cmInlineParser) addText(start int) *cmInline {
+	return ip.add(newTextNode(ip.subject[start:ip.pos]), start)
+}
+
+func (ip *cmInlineParser) parseInline() {
+	start := ip.pos
+	switch ip.subject[ip.pos] {
+	case '\n':
+		ip.parseNewline()
+		return
+	case '\\':
+		ip.parseBackslash()
+		return
+	case '`':
+		ip.parseBackticks()
+		return
+	case '*', '_':
+		ip.handleDelim(ip.subject[ip.pos])
+		return
+	case '[':
+		ip.pos++
+		ip.addBracket(ip.addText(start), start, false)
+		return
+	case '!':
+		ip.pos++
+		if ip.peek() == '[' {
+			ip.pos++
+			ip.addBracket(ip.addText(start), start+1, true)
+		} else {
+			ip.addText(start)
+		}
+		return
+	case ']':
+		ip.parseCloseBracket()
+		return
+	case '<':
+		if ip.parseAutolink() || ip.parseHTMLTag() {
+			return
+		}
+	case '&':
+		if ip.parseEntity() {
+			return
+		}
+	default:
+		ip.parseString()
+		return
+	}
+	ip.pos++
+	ip.addText(start)
+}
+
+func cmIsSpecial(c byte) bool {
+	switch c {
+	case '\n', '`', '[', ']', '\\', '!', '<', '&', '*', '_':
+		return true
+	}
+	return false
+}
+
+func (ip *cmInlineParser) parseString() {
+	start := ip.pos
+	for ip.pos < len(ip.subject) && !cmIsSpecial(ip.subject[ip.pos]) {
+		ip.pos++
+	}
+	ip.addText(start)
+}
+
+// parseNewline turns a line ending into a soft break or, if it's preceded
+// by 2 or more spaces, a hard break.
+func (ip *cmInlineParser) parseNewline() {
+	start := ip.pos
+	ip.pos++
+	var node ast.Node = &ast.Softbreak{}
+	if last := ip.root.last; last != nil {
+		if text, ok := last.node.(*ast.Text); ok && bytes.HasSuffix(text.Literal, []byte(" ")) {
+			if bytes.HasSuffix(text.Literal, []byte("  ")) {
+				node = &ast.Hardbreak{}
+			}
+			text.Literal = bytes.TrimRight(text.Literal, " ")
+			last.end = last.start + len(text.Literal)
+		}
+	}
+	ip.add(node, start)
+	// skip spaces at the beginning of the next line
+	for ip.peek() == ' ' {
+		ip.pos++
+	}
+}
+
+func (ip *cmInlineParser) parseBackslash() {
+	start := ip.pos
+	ip.pos++
+	c := ip.peek()
+	switch {
+	case c == '\n':
+		ip.pos++
+		ip.add(&ast.Hardbreak{}, start)
+	case ip.pos < len(ip.subject) && isEscapable(c):
+		ip.pos++
+		text := ip.addText(start)
+		text.node.AsLeaf().Literal = ip.subject[start+1 : ip.pos]
+	default:
+		ip.addText(start)
+	}
+}
+
+func (ip *cmInlineParser) parseBackticks() {
+	start := ip.pos
+	for ip.peek() == '`' {
+		ip.pos++
+	}
+	n := ip.pos - start
+	afterOpen := ip.pos
+	// look for a closing run of the same length
+	for i := afterOpen; i < len(ip.subject); {
+		if ip.subject[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(ip.subject) && ip.subject[j] == '`' {
+			j++
+		}
+		if j-i == n {
+			contents := bytes.Replace(ip.subject[afterOpen:i], []byte{'\n'}, []byte{' '}, -1)
+			if len(contents) > 2 && contents[0] == ' ' && contents[len(contents)-1] == ' ' &&
+				len(bytes.Trim(contents, " ")) > 0 {
+				contents = contents[1 : len(contents)-1]
+			}
+			ip.pos = j
+			code := &ast.Code{}
+			code.Literal = contents
+			ip.add(code, start)
+			return
+		}
+		i = j
+	}
+	// no closing run, the opening backticks are literal
+	ip.addText(start)
+}
+
+// scanDelims scans the delimiter run at the current position and returns
+// its length and whether it can open and close emphasis.
+func (ip *cmInlineParser) scanDelims(c byte) (n int, canOpen, canClose bool) {
+	start := ip.pos
+	for peek(ip.subject, start+n) == c && start+n < len(ip.subject) {
+		n++
+	}
+	before := '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRune(ip.subject[:start])
+	}
+	after := '\n'
+	if start+n < len(ip.subject) {
+		after, _ = utf8.DecodeRune(ip.subject[start+n:])
+	}
+	afterIsSpace := cmIsUnicodeSpace(after)
+	afterIsPunct := cmIsUnicodePunct(after)
+	beforeIsSpace := cmIsUnicodeSpace(before)
+	beforeIsPunct := cmIsUnicodePunct(before)
+
+	leftFlanking := !afterIsSpace && (!afterIsPunct || beforeIsSpace || beforeIsPunct)
+	rightFlanking := !beforeIsSpace && (!beforeIsPunct || afterIsSpace || afterIsPunct)
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || beforeIsPunct)
+		canClose = rightFlanking && (!leftFlanking || afterIsPunct)
+	} else {
+		canOpen = leftFlanking
+		canClose = rightFlanking
+	}
+	return n, canOpen, canClose
+}
+
+func cmIsUnicodeSpace(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\f' || r == '\r' || unicode.Is(unicode.Zs, r)
+}
+
+func cmIsUnicodePunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+func (ip *cmInlineParser) handleDelim(c byte) {
+	n, canOpen, canClose := ip.scanDelims(c)
+	start := ip.pos
+	ip.pos += n
+	d := &cmDelimiter{
+		c:          c,
+		numDelims:  n,
+		origDelims: n,
+		inl:        ip.addText(start),
+		prev:       ip.delimiters,
+		canOpen:    canOpen,
+		canClose:   canClose,
+	}
+	if d.prev != nil {
+		d.prev.next = d
+	}
+	ip.delimiters = d
+}
+
+func (ip *cmInlineParser) removeDelimiter(d *cmDelimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next == nil {
+		ip.delimiters = d.prev
+	} else {
+		d.next.prev = d.prev
+	}
+}
+
+// processEmphasis matches emphasis openers and closers above stackBottom
+// on the delimiter stack and turns them into emphasis nodes.
+func (ip *cmInlineParser) processEmphasis(stackBottom *cmDelimiter) {
+	// lower bounds for the search of an opener, per delimiter character,
+	// whether the closer can open and the length of the closer modulo 3
+	var openersBottom [12]*cmDelimiter
+	for i := range openersBottom {
+		openersBottom[i] = stackBottom
+	}
+
+	// find the first closer above stackBottom
+	closer := ip.delimiters
+	for closer != nil && closer.prev != stackBottom {
+		closer = closer.prev
+	}
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+		// look back for the first matching opener
+		bottom := closer.origDelims % 3
+		if closer.canOpen {
+			bottom += 3
+		}
+		if closer.c == '*' {
+			bottom += 6
+		}
+		opener := closer.prev
+		openerFound := false
+		for opener != nil && opener != stackBottom && opener != openersBottom[bottom] {
+			oddMatch := (closer.canOpen || opener.canClose) && closer.origDelims%3 != 0 &&
+				(opener.origDelims+closer.origDelims)%3 == 0
+			if opener.c == closer.c && opener.canOpen && !oddMatch {
+				openerFound = true
+				break
+			}
+			opener = opener.prev
+		}
+		oldCloser := closer
+		if !openerFound {
+			closer = closer.next
+			// set lower bound for future searches for openers
+			openersBottom[bottom] = oldCloser.prev
+			if !oldCloser.canOpen {
+				// a closer that can't be an opener is no longer needed
+				ip.removeDelimiter(oldCloser)
+			}
+			continue
+		}
+
+		use := 1
+		if closer.numDelims >= 2 && opener.numDelims >= 2 {
+			use = 2
+		}
+		openerInl := opener.inl
+		closerInl := closer.inl
+
+		// remove the used delimiters from the stack entries and text nodes
+		opener.numDelims -= use
+		closer.numDelims -= use
+		openerInl.end -= use
+		closerInl.start += use
+		openerInl.node.AsLeaf().Literal = ip.subject[openerInl.start:openerInl.end]
+		closerInl.node.AsLeaf().Literal = ip.subject[closerInl.start:closerInl.end]
+
+		var node ast.Node = &ast.Emph{}
+		if use == 2 {
+			node = &ast.Strong{}
+		}
+		emph := &cmInline{node: node, start: openerInl.end, end: closerInl.start}
+		for tmp := openerInl.next; tmp != nil && tmp != closerInl; {
+			next := tmp.next
+			emph.appendChild(tmp)
+			tmp = next
+		}
+		openerInl.insertAfter(emph)
+
+		// remove the delimiters between opener and closer
+		if opener.next != closer {
+			opener.next = closer
+			closer.prev = opener
+		}
+		if opener.numDelims == 0 {
+			openerInl.unlink()
+			ip.removeDelimiter(opener)
+		}
+		if closer.numDelims == 0 {
+			closerInl.unlink()
+			next := closer.next
+			ip.removeDelimiter(closer)
+			closer = next
+		}
+	}
+
+	// remove all delimiters
+	for ip.delimiters != nil && ip.delimiters != stackBottom {
+		ip.removeDelimiter(ip.delimiters)
+	}
+}
+
+func (ip *cmInlineParser) addBracket(inl *cmInline, index int, image bool) {
+	if ip.brackets != nil {
+		ip.brackets.bracketAfter = true
+	}
+	ip.brackets = &cmBracket{
+		inl:       inl,
+		prev:      ip.brackets,
+		prevDelim: ip.delimiters,
+		index:     index,
+		image:     image,
+		active:    true,
+	}
+}
+
+func (ip *cmInlineParser) parseCloseBracket() {
+	ip.pos++
+	startPos := ip.pos
+	opener := ip.brackets
+	if opener == nil {
+		// no matched opener, just a literal
+		ip.addText(startPos - 1)
+		return
+	}
+	if !opener.active {
+		ip.addText(startPos - 1)
+		ip.brackets = opener.prev
+		return
+	}
+
+	var dest, title []byte
+	matched := false
+
+	// inline link
+	if ip.peek() == '(' {
+		ip.pos++
+		ip.spnl()
+		var ok bool
+		if dest, ok = ip.parseLinkDestination(); ok {
+			ip.spnl()
+			// the title has to be separated by whitespace
+			if IsSpace(ip.subject[ip.pos-1]) {
+				title, _ = ip.parseLinkTitle()
+			}
+			ip.spnl()
+			if ip.peek() == ')' {
+				ip.pos++
+				matched = true
+			}
+		}
+		if !matched {
+			ip.pos = startPos
+		}
+	}
+
+	// reference link
+	if !matched {
+		var label []byte
+		beforeLabel := ip.pos
+		n := ip.parseLinkLabel()
+		if n > 2 {
+			label = ip.subject[beforeLabel : beforeLabel+n]
+		} else if !opener.bracketAfter {
+			// empty or missing second label, use the first one. It can't
+			// contain a bracket so don't bother if we know there's one
+			label = ip.subject[opener.index:startPos]
+		}
+		if n == 0 {
+			// rewind the spaces of a shortcut reference
+			ip.pos = startPos
+		}
+		if label != nil {
+			if ref, found := ip.c.p.getRef(cmNormalizeLabel(label)); found {
+				dest = ref.link
+				title = ref.title
+				matched = true
+			}
+		}
+	}
+
+	if !matched {
+		ip.brackets = opener.prev
+		ip.pos = startPos
+		ip.addText(startPos - 1)
+		return
+	}
+
+	var node ast.Node
+	if opener.image {
+		img := &ast.Image{Destination: dest}
+		if len(title) > 0 {
+			img.Title = title
+		}
+		node = img
+	} else {
+		node = &ast.Link{Destination: dest, Title: title}
+	}
+	link := &cmInline{node: node, start: opener.inl.start, end: ip.pos}
+	for tmp := opener.inl.next; tmp != nil; {
+		next := tmp.next
+		link.appendChild(tmp)
+		tmp = next
+	}
+	ip.root.appendChild(link)
+	ip.processEmphasis(opener.prevDelim)
+	ip.brackets = opener.prev
+	opener.inl.unlink()
+
+	// no links in links, deactivate earlier link openers
+	if !opener.image {
+		for b := ip.brackets; b != nil; b = b.prev {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+}
+
+// spnl skips spaces and tabs, including up to one line ending.
+func (ip *cmInlineParser) spnl() {
+	for isSpaceOrTab(ip.peek()) && ip.pos < len(ip.subject) {
+		ip.pos++
+	}
+	if ip.peek() == '\n' && ip.pos < len(ip.subject) {
+		ip.pos++
+		for isSpaceOrTab(ip.peek()) && ip.pos < len(ip.subject) {
+			ip.pos++
+		}
+	}
+}
+
+// parseLinkLabel returns the length of the link label at the current
+// position, or 0.
+func (ip *cmInlineParser) parseLinkLabel() int {
+	s := ip.subject
+	if ip.peek() != '[' {
+		return 0
+	}
+	chars := 0
+	for i := ip.pos + 1; i < len(s) && chars <= 999; chars++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+		case '[':
+			return 0
+		case ']':
+			n := i + 1 - ip.pos
+			ip.pos = i + 1
+			return n
+		}
+		_, size := utf8.DecodeRune(s[i:])
+		i += size
+	}
+	return 0
+}
+
+func (ip *cmInlineParser) parseLinkDestination() ([]byte, bool) {
+	s := ip.subject
+	start := ip.pos
+	if ip.peek() == '<' {
+		for i := start + 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				if i+1 < len(s) && s[i+1] != '\n' {
+					i++
+					continue
+				}
+				return nil, false
+			case '>':
+				ip.pos = i + 1
+				return cmNormalizeURI(unescapeString(s[start+1 : i])), true
+			case '<', '\n', 0:
+				return nil, false
+			}
+		}
+		return nil, false
+	}
+
+	openParens := 0
+loop:
+	for ip.pos < len(s) {
+		c := s[ip.pos]
+		switch {
+		case c == '\\' && ip.pos+1 < len(s) && isEscapable(s[ip.pos+1]):
+			ip.pos += 2
+		case c == '(':
+			ip.pos++
+			openParens++
+		case c == ')':
+			if openParens < 1 {
+				break loop
+			}
+			ip.pos++
+			openParens--
+		case c <= ' ' || c == 0x7f:
+			// whitespace and control characters
+			break loop
+		default:
+			ip.pos++
+		}
+	}
+	if (ip.pos == start && ip.peek() != ')') || openParens != 0 {
+		ip.pos = start
+		return nil, false
+	}
+	return cmNormalizeURI(unescapeString(s[start:ip.pos])), true
+}
+
+func (ip *cmInlineParser) parseLinkTitle() ([]byte, bool) {
+	s := ip.subject
+	start := ip.pos
+	if start >= len(s) {
+		return nil, false
+	}
+	closer := s[start]
+	switch closer {
+	case '"', '\'':
+	case '(':
+		closer = ')'
+	default:
+		return nil, false
+	}
+	for i := start + 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, false
+			}
+			i++
+		case c == closer:
+			ip.pos = i + 1
+			return unescapeString(s[start+1 : i]), true
+		case c == 0, closer == ')' && c == '(':
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+var (
+	cmEmailAutolinkRe = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	cmAutolinkRe      = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9.+-]{1,31}:[^<>\x00-\x20]*>`)
+	cmHTMLTagRe       = regexp.MustCompile(`^(?i)` + cmHTMLTag)
+	cmEntityRe        = regexp.MustCompile(`^&(?:#[xX][a-fA-F0-9]{1,6}|#[0-9]{1,7}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+)
+
+func (ip *cmInlineParser) parseAutolink() bool {
+	start := ip.pos
+	rest := ip.subject[start:]
+	var dest []byte
+	m := cmEmailAutolinkRe.Find(rest)
+	if m != nil {
+		dest = append([]byte("mailto:"), cmNormalizeURI(m[1:len(m)-1])...)
+	} else if m = cmAutolinkRe.Find(rest); m != nil {
+		dest = cmNormalizeURI(m[1 : len(m)-1])
+	} else {
+		return false
+	}
+	ip.pos += len(m)
+	link := ip.add(&ast.Link{Destination: dest}, start)
+	text := &cmInline{node: newTextNode(m[1 : len(m)-1]), start: start + 1, end: ip.pos - 1}
+	link.appendChild(text)
+	return true
+}
+
+func (ip *cmInlineParser) parseHTMLTag() bool {
+	start := ip.pos
+	m := cmHTMLTagRe.Find(ip.subject[start:])
+	if m == nil {
+		return false
+	}
+	ip.pos += len(m)
+	span := &ast.HTMLSpan{}
+	span.Literal = m
+	ip.add(span, start)
+	return true
+}
+
+func (ip *cmInlineParser) parseEntity() bool {
+	start := ip.pos
+	m := cmEntityRe.Find(ip.subject[start:])
+	if m == nil {
+		return false
+	}
+	ip.pos += len(m)
+	text := ip.addText(start)
+	text.node.AsLeaf().Literal = []byte(html.UnescapeString(string(m)))
+	return true
+}
+
+// parseReference parses a link reference definition at the start of the
+// subject and returns its length, or 0.
+func (ip *cmInlineParser) parseReference() int {
+	n := ip.parseLinkLabel()
+	if n == 0 {
+		return 0
+	}
+	label := ip.subject[:n]
+	if ip.peek() != ':' {
+		return 0
+	}
+	ip.pos++
+	ip.spnl()
+	dest, ok := ip.parseLinkDestination()
+	if !ok {
+		return 0
+	}
+
+	var title []byte
+	hasTitle := false
+	beforeTitle := ip.pos
+	ip.spnl()
+	if ip.pos != beforeTitle {
+		title, hasTitle = ip.parseLinkTitle()
+	}
+	if !hasTitle {
+		ip.pos = beforeTitle
+	}
+
+	// the definition has to end at the end of a line
+	if !ip.atLineEnd() {
+		if !hasTitle {
+			return 0
+		}
+		// the title isn't at the end of a line but the definition is
+		// still valid without it if the destination is
+		title = nil
+		ip.pos = beforeTitle
+		if !ip.atLineEnd() {
+			return 0
+		}
+	}
+
+	key := cmNormalizeLabel(label)
+	if key == "" {
+		return 0
+	}
+	if _, exists := ip.c.p.refs[key]; !exists {
+		ip.c.p.refs[key] = &reference{
+			link:  dest,
+			title: title,
+			src:   ip.subject[:ip.pos],
+		}
+		ip.c.p.checkReferences()
+	}
+	return ip.pos
+}
+
+// atLineEnd skips spaces and tabs and the line ending that follows them
+// and returns true, or false if there's other text before it.
+func (ip *cmInlineParser) atLineEnd() bool {
+	i := ip.pos
+	for i < len(ip.subject) && isSpaceOrTab(ip.subject[i]) {
+		i++
+	}
+	if i < len(ip.subject) && ip.subject[i] != '\n' {
+		return false
+	}
+	if i < len(ip.subject) {
+		i++
+	}
+	ip.pos = i
+	return true
+}
+
+// cmNormalizeLabel returns the key that a link label is matched by: the
+// label without the brackets, with its whitespace collapsed and case
+// folded.
+func cmNormalizeLabel(label []byte) string {
+	s := string(label[1 : len(label)-1])
+	s = strings.Join(strings.Fields(s), " ")
+	// strings.ToLower doesn't do full case folding, ß and ẞ fold to ss
+	s = strings.ToLower(s)
+	s = strings.Replace(s, "ß", "ss", -1)
+	return strings.ToLower(strings.ToUpper(s))
+}
+
+// cmNormalizeURI percent-encodes the characters of a link destination that
+// are not allowed in a URI, leaving existing escapes alone.
+func cmNormalizeURI(s []byte) []byte {
+	const hex = "0123456789ABCDEF"
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAlnum(c) || strings.IndexByte(";/?:@&=+$,-_.!~*'()#", c) >= 0 ||
+			(c == '%' && i+2 < len(s) && isHexDigit(s[i+1]) && isHexDigit(s[i+2])) {
+			if out != nil {
+				out = append(out, c)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]byte, 0, len(s)+8), s[:i]...)
+		}
+		out = append(out, '%', hex[c>>4], hex[c&0xf])
+	}
+	if out == nil {
+		return s
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package parser
+
+//go:generate go run emoji_gen.go
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// EmojiFunc resolves an emoji shortcode, without the colons, to a node, e.g.
+// an *ast.Image for custom emoji. It returns nil if name isn't a custom
+// emoji.
+type EmojiFunc func(name string) ast.Node
+
+// isEmojiChar returns true if c can be part of an emoji shortcode.
+func isEmojiChar(c byte) bool {
+	return isAlnum(c) || c == '_' || c == '+' || c == '-'
+}
+
+// emoji parses an emoji shortcode like :smile: or :+1:. The shortcode is
+// looked up with Options.EmojiFn first and then in Options.Emojis, or
+// EmojiShortcodes if that isn't set.
+func emoji(p *Parser, data []byte, offset int) (int, ast.Node) {
+	// :smile: in the middle of a word or a URL isn't an emoji
+	if offset > 0 && isAlnum(data[offset-1]) {
+		return 0, nil
+	}
+	wordStart := offset
+	for wordStart > 0 && !IsSpace(data[wordStart-1]) {
+		wordStart--
+	}
+	wordEnd := offset
+	for wordEnd < len(data) && !IsSpace(data[wordEnd]) {
+		wordEnd++
+	}
+	if bytes.Contains(data[wordStart:wordEnd], []byte("://")) {
+		return 0, nil
+	}
+
+	data = data[offset:]
+	i := 1
+	for i < len(data) && isEmojiChar(data[i]) {
+		i++
+	}
+	if i == 1 || i >= len(data) || data[i] != ':' {
+		return 0, nil
+	}
+	name := string(data[1:i])
+	end := i + 1
+
+	if p.Opts.EmojiFn != nil {
+		if node := p.Opts.EmojiFn(name); node != nil {
+			return end, node
+		}
+	}
+	emojis := p.Opts.Emojis
+	if emojis == nil {
+		emojis = EmojiShortcodes
+	}
+	if s, ok := emojis[name]; ok {
+		return end, newTextNode([]byte(s))
+	}
+	return 0, nil
+}
@@ -0,0 +1,94 @@
+//go:build ignore
+// +build ignore
+
+// emoji_gen.go generates emoji_table.go from the emoji database of gemoji,
+// GitHub's emoji library. Run it with go generate, or with
+//
+//	go run emoji_gen.go -db emoji.json
+//
+// to use a downloaded copy of db/emoji.json.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// gemojiVersion is the release of gemoji the table is generated from
+const gemojiVersion = "v4.1.0"
+
+var dbURL = "https://raw.githubusercontent.com/github/gemoji/" + gemojiVersion + "/db/emoji.json"
+
+// gemoji is an entry of db/emoji.json
+type gemoji struct {
+	Emoji   string   `json:"emoji"`
+	Aliases []string `json:"aliases"`
+}
+
+func readDB(path string) ([]byte, error) {
+	if path != "" {
+		return ioutil.ReadFile(path)
+	}
+	resp, err := http.Get(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", dbURL, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func main() {
+	db := flag.String("db", "", "gemoji db/emoji.json, downloaded if not set")
+	out := flag.String("o", "emoji_table.go", "output file")
+	flag.Parse()
+
+	d, err := readDB(*db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var emojis []gemoji
+	if err := json.Unmarshal(d, &emojis); err != nil {
+		log.Fatal(err)
+	}
+	table := map[string]string{}
+	for _, e := range emojis {
+		for _, alias := range e.Aliases {
+			table[alias] = e.Emoji
+		}
+	}
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by emoji_gen.go from gemoji %s; DO NOT EDIT.\n\n", gemojiVersion)
+	buf.WriteString("package parser\n\n")
+	buf.WriteString("// EmojiShortcodes maps emoji shortcodes, without the colons, to Unicode\n")
+	buf.WriteString("// emoji. It has GitHub's shortcodes, from gemoji " + gemojiVersion + ". It's used by the\n")
+	buf.WriteString("// Emoji extension unless Options.Emojis is set.\n")
+	buf.WriteString("var EmojiShortcodes = map[string]string{\n")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q: %+q,\n", name, table[name])
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestEmoji(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Hi :smile:!", "Hi \U0001f604!"},
+		{":+1: :-1: :rocket:", "\U0001f44d \U0001f44e \U0001f680"},
+		{":nope: :smile", ":nope: :smile"},
+		{"a::smile:", "a:\U0001f604"},
+		{"time 10:30:45", "time 10:30:45"},
+		{"word:smile:", "word:smile:"},
+		{"example.com/:smile:", "example.com/\U0001f604"},
+		{"http://example.com/:smile:", "http://example.com/:smile:"},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(Emoji)
+		doc := p.Parse([]byte(test.input))
+		var got string
+		ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+			if text, ok := node.(*ast.Text); ok && entering {
+				got += string(text.Literal)
+			}
+			return ast.GoToNext
+		})
+		if got != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestEmojiCodeSpan(t *testing.T) {
+	p := NewWithExtensions(Emoji)
+	doc := p.Parse([]byte("`:smile:`"))
+	var code *ast.Code
+	for _, child := range doc.GetChildren()[0].GetChildren() {
+		if c, ok := child.(*ast.Code); ok {
+			code = c
+		}
+	}
+	if code == nil {
+		t.Fatalf("expected a code span, got:\n%s", ast.ToString(doc))
+	}
+	if string(code.Literal) != ":smile:" {
+		t.Errorf("expected %q, got %q", ":smile:", code.Literal)
+	}
+}
+
+func TestEmojiOptions(t *testing.T) {
+	p := NewWithExtensions(Emoji)
+	p.Opts.Emojis = map[string]string{"yes": "\u2705"}
+	p.Opts.EmojiFn = func(name string) ast.Node {
+		if name != "our-logo" {
+			return nil
+		}
+		img := &ast.Image{Destination: []byte("/emoji/our-logo.png"), Title: []byte(name)}
+		ast.AppendChild(img, &ast.Text{Leaf: ast.Leaf{Literal: []byte(name)}})
+		return img
+	}
+	doc := p.Parse([]byte(":our-logo: :yes: :smile:"))
+	var img *ast.Image
+	var got string
+	for _, child := range doc.GetChildren()[0].GetChildren() {
+		switch n := child.(type) {
+		case *ast.Image:
+			img = n
+		case *ast.Text:
+			got += string(n.Literal)
+		}
+	}
+	if img == nil {
+		t.Fatalf("expected an image, got:\n%s", ast.ToString(doc))
+	}
+	if string(img.Destination) != "/emoji/our-logo.png" {
+		t.Errorf("expected image destination %q, got %q", "/emoji/our-logo.png", img.Destination)
+	}
+	if exp := " \u2705 :smile:"; got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}
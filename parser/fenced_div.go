@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// fencedDivFence parses a line of a fenced div that starts with 3 or more
+// colons. It returns the number of colons and the rest of the line, which
+// is empty for a closing fence, or 0 if it's not a fence.
+func fencedDivFence(line []byte) (int, []byte) {
+	i := 0
+	for i < len(line) && i < 3 && line[i] == ' ' {
+		i++
+	}
+	n := 0
+	for i+n < len(line) && line[i+n] == ':' {
+		n++
+	}
+	if n < 3 {
+		return 0, nil
+	}
+	return n, bytes.TrimSpace(line[i+n:])
+}
+
+// isFencedDivFence returns true if data starts with a fenced div fence, so
+// it's not e.g. a definition
+func (p *Parser) isFencedDivFence(data []byte) bool {
+	if p.extensions&FencedDivs == 0 {
+		return false
+	}
+	n, _ := fencedDivFence(data[:skipUntilChar(data, 0, '\n')])
+	return n > 0
+}
+
+// fencedDiv parses a fenced div. The opening fence has a name, arguments
+// and an attribute, all optional but at least one of them. Fenced divs
+// nest, a closing fence closes the innermost fenced div that was opened
+// with as many or fewer colons.
+//
+//	:::: note {#intro}
+//	::: warning
+//	Be careful.
+//	:::
+//	::::
+func (p *Parser) fencedDiv(data []byte) int {
+	end := skipUntilChar(data, 0, '\n')
+	n, rest := fencedDivFence(data[:end])
+	if n == 0 || len(rest) == 0 {
+		return 0
+	}
+	closing, ok := p.fencedDivEnds[&data[0]]
+	if !ok || closing >= len(data) {
+		p.fencedDivClosings(data)
+		closing = p.fencedDivEnds[&data[0]]
+	}
+	if closing == 0 {
+		return 0
+	}
+
+	beg := skipCharN(data, end, '\n', 1)
+	div := p.fencedDivOpening(rest)
+	p.AddBlock(div)
+	p.Block(data[beg:closing])
+	p.Finalize(div)
+	return skipCharN(data, skipUntilChar(data, closing, '\n'), '\n', 1)
+}
+
+// fencedDivClosings finds the closing fences of all opening fences in data
+// in one pass and records the offset of each one's closing fence from the
+// opening fence in p.fencedDivEnds, by the start of the opening fence line,
+// 0 if it has none. Scanning for the closing fence of every opening fence
+// separately is quadratic when they aren't closed. The closing fence only
+// depends on the lines in between, so it's the same when the fenced div is
+// parsed again in a part of data.
+func (p *Parser) fencedDivClosings(data []byte) {
+	if p.fencedDivEnds == nil {
+		p.fencedDivEnds = map[*byte]int{}
+	}
+	var open []int // start of the opening fences, innermost last
+	var colons []int
+	fenceMarker := ""
+	for i := 0; i < len(data); {
+		lineEnd := skipUntilChar(data, i, '\n')
+		next := skipCharN(data, lineEnd, '\n', 1)
+		if p.extensions&FencedCode != 0 {
+			// fences in code blocks don't count
+			if _, marker := isFenceLine(data[i:next], nil, fenceMarker); marker != "" {
+				if fenceMarker == "" {
+					fenceMarker = marker
+				} else {
+					fenceMarker = ""
+				}
+				i = next
+				continue
+			}
+		}
+		m, r := fencedDivFence(data[i:lineEnd])
+		switch {
+		case m == 0 || fenceMarker != "":
+		case len(r) > 0:
+			open = append(open, i)
+			colons = append(colons, m)
+			p.fencedDivEnds[&data[i]] = 0
+		case len(open) > 0 && m >= colons[len(colons)-1]:
+			// a closing fence closes the innermost fenced div, if it has
+			// as many or more colons
+			start := open[len(open)-1]
+			p.fencedDivEnds[&data[start]] = i - start
+			open, colons = open[:len(open)-1], colons[:len(colons)-1]
+		}
+		i = next
+	}
+}
+
+// fencedDivOpening returns the fenced div of the rest of an opening fence,
+// after the colons. Its attribute is set as the next block attribute.
+func (p *Parser) fencedDivOpening(rest []byte) *ast.FencedDiv {
+	// trailing colons are optional
+	if trimmed := bytes.TrimRight(rest, ":"); len(trimmed) < len(rest) && len(trimmed) > 0 &&
+		(trimmed[len(trimmed)-1] == ' ' || trimmed[len(trimmed)-1] == '\t') {
+		rest = bytes.TrimSpace(trimmed)
+	}
+	if i := bytes.IndexByte(rest, '{'); i >= 0 && rest[len(rest)-1] == '}' {
+		if len(p.attribute(rest[i:])) == 0 {
+			rest = bytes.TrimSpace(rest[:i])
+		}
+	}
+	div := &ast.FencedDiv{}
+	if len(rest) > 0 && rest[0] != '{' {
+		i := bytes.IndexAny(rest, " \t")
+		if i < 0 {
+			i = len(rest)
+		}
+		div.Name = string(rest[:i])
+		div.Args = string(bytes.TrimSpace(rest[i:]))
+	} else {
+		div.Args = string(rest)
+	}
+	return div
+}
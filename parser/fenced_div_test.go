@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestFencedDivs(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   string
+	}{
+		{
+			"::: warning\nBe careful.\n:::\n",
+			"FencedDiv\n  Paragraph\n    Text 'Be careful.'\n",
+		},
+		{
+			":::: note {#n}\n::: danger\ninner\n:::\n::::\n",
+			"FencedDiv\n  FencedDiv\n    Paragraph\n      Text 'inner'\n",
+		},
+		{
+			// a closing fence with fewer colons doesn't close the outer div
+			":::: outer\n::: inner\na\n:::\nb\n:::\n::::\n",
+			"FencedDiv\n  FencedDiv\n    Paragraph\n      Text 'a'\n  Paragraph\n    Text 'b\\n:::'\n",
+		},
+		{
+			"::: Warning ::::::\nwarn\n\n::: Danger\nin\n:::\n::::::::::::\n",
+			"FencedDiv\n  Paragraph\n    Text 'warn'\n  FencedDiv\n    Paragraph\n      Text 'in'\n",
+		},
+		{
+			"::: code\n```\n:::\n```\n:::\n",
+			"FencedDiv\n  CodeBlock: ':::\\n'\n",
+		},
+		{
+			"::: unclosed\ntext\n",
+			"Paragraph\n  Text '::: unclosed\\ntext'\n",
+		},
+		{
+			":::\ntext\n:::\n",
+			"Paragraph\n  Text ':::\\ntext\\n:::'\n",
+		},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions | FencedDivs)
+		doc := p.Parse([]byte(test.input))
+		if got := ast.ToString(doc); got != test.exp {
+			t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", test.input, test.exp, got)
+		}
+	}
+}
+
+func TestFencedDivOpening(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		args  string
+		id    string
+		class string
+	}{
+		{"::: warning\n", "warning", "", "", ""},
+		{"::: youtube abc start=10\n", "youtube", "abc start=10", "", ""},
+		{"::: {#id .sidebar}\n", "", "", "id", "sidebar"},
+		{"::: note Title {#id}\n", "note", "Title", "id", ""},
+		{"::: note :::\n", "note", "", "", ""},
+		{"::: note {bad}\n", "note", "{bad}", "", ""},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions | FencedDivs)
+		doc := p.Parse([]byte(test.input + "text\n:::\n"))
+		div, ok := doc.GetChildren()[0].(*ast.FencedDiv)
+		if !ok {
+			t.Errorf("%q: expected a fenced div, got %T", test.input, doc.GetChildren()[0])
+			continue
+		}
+		if div.Name != test.name || div.Args != test.args {
+			t.Errorf("%q: got name %q args %q, expected %q %q", test.input, div.Name, div.Args, test.name, test.args)
+		}
+		var id, class string
+		if div.Attribute != nil {
+			id = string(div.Attribute.ID)
+			if len(div.Attribute.Classes) > 0 {
+				class = string(div.Attribute.Classes[0])
+			}
+		}
+		if id != test.id || class != test.class {
+			t.Errorf("%q: got id %q class %q, expected %q %q", test.input, id, class, test.id, test.class)
+		}
+	}
+}
+
+// TestFencedDivScaling guards against looking for the closing fence of
+// every opening fence separately, which took 15s for unclosed fences.
+func TestFencedDivScaling(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("::: a\n\n", 20000),
+		strings.Repeat("::: a\n", 20000) + strings.Repeat(":::\n", 20000),
+	}
+	for _, input := range inputs {
+		start := time.Now()
+		NewWithExtensions(CommonExtensions | FencedDivs).Parse([]byte(input))
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("parsing %q... took %s, expected it to be linear", input[:12], elapsed)
+		}
+	}
+}
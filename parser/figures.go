@@ -1,8 +1,6 @@
 package parser
 
 import (
-	"bytes"
-
 	"github.com/gomarkdown/markdown/ast"
 )
 
@@ -66,7 +64,7 @@ func (p *Parser) figureBlock(data []byte, doRender bool) int {
 		return 0
 	}
 
-	var raw bytes.Buffer
+	raw := p.newSourceBuffer()
 
 	for {
 		// safe to assume beg < len(data)
@@ -106,6 +104,7 @@ func (p *Parser) figureBlock(data []byte, doRender bool) int {
 	if captionContent, id, consumed := p.caption(data[beg:], []byte("Figure: ")); consumed > 0 {
 		caption := &ast.Caption{}
 		p.Inline(caption, captionContent)
+		p.setRange(caption, captionContent)
 
 		figure.HeadingID = id
 
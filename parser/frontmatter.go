@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// DecodeFrontMatterFunc decodes the front matter of a document, in format,
+// into a map, e.g. with a YAML or TOML library.
+type DecodeFrontMatterFunc func(format ast.FrontMatterFormats, data []byte) (map[string]interface{}, error)
+
+var frontMatterDelims = []struct {
+	delim  string
+	format ast.FrontMatterFormats
+}{
+	{"---", ast.FrontMatterYAML},
+	{"+++", ast.FrontMatterTOML},
+	{";;;", ast.FrontMatterJSON},
+}
+
+// frontMatter parses the front matter at the start of the document,
+// between two lines with only ---, +++ or ;;; on them, and returns the
+// number of bytes consumed. YAML front matter can also end with "...".
+//
+//	---
+//	title: My Post
+//	---
+func (p *Parser) frontMatter(data []byte) int {
+	line := func(i int) (int, []byte) {
+		end := skipUntilChar(data, i, '\n')
+		l := bytes.TrimRight(data[i:end], " \t")
+		if end < len(data) {
+			end++
+		}
+		return end, l
+	}
+
+	start, first := line(0)
+	format := ast.FrontMatterNone
+	for _, d := range frontMatterDelims {
+		if string(first) == d.delim {
+			format = d.format
+		}
+	}
+	if format == ast.FrontMatterNone {
+		return 0
+	}
+
+	for i := start; i < len(data); {
+		end, l := line(i)
+		if bytes.Equal(l, first) || (format == ast.FrontMatterYAML && string(l) == "...") {
+			node := &ast.FrontMatter{Format: format}
+			node.Literal = data[start:i]
+			p.setRange(node, data[:end])
+			p.AddBlock(node)
+			p.frontMatterNode = node
+			return end
+		}
+		i = end
+	}
+	return 0
+}
+
+// FrontMatter returns the front matter of the parsed document, or nil if it
+// has none. It's only parsed with the FrontMatter extension.
+func (p *Parser) FrontMatter() *ast.FrontMatter {
+	return p.frontMatterNode
+}
+
+// DecodeFrontMatter decodes the front matter of the parsed document with
+// Opts.DecodeFrontMatterFn. Without it only JSON front matter can be
+// decoded. It returns nil if there's no front matter.
+func (p *Parser) DecodeFrontMatter() (map[string]interface{}, error) {
+	node := p.frontMatterNode
+	if node == nil {
+		return nil, nil
+	}
+	if p.Opts.DecodeFrontMatterFn != nil {
+		return p.Opts.DecodeFrontMatterFn(node.Format, node.Literal)
+	}
+	if node.Format != ast.FrontMatterJSON {
+		return nil, fmt.Errorf("parser: no decoder for %s front matter, set Options.DecodeFrontMatterFn", node.Format)
+	}
+	res := map[string]interface{}{}
+	if len(bytes.TrimSpace(node.Literal)) == 0 {
+		return res, nil
+	}
+	if err := json.Unmarshal(node.Literal, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
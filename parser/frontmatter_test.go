@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestFrontMatter(t *testing.T) {
+	tests := []struct {
+		input   string
+		format  ast.FrontMatterFormats
+		literal string
+	}{
+		{"---\ntitle: x\n\ntags: [a]\n---\n# A\n", ast.FrontMatterYAML, "title: x\n\ntags: [a]\n"},
+		{"---\ntitle: x\n...\n", ast.FrontMatterYAML, "title: x\n"},
+		{"+++ \ntitle = \"x\"\n+++", ast.FrontMatterTOML, "title = \"x\"\n"},
+		{";;;\n{\"title\": \"x\"}\n;;;\n", ast.FrontMatterJSON, "{\"title\": \"x\"}\n"},
+		{"---\n---\ntext\n", ast.FrontMatterYAML, ""},
+
+		// not front matter
+		{"---\nnot closed\n", ast.FrontMatterNone, ""},
+		{"+++\ntitle\n---\n", ast.FrontMatterNone, ""},
+		{"text\n\n---\na\n---\n", ast.FrontMatterNone, ""},
+		{"----\na\n----\n", ast.FrontMatterNone, ""},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions | FrontMatter)
+		doc := p.Parse([]byte(test.input))
+		node := p.FrontMatter()
+		if test.format == ast.FrontMatterNone {
+			if node != nil {
+				t.Errorf("%q: parsed front matter %q", test.input, node.Literal)
+			}
+			continue
+		}
+		if node == nil {
+			t.Errorf("%q: no front matter", test.input)
+			continue
+		}
+		if doc.GetChildren()[0] != node {
+			t.Errorf("%q: front matter isn't the first node", test.input)
+		}
+		if node.Format != test.format || string(node.Literal) != test.literal {
+			t.Errorf("%q: got %s %q, expected %s %q", test.input, node.Format, node.Literal, test.format, test.literal)
+		}
+	}
+}
+
+func TestFrontMatterDisabled(t *testing.T) {
+	p := NewWithExtensions(CommonExtensions)
+	doc := p.Parse([]byte("---\ntitle: x\n---\n"))
+	if p.FrontMatter() != nil {
+		t.Error("front matter parsed without the extension")
+	}
+	if _, ok := doc.GetChildren()[0].(*ast.HorizontalRule); !ok {
+		t.Errorf("expected a horizontal rule, got %T", doc.GetChildren()[0])
+	}
+}
+
+func TestDecodeFrontMatter(t *testing.T) {
+	p := NewWithExtensions(FrontMatter)
+	p.Parse([]byte(";;;\n{\"title\": \"x\", \"n\": 1}\n;;;\n"))
+	m, err := p.DecodeFrontMatter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := map[string]interface{}{"title": "x", "n": 1.0}; !reflect.DeepEqual(m, exp) {
+		t.Errorf("got %v, expected %v", m, exp)
+	}
+
+	p = NewWithExtensions(FrontMatter)
+	p.Parse([]byte("---\ntitle: x\n---\n"))
+	if _, err := p.DecodeFrontMatter(); err == nil {
+		t.Error("expected an error decoding YAML without a decoder")
+	}
+
+	errDecode := errors.New("decode")
+	p = NewWithExtensions(FrontMatter)
+	p.Opts.DecodeFrontMatterFn = func(format ast.FrontMatterFormats, data []byte) (map[string]interface{}, error) {
+		if format != ast.FrontMatterYAML || string(data) != "title: x\n" {
+			t.Errorf("got %s %q", format, data)
+		}
+		return nil, errDecode
+	}
+	p.Parse([]byte("---\ntitle: x\n---\n"))
+	if _, err := p.DecodeFrontMatter(); err != errDecode {
+		t.Errorf("got error %v, expected %v", err, errDecode)
+	}
+
+	p = NewWithExtensions(FrontMatter)
+	p.Parse([]byte("text\n"))
+	if m, err := p.DecodeFrontMatter(); m != nil || err != nil {
+		t.Errorf("got %v, %v without front matter", m, err)
+	}
+}
@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"bytes"
 	"path"
 	"path/filepath"
 )
@@ -47,7 +46,9 @@ func (p *Parser) isInclude(data []byte) (filename string, address []byte, consum
 
 func (p *Parser) readInclude(from, file string, address []byte) []byte {
 	if p.Opts.ReadIncludeFn != nil {
-		return p.Opts.ReadIncludeFn(from, file, address)
+		data := p.Opts.ReadIncludeFn(from, file, address)
+		p.registerSource(p.includeStack.Path(file), data)
+		return data
 	}
 
 	return nil
@@ -78,8 +79,8 @@ func (p *Parser) readCodeInclude(from, file string, address []byte) []byte {
 		return nil
 	}
 	ext := path.Ext(file)
-	buf := &bytes.Buffer{}
-	buf.Write([]byte("```"))
+	buf := p.newSourceBuffer()
+	buf.WriteString("```")
 	if ext != "" { // starts with a dot
 		buf.WriteString(" " + ext[1:] + "\n")
 	} else {
@@ -121,6 +122,14 @@ func (i *incStack) Pop() {
 	i.stack = i.stack[:len(i.stack)-1]
 }
 
+// Path returns the path of file new when included from the file on top of the stack.
+func (i *incStack) Path(new string) string {
+	if path.IsAbs(new) {
+		return new
+	}
+	return filepath.Join(i.Last(), new)
+}
+
 func (i *incStack) Last() string {
 	if len(i.stack) == 0 {
 		return ""
@@ -25,6 +25,7 @@ func (p *Parser) Inline(currBlock ast.Node, data []byte) {
 	if p.nesting >= p.maxNesting || len(data) == 0 {
 		return
 	}
+	p.checkContext()
 	p.nesting++
 	beg, end := 0, 0
 
@@ -42,8 +43,14 @@ func (p *Parser) Inline(currBlock ast.Node, data []byte) {
 			continue
 		}
 		// copy inactive chars into the output
-		ast.AppendChild(currBlock, newTextNode(data[beg:end]))
+		p.appendText(currBlock, data[:end], beg)
 		if node != nil {
+			nodeEnd := end + consumed
+			if nodeEnd > n {
+				nodeEnd = n
+			}
+			p.setRange(node, data[end:nodeEnd])
+			p.countNodes(1)
 			ast.AppendChild(currBlock, node)
 		}
 		beg = end + consumed
@@ -54,7 +61,7 @@ func (p *Parser) Inline(currBlock ast.Node, data []byte) {
 		if data[end-1] == '\n' {
 			end--
 		}
-		ast.AppendChild(currBlock, newTextNode(data[beg:end]))
+		p.appendText(currBlock, data[:end], beg)
 	}
 	p.nesting--
 }
@@ -189,6 +196,7 @@ func codeSpan(p *Parser, data []byte, offset int) (int, ast.Node) {
 				Info:     data[syntaxStart : syntaxStart+syntaxLen],
 			}
 			codeblock.Literal = data[i:fEnd]
+			codeblock.Meta = codeBlockMeta(codeblock.Info)
 			return end, codeblock
 		}
 	}
@@ -270,6 +278,12 @@ func maybeInlineFootnoteOrSuper(p *Parser, data []byte, offset int) (int, ast.No
 
 // '[': parse a link or an image or a footnote or a citation
 func link(p *Parser, data []byte, offset int) (int, ast.Node) {
+	if p.extensions&WikiLinks != 0 && data[offset] == '[' {
+		if consumed, node := wikiLink(p, data, offset); node != nil {
+			return consumed, node
+		}
+	}
+
 	// no links allowed inside regular links, footnote, and deferred footnotes
 	if p.InsideLink && (offset > 0 && data[offset-1] == '[' || len(data)-1 > offset && data[offset+1] == '^') {
 		return 0, nil
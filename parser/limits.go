@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Limits bound the resources used to parse a document, for parsing
+// untrusted input. A limit of 0 means no limit.
+//
+// Only ParseContext reports an exceeded limit. Parse returns an empty
+// document instead, so use ParseContext when setting limits.
+type Limits struct {
+	MaxInputSize int // maximum size of the input in bytes
+	MaxNodes     int // maximum number of nodes in the AST
+	// maximum nesting of blocks like block quotes and lists. Without the
+	// CommonMark extension blocks aren't nested deeper than 64 levels, the
+	// document being the first, and deeper ones are text, so a larger
+	// limit is never exceeded.
+	MaxNesting      int
+	MaxReferences   int // maximum number of link reference and footnote definitions
+	MaxIncludeDepth int // maximum nesting of included files
+}
+
+// LimitError is returned by ParseContext when the input exceeds one of
+// the Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded field of Limits, e.g. "MaxNodes"
+	Limit string
+	// Max is the value of the limit
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("markdown: input exceeds %s limit of %d", e.Limit, e.Max)
+}
+
+// parseAbort is the panic value that stops parsing, recovered in
+// ParseContext.
+type parseAbort struct {
+	err error
+}
+
+// ParseContext is like Parse but stops with an error when ctx is done or
+// the input exceeds one of p.Opts.Limits. It then returns a nil document
+// and either ctx.Err() or a *LimitError.
+//
+// Parser is not reusable. Create a new Parser for each ParseContext() call.
+func (p *Parser) ParseContext(ctx context.Context, input []byte) (doc ast.Node, err error) {
+	if p.didParse {
+		panic("Parser is not reusable. Must create new Parser for each Parse() call.")
+	}
+	if max := p.Opts.Limits.MaxInputSize; max > 0 && len(input) > max {
+		p.didParse = true
+		return nil, &LimitError{Limit: "MaxInputSize", Max: max}
+	}
+	if err := ctx.Err(); err != nil {
+		p.didParse = true
+		return nil, err
+	}
+	if ctx.Done() != nil {
+		p.ctx = ctx
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			abort, ok := r.(parseAbort)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, abort.err
+		}
+	}()
+	return p.parse(input), nil
+}
+
+func (p *Parser) abort(err error) {
+	panic(parseAbort{err})
+}
+
+// checkContext stops parsing if the context of ParseContext is done.
+func (p *Parser) checkContext() {
+	if p.ctx == nil {
+		return
+	}
+	if err := p.ctx.Err(); err != nil {
+		p.abort(err)
+	}
+}
+
+// countNodes counts n new nodes against the MaxNodes limit.
+func (p *Parser) countNodes(n int) {
+	p.nodeCount += n
+	if max := p.Opts.Limits.MaxNodes; max > 0 && p.nodeCount > max {
+		p.abort(&LimitError{Limit: "MaxNodes", Max: max})
+	}
+}
+
+// checkNesting stops parsing if blocks are nested deeper than depth.
+func (p *Parser) checkNesting(depth int) {
+	if max := p.Opts.Limits.MaxNesting; max > 0 && depth > max {
+		p.abort(&LimitError{Limit: "MaxNesting", Max: max})
+	}
+}
+
+// checkReferences stops parsing if there are too many reference
+// definitions.
+func (p *Parser) checkReferences() {
+	if max := p.Opts.Limits.MaxReferences; max > 0 && len(p.refs) > max {
+		p.abort(&LimitError{Limit: "MaxReferences", Max: max})
+	}
+}
+
+// checkIncludeDepth stops parsing if files are included deeper than depth.
+func (p *Parser) checkIncludeDepth(depth int) {
+	if max := p.Opts.Limits.MaxIncludeDepth; max > 0 && depth > max {
+		p.abort(&LimitError{Limit: "MaxIncludeDepth", Max: max})
+	}
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestParseContextLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		exts   Extensions
+		limits Limits
+		input  string
+		limit  string // exceeded limit, "" if none
+	}{
+		{"input size", CommonExtensions, Limits{MaxInputSize: 10}, "0123456789", ""},
+		{"input size", CommonExtensions, Limits{MaxInputSize: 10}, "0123456789a", "MaxInputSize"},
+		// document, paragraph, text
+		{"nodes", CommonExtensions, Limits{MaxNodes: 3}, "para\n", ""},
+		{"nodes", CommonExtensions, Limits{MaxNodes: 3}, "*para*\n", "MaxNodes"},
+		{"nodes commonmark", CommonMark, Limits{MaxNodes: 3}, "para\n", ""},
+		{"nodes commonmark", CommonMark, Limits{MaxNodes: 3}, "*para*\n", "MaxNodes"},
+		{"nesting", CommonExtensions, Limits{MaxNesting: 2}, "> > quote\n", ""},
+		{"nesting", CommonExtensions, Limits{MaxNesting: 2}, "> > > quote\n", "MaxNesting"},
+		{"nesting commonmark", CommonMark, Limits{MaxNesting: 2}, "* > quote\n", ""},
+		{"nesting commonmark", CommonMark, Limits{MaxNesting: 2}, "* > * quote\n", "MaxNesting"},
+		// blocks aren't nested deeper than 64, so a larger limit isn't exceeded
+		{"deep nesting", CommonExtensions, Limits{MaxNesting: 100}, strings.Repeat("> ", 101) + "quote\n", ""},
+		// the CommonMark parser has no maximum depth
+		{"deep nesting commonmark", CommonMark, Limits{MaxNesting: 100}, strings.Repeat("> ", 100) + "quote\n", ""},
+		{"deep nesting commonmark", CommonMark, Limits{MaxNesting: 100}, strings.Repeat("> ", 101) + "quote\n", "MaxNesting"},
+		// inline nesting doesn't count
+		{"inline nesting", CommonExtensions, Limits{MaxNesting: 2}, "> > *a **b [c](/d)***\n", ""},
+		{"references", CommonExtensions, Limits{MaxReferences: 2}, "[a]: /a\n[b]: /b\n", ""},
+		{"references", CommonExtensions, Limits{MaxReferences: 2}, "[a]: /a\n[b]: /b\n[c]: /c\n", "MaxReferences"},
+		{"references commonmark", CommonMark, Limits{MaxReferences: 1}, "[a]: /a\n[a]: /b\n", ""},
+		{"references commonmark", CommonMark, Limits{MaxReferences: 1}, "[a]: /a\n[b]: /b\n", "MaxReferences"},
+		{"footnotes", CommonExtensions | Footnotes, Limits{MaxReferences: 1}, "[^a]: a\n[^b]: b\n", "MaxReferences"},
+		{"include depth", CommonExtensions | Includes, Limits{MaxIncludeDepth: 2}, "{{2}}\n", ""},
+		{"include depth", CommonExtensions | Includes, Limits{MaxIncludeDepth: 2}, "{{3}}\n", "MaxIncludeDepth"},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(test.exts)
+		p.Opts.Limits = test.limits
+		// {{n}} includes {{n-1}}
+		p.Opts.ReadIncludeFn = func(from, path string, address []byte) []byte {
+			if path == "1" {
+				return []byte("included\n")
+			}
+			return []byte("{{" + string(path[0]-1) + "}}\n")
+		}
+		doc, err := p.ParseContext(context.Background(), []byte(test.input))
+		if test.limit == "" {
+			if err != nil || doc == nil {
+				t.Errorf("%s: ParseContext(%q) failed with %v", test.name, test.input, err)
+			}
+			continue
+		}
+		lerr, ok := err.(*LimitError)
+		if !ok || lerr.Limit != test.limit {
+			t.Errorf("%s: ParseContext(%q) returned %v, expected %s error", test.name, test.input, err, test.limit)
+		}
+		if doc != nil {
+			t.Errorf("%s: ParseContext(%q) returned a document with an error", test.name, test.input)
+		}
+	}
+}
+
+func TestParseContextDeepNesting(t *testing.T) {
+	// MaxNesting doesn't raise the maximum depth of blocks, 64 levels with
+	// the document
+	p := New()
+	p.Opts.Limits.MaxNesting = 100
+	doc, err := p.ParseContext(context.Background(), []byte(strings.Repeat("> ", 100)+"quote\n"))
+	if err != nil {
+		t.Fatalf("ParseContext() failed with %v", err)
+	}
+	depth := 0
+	for node := doc; len(node.GetChildren()) > 0; node = node.GetChildren()[0] {
+		if _, ok := node.(*ast.BlockQuote); ok {
+			depth++
+		}
+	}
+	if depth != 63 {
+		t.Errorf("ParseContext() parsed %d nested block quotes, expected 63", depth)
+	}
+}
+
+func TestParseContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New().ParseContext(ctx, []byte("text\n")); err != context.Canceled {
+		t.Errorf("ParseContext() with a cancelled context returned %v", err)
+	}
+
+	// cancel while parsing, from the hook called for every block
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	p := New()
+	blocks := 0
+	p.Opts.ParserHook = func(data []byte) (ast.Node, []byte, int) {
+		blocks++
+		if blocks == 2 {
+			cancel()
+		}
+		return nil, nil, 0
+	}
+	input := strings.Repeat("para\n\n", 10)
+	if _, err := p.ParseContext(ctx, []byte(input)); err != context.Canceled {
+		t.Errorf("ParseContext() cancelled while parsing returned %v", err)
+	}
+	if blocks != 2 {
+		t.Errorf("ParseContext() parsed %d blocks after it was cancelled", blocks-2)
+	}
+}
+
+func TestParseLimitsSlowInput(t *testing.T) {
+	input, err := ioutil.ReadFile("../testdata/issue265-slow-binary.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New()
+	p.Opts.Limits.MaxInputSize = 64 * 1024
+	_, err = p.ParseContext(context.Background(), input)
+	if lerr, ok := err.(*LimitError); !ok || lerr.Limit != "MaxInputSize" {
+		t.Errorf("ParseContext() returned %v, expected MaxInputSize error", err)
+	}
+
+	// Parse returns an empty document
+	p = New()
+	p.Opts.Limits.MaxNodes = 10
+	doc := p.Parse([]byte("*a* *b* *c* *d* *e* *f*\n"))
+	if len(doc.GetChildren()) != 0 {
+		t.Errorf("Parse() over a limit returned a document with %d children", len(doc.GetChildren()))
+	}
+}
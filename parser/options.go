@@ -12,6 +12,20 @@ type Options struct {
 	ParserHook    BlockFunc
 	ReadIncludeFn ReadIncludeFunc
 
+	// DecodeFrontMatterFn is used by Parser.DecodeFrontMatter
+	DecodeFrontMatterFn DecodeFrontMatterFunc
+
+	// Emojis replaces EmojiShortcodes as the table used by the Emoji
+	// extension, keys are shortcodes without the colons
+	Emojis map[string]string
+
+	// EmojiFn is tried before Emojis, e.g. to return an image for
+	// custom emoji
+	EmojiFn EmojiFunc
+
+	// Limits bound the resources used to parse a document, see ParseContext
+	Limits Limits
+
 	Flags Flags // Flags allow customizing parser's behavior
 }
 
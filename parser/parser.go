@@ -5,6 +5,7 @@ package parser
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -15,7 +16,7 @@ import (
 )
 
 // Extensions is a bitmask of enabled parser extensions.
-type Extensions int
+type Extensions uint64
 
 // Bit flags representing markdown parsing extensions.
 // Use | (or) to specify multiple extensions.
@@ -45,6 +46,16 @@ const (
 	EmptyLinesBreakList                           // 2 empty lines break out of list
 	Includes                                      // Support including other files.
 	Mmark                                         // Support Mmark syntax, see https://mmark.miek.nl/post/syntax/
+	CommonMark                                    // Follow the CommonMark spec, other extensions are ignored
+	TaskLists                                     // Parse [ ] and [x] at the start of list items as tasks
+	ExtendedAutolink                              // GFM autolinks: www., http://, https://, ftp:// and email addresses
+	TOCPlaceholder                                // Parse [TOC] and {{toc}} as table of contents placeholders
+	Sections                                      // Wrap headings and the content that follows them in ast.Section
+	FrontMatter                                   // Parse YAML, TOML or JSON front matter at the start of the document
+	Alerts                                        // GitHub alerts: block quotes starting with [!NOTE], [!WARNING] etc.
+	FencedDivs                                    // Pandoc style fenced divs and directives between ::: fences
+	Emoji                                         // Translate emoji shortcodes like :smile: into Unicode emoji
+	WikiLinks                                     // Parse [[Page]], [[Page|label]] and [[Page#Section]] links
 
 	CommonExtensions Extensions = NoIntraEmphasis | Tables | FencedCode |
 		Autolink | Strikethrough | SpaceHeadings | HeadingIDs |
@@ -91,6 +102,11 @@ type Parser struct {
 	// the default list of safe URLs.
 	IsSafeURLOverride func(url []byte) bool
 
+	// WikiLinkResolver is an optional function that maps the page of a wiki
+	// link to its destination and reports whether the page exists. By default
+	// the destination is the page name with spaces replaced by underscores.
+	WikiLinkResolver WikiLinkResolverFunc
+
 	Opts Options
 
 	// after parsing, this is AST root of parsed markdown text
@@ -103,6 +119,7 @@ type Parser struct {
 	inlineCallback [256]InlineParser
 	nesting        int
 	maxNesting     int
+	blockDepth     int // nesting of blocks, checked against Limits.MaxNesting
 	InsideLink     bool
 	indexCnt       int // incremented after every index
 
@@ -121,11 +138,27 @@ type Parser struct {
 
 	includeStack *incStack
 
+	// the front matter of the document, with the FrontMatter extension
+	frontMatterNode *ast.FrontMatter
+
+	// closing fences of fenced divs, see fencedDivClosings
+	fencedDivEnds map[*byte]int
+
 	// collect headings where we auto-generated id so that we can
 	// ensure they are unique at the end
 	allHeadingsWithAutoID []*ast.Heading
 
+	// buffers that were parsed, used to find source positions of nodes
+	srcBuffers    []*srcBuffer
+	lastSrcBuffer int
+	// blocks added by the block construct that is being parsed
+	newBlocks []ast.Node
+
 	didParse bool
+
+	// context of ParseContext, nil if it can't be cancelled
+	ctx       context.Context
+	nodeCount int
 }
 
 // New creates a markdown parser with CommonExtensions.
@@ -137,6 +170,12 @@ func New() *Parser {
 	return NewWithExtensions(CommonExtensions)
 }
 
+// NewCommonMark creates a markdown parser that follows the CommonMark spec,
+// see https://spec.commonmark.org/0.31.2/
+func NewCommonMark() *Parser {
+	return NewWithExtensions(CommonMark)
+}
+
 // NewWithExtensions creates a markdown parser with given extensions.
 func NewWithExtensions(extension Extensions) *Parser {
 	p := Parser{
@@ -178,9 +217,20 @@ func NewWithExtensions(extension Extensions) *Parser {
 		p.inlineCallback['M'] = maybeAutoLink
 		p.inlineCallback['F'] = maybeAutoLink
 	}
+	if p.extensions&ExtendedAutolink != 0 {
+		for _, c := range []byte("wWhHfF") {
+			p.inlineCallback[c] = extendedAutolink
+		}
+		// mailto: links are found with the email addresses
+		p.inlineCallback['m'] = nil
+		p.inlineCallback['M'] = nil
+	}
 	if p.extensions&MathJax != 0 {
 		p.inlineCallback['$'] = math
 	}
+	if p.extensions&Emoji != 0 {
+		p.inlineCallback[':'] = emoji
+	}
 
 	return &p
 }
@@ -224,8 +274,10 @@ func (p *Parser) addChild(node ast.Node) ast.Node {
 	for !canNodeContain(p.tip, node) {
 		p.Finalize(p.tip)
 	}
+	p.countNodes(1)
 	ast.AppendChild(p.tip, node)
 	p.tip = node
+	p.newBlocks = append(p.newBlocks, node)
 	return node
 }
 
@@ -233,7 +285,8 @@ func canNodeContain(n ast.Node, v ast.Node) bool {
 	switch n.(type) {
 	case *ast.List:
 		return isListItem(v)
-	case *ast.Document, *ast.BlockQuote, *ast.Aside, *ast.ListItem, *ast.CaptionFigure:
+	case *ast.Document, *ast.BlockQuote, *ast.Aside, *ast.Admonition, *ast.FencedDiv, *ast.ListItem,
+		*ast.CaptionFigure:
 		return !isListItem(v)
 	case *ast.Table:
 		switch v.(type) {
@@ -295,17 +348,37 @@ type Reference struct {
 // You can then convert AST to html using html.Renderer, to some other format
 // using a custom renderer or transform the tree.
 //
+// WARNING: if the input exceeds one of p.Opts.Limits, Parse returns an
+// empty *ast.Document and the error is lost. Use ParseContext, which
+// returns it, when setting limits.
+//
 // Parser is not reusable. Create a new Parser for each Parse() call.
 func (p *Parser) Parse(input []byte) ast.Node {
-	if p.didParse {
-		panic("Parser is not reusable. Must create new Parser for each Parse() call.")
+	doc, err := p.ParseContext(context.Background(), input)
+	if err != nil {
+		return &ast.Document{}
 	}
+	return doc
+}
+
+func (p *Parser) parse(input []byte) ast.Node {
 	p.didParse = true
 
 	// the code only works with Unix CR newlines so to make life easy for
 	// callers normalize newlines
 	input = NormalizeNewlines(input)
+	p.registerSource("", input)
+	p.setRange(p.Doc, input)
+
+	if p.extensions&CommonMark != 0 {
+		p.parseCommonMark(input)
+		inheritRanges(p.Doc)
+		return p.Doc
+	}
 
+	if p.extensions&FrontMatter != 0 {
+		input = input[p.frontMatter(input):]
+	}
 	p.Block(input)
 	// Walk the tree and finish up some of unfinished blocks
 	for p.tip != nil {
@@ -343,6 +416,12 @@ func (p *Parser) Parse(input []byte) ast.Node {
 		taken[id] = true
 	}
 
+	if p.extensions&Sections != 0 {
+		WrapSections(p.Doc)
+	}
+
+	inheritRanges(p.Doc)
+
 	return p.Doc
 }
 
@@ -369,6 +448,7 @@ func (p *Parser) parseRefsToAST() {
 		listItem := block.(*ast.ListItem)
 		listItem.ListFlags = flags | ast.ListTypeOrdered
 		listItem.RefLink = ref.link
+		p.setRange(listItem, ref.src)
 		if ref.hasBlock {
 			flags |= ast.ListItemContainsBlock
 			p.Block(ref.title)
@@ -463,6 +543,7 @@ type reference struct {
 	noteID   int // 0 if not a footnote ref
 	hasBlock bool
 	footnote ast.Node // a link to the Item node within a list of footnotes
+	src      []byte   // the part of the input the reference was defined in
 
 	text []byte // only gets populated by refOverride feature with Reference.Text
 }
@@ -560,6 +641,7 @@ func isReference(p *Parser, data []byte, tabSize int) int {
 	ref := &reference{
 		noteID:   noteID,
 		hasBlock: hasBlock,
+		src:      data[:lineEnd],
 	}
 
 	if noteID > 0 {
@@ -576,6 +658,7 @@ func isReference(p *Parser, data []byte, tabSize int) int {
 	id := string(bytes.ToLower(data[idOffset:idEnd]))
 
 	p.refs[id] = ref
+	p.checkReferences()
 
 	return lineEnd
 }
@@ -673,7 +756,7 @@ func scanFootnote(p *Parser, data []byte, i, indentSize int) (blockStart, blockE
 	}
 
 	// get working buffer
-	var raw bytes.Buffer
+	raw := p.newSourceBuffer()
 
 	// put the first line into the working buffer
 	raw.Write(data[blockEnd:i])
@@ -786,8 +869,7 @@ func IsSafeURL(url []byte) bool {
 	nLink := len(url)
 	for _, path := range Paths {
 		nPath := len(path)
-		linkPrefix := url[:nPath]
-		if nLink >= nPath && bytes.Equal(linkPrefix, path) {
+		if nLink >= nPath && bytes.Equal(url[:nPath], path) {
 			if nLink == nPath {
 				return true
 			} else if IsAlnum(url[nPath]) {
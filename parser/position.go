@@ -0,0 +1,233 @@
+package parser
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Source positions
+//
+// Most of the parsing happens on sub-slices of the input, but block
+// containers like block quotes and list items strip their prefixes and
+// copy the remaining lines into a new buffer before parsing it again.
+// To report positions in the original text every buffer that is handed to
+// Block or Inline is registered together with a list of segments that map
+// byte ranges of the buffer back to the source they came from.
+
+// source is a text that is being parsed: the main input or an included file.
+type source struct {
+	file  string
+	data  []byte
+	lines []int // offsets of line starts, computed lazily
+	line  int   // index into lines of the last lookup
+}
+
+func (s *source) position(offset int) ast.Position {
+	if s.lines == nil {
+		s.lines = append(s.lines, 0)
+		for i, c := range s.data {
+			if c == '\n' {
+				s.lines = append(s.lines, i+1)
+			}
+		}
+	}
+	// nodes are mostly visited in order, try the last line first
+	line := s.line
+	if offset < s.lines[line] || (line+1 < len(s.lines) && offset >= s.lines[line+1]) {
+		line = sort.SearchInts(s.lines, offset+1) - 1
+		s.line = line
+	}
+	return ast.Position{
+		Offset: offset,
+		Line:   line + 1,
+		Column: offset - s.lines[line] + 1,
+	}
+}
+
+// srcSegment maps bytes of a buffer, starting at start, to src
+// starting at offset.
+type srcSegment struct {
+	start  int
+	src    *source
+	offset int
+}
+
+// srcBuffer is a buffer registered with the parser together with the
+// segments describing where its bytes came from.
+type srcBuffer struct {
+	data []byte
+	segs []srcSegment
+}
+
+// within returns the offset of b in buf if b is a sub-slice of buf.
+func within(buf, b []byte) (int, bool) {
+	if cap(b) == 0 || cap(buf) == 0 {
+		return 0, false
+	}
+	off := cap(buf) - cap(b)
+	if off < 0 || off > len(buf) {
+		return 0, false
+	}
+	return off, &buf[:cap(buf)][off] == &b[:cap(b)][0]
+}
+
+// locate maps offset in buffer to a source and an offset in that source.
+func (b *srcBuffer) locate(off int) (*source, int) {
+	i := sort.Search(len(b.segs), func(i int) bool { return b.segs[i].start > off }) - 1
+	if i < 0 {
+		// synthetic bytes before the first segment
+		i = 0
+		off = b.segs[0].start
+	}
+	seg := b.segs[i]
+	return seg.src, seg.offset + off - seg.start
+}
+
+// registerSource registers data as the content of file.
+func (p *Parser) registerSource(file string, data []byte) {
+	src := &source{file: file, data: data}
+	p.registerBuffer(data, []srcSegment{{src: src}})
+}
+
+func (p *Parser) registerBuffer(data []byte, segs []srcSegment) {
+	if len(segs) == 0 || cap(data) == 0 {
+		return
+	}
+	p.srcBuffers = append(p.srcBuffers, &srcBuffer{data: data, segs: segs})
+}
+
+// findBuffer returns the registered buffer that d is a part of and the
+// offset of d in it.
+func (p *Parser) findBuffer(d []byte) (*srcBuffer, int) {
+	n := len(p.srcBuffers)
+	// most lookups are in the main input, otherwise they tend to follow
+	// the order buffers were registered in, so search outwards from the
+	// last hit
+	if n > 0 {
+		if off, ok := within(p.srcBuffers[0].data, d); ok {
+			return p.srcBuffers[0], off
+		}
+	}
+	last := p.lastSrcBuffer
+	for dist := 0; dist < n; dist++ {
+		for _, idx := range [2]int{last + dist, last - dist - 1} {
+			if idx < 1 || idx >= n {
+				continue
+			}
+			buf := p.srcBuffers[idx]
+			if off, ok := within(buf.data, d); ok {
+				p.lastSrcBuffer = idx
+				return buf, off
+			}
+		}
+	}
+	return nil, 0
+}
+
+// sourceRange returns the range of the source that d was parsed from.
+// It returns an invalid range if d is not a part of a registered buffer.
+func (p *Parser) sourceRange(d []byte) ast.SourceRange {
+	buf, off := p.findBuffer(d)
+	if buf == nil {
+		return ast.SourceRange{}
+	}
+	src, start := buf.locate(off)
+	r := ast.SourceRange{
+		File:  src.file,
+		Start: src.position(start),
+	}
+	if len(d) == 0 {
+		r.End = r.Start
+		return r
+	}
+	endSrc, end := buf.locate(off + len(d) - 1)
+	if endSrc != src {
+		end = len(src.data) - 1
+	}
+	r.End = src.position(end + 1)
+	return r
+}
+
+// setRange sets the source range of node to d, unless it already has one.
+func (p *Parser) setRange(node ast.Node, d []byte) {
+	if node == nil || ast.GetSourceRange(node).IsValid() {
+		return
+	}
+	if r := p.sourceRange(d); r.IsValid() {
+		ast.SetSourceRange(node, r)
+	}
+}
+
+// endBlocks sets the range of blocks added since mark, that don't have one
+// yet, to the part of start that was consumed up to rest.
+func (p *Parser) endBlocks(mark int, start, rest []byte) {
+	if mark >= len(p.newBlocks) {
+		return
+	}
+	consumed := start[:len(start)-len(rest)]
+	// don't include trailing blank lines
+	end := len(consumed)
+	for end > 0 && IsSpace(consumed[end-1]) {
+		end--
+	}
+	for _, node := range p.newBlocks[mark:] {
+		p.setRange(node, consumed[:end])
+	}
+	p.newBlocks = p.newBlocks[:mark]
+}
+
+// inheritRanges gives nodes the parser synthesized without a source of their
+// own the range of their parent.
+func inheritRanges(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		parent := node.GetParent()
+		if parent != nil && !ast.GetSourceRange(node).IsValid() {
+			ast.SetSourceRange(node, ast.GetSourceRange(parent))
+		}
+		return ast.GoToNext
+	})
+}
+
+// sourceBuffer is used instead of bytes.Buffer when building a buffer from
+// parts of the input. It remembers where the written parts came from.
+type sourceBuffer struct {
+	bytes.Buffer
+	p    *Parser
+	segs []srcSegment
+}
+
+func (p *Parser) newSourceBuffer() *sourceBuffer {
+	return &sourceBuffer{p: p}
+}
+
+// Write appends d, which should be a part of a registered buffer.
+func (b *sourceBuffer) Write(d []byte) (int, error) {
+	if buf, off := b.p.findBuffer(d); buf != nil {
+		start := b.Len()
+		// d might span multiple segments of its buffer
+		for i := 0; i < len(d); {
+			src, srcOff := buf.locate(off + i)
+			b.segs = append(b.segs, srcSegment{start: start + i, src: src, offset: srcOff})
+			j := sort.Search(len(buf.segs), func(j int) bool { return buf.segs[j].start > off+i })
+			if j >= len(buf.segs) {
+				break
+			}
+			i = buf.segs[j].start - off
+		}
+	}
+	return b.Buffer.Write(d)
+}
+
+// Bytes returns the content of the buffer and registers it with the parser.
+// The buffer must not be written to afterwards.
+func (b *sourceBuffer) Bytes() []byte {
+	d := b.Buffer.Bytes()
+	b.p.registerBuffer(d, b.segs)
+	b.segs = nil
+	return d
+}
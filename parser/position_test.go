@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func findNode(doc ast.Node, match func(ast.Node) bool) ast.Node {
+	var found ast.Node
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if entering && match(node) {
+			found = node
+			return ast.Terminate
+		}
+		return ast.GoToNext
+	})
+	return found
+}
+
+func findText(doc ast.Node, text string) ast.Node {
+	return findNode(doc, func(node ast.Node) bool {
+		t, ok := node.(*ast.Text)
+		return ok && string(t.Literal) == text
+	})
+}
+
+func TestSourceRange(t *testing.T) {
+	testSourceRange(t, New())
+}
+
+func TestSourceRangeCommonMark(t *testing.T) {
+	testSourceRange(t, NewCommonMark())
+}
+
+func testSourceRange(t *testing.T, p *Parser) {
+	input := "# Title\n\nSome *text* and [a link](/url).\n\n> quoted\n>\n> - item one\n>   continued\n> - item **two**\n"
+	doc := p.Parse([]byte(input))
+
+	tests := []struct {
+		node  ast.Node
+		start string
+		end   string
+		text  string
+	}{
+		{findNode(doc, func(n ast.Node) bool { _, ok := n.(*ast.Heading); return ok }), "1:1", "1:8", "# Title"},
+		{findText(doc, "Title"), "1:3", "1:8", "Title"},
+		{findNode(doc, func(n ast.Node) bool { _, ok := n.(*ast.Emph); return ok }), "3:6", "3:12", "*text*"},
+		{findNode(doc, func(n ast.Node) bool { _, ok := n.(*ast.Link); return ok }), "3:17", "3:31", "[a link](/url)"},
+		{findNode(doc, func(n ast.Node) bool { _, ok := n.(*ast.BlockQuote); return ok }), "5:1", "9:17", input[42 : len(input)-1]},
+		{findText(doc, "quoted"), "5:3", "5:9", "quoted"},
+		{findNode(doc, func(n ast.Node) bool { _, ok := n.(*ast.ListItem); return ok }), "7:3", "8:14", "- item one\n>   continued"},
+		{findText(doc, "two"), "9:12", "9:15", "two"},
+	}
+	for i, test := range tests {
+		if test.node == nil {
+			t.Errorf("%d: node not found", i)
+			continue
+		}
+		r := ast.GetSourceRange(test.node)
+		if r.Start.String() != test.start || r.End.String() != test.end {
+			t.Errorf("%d: %T got %s, want %s-%s", i, test.node, r, test.start, test.end)
+			continue
+		}
+		if test.text != "" && input[r.Start.Offset:r.End.Offset] != test.text {
+			t.Errorf("%d: %T got text %q, want %q", i, test.node, input[r.Start.Offset:r.End.Offset], test.text)
+		}
+	}
+
+	// every node must have a range
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !ast.GetSourceRange(node).IsValid() {
+			t.Errorf("%T has no source range", node)
+		}
+		return ast.GoToNext
+	})
+}
+
+func TestSourceRangeInclude(t *testing.T) {
+	p := NewWithExtensions(CommonExtensions | Includes)
+	p.Opts.ReadIncludeFn = func(from, path string, address []byte) []byte {
+		return []byte("included *text*\n")
+	}
+	doc := p.Parse([]byte("first\n\n{{sub/inc.md}}\n\nlast\n"))
+
+	r := ast.GetSourceRange(findText(doc, "text"))
+	if r.File != "sub/inc.md" || r.Start.String() != "1:11" {
+		t.Errorf("included text: got %s, want sub/inc.md:1:11-1:15", r)
+	}
+	r = ast.GetSourceRange(findText(doc, "last"))
+	if r.File != "" || r.Start.String() != "5:1" {
+		t.Errorf("text after include: got %s, want 5:1-5:5", r)
+	}
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// WrapSections groups each heading at the top level of doc and the content
+// that follows it into an ast.Section, up to the next heading with the same
+// or a lower level. Sections of headings with a higher level are nested in
+// it. Title blocks aren't wrapped, and the footnotes and document matter
+// nodes end all sections.
+//
+// It's done by the parser with the Sections extension, and can be called on
+// an already parsed document.
+func WrapSections(doc ast.Node) {
+	var children []ast.Node
+	var stack []*ast.Section
+	var sectionChildren [][]ast.Node
+
+	add := func(node ast.Node) {
+		if len(stack) == 0 {
+			node.SetParent(doc)
+			children = append(children, node)
+			return
+		}
+		i := len(stack) - 1
+		node.SetParent(stack[i])
+		sectionChildren[i] = append(sectionChildren[i], node)
+	}
+	closeSection := func() {
+		i := len(stack) - 1
+		section := stack[i]
+		section.SetChildren(sectionChildren[i])
+		setSectionRange(section)
+		stack = stack[:i]
+		sectionChildren = sectionChildren[:i]
+	}
+
+	for _, child := range doc.GetChildren() {
+		switch child := child.(type) {
+		case *ast.Heading:
+			if child.IsTitleblock {
+				break
+			}
+			for len(stack) > 0 && stack[len(stack)-1].Level >= child.Level {
+				closeSection()
+			}
+			section := &ast.Section{Level: child.Level}
+			add(section)
+			stack = append(stack, section)
+			sectionChildren = append(sectionChildren, nil)
+			add(child)
+			continue
+		case *ast.Footnotes, *ast.DocumentMatter:
+			for len(stack) > 0 {
+				closeSection()
+			}
+		}
+		add(child)
+	}
+	for len(stack) > 0 {
+		closeSection()
+	}
+	doc.SetChildren(children)
+}
+
+// setSectionRange sets the source range of section from the start of its
+// heading to the end of its last child.
+func setSectionRange(section *ast.Section) {
+	children := section.Children
+	start := ast.GetSourceRange(children[0])
+	end := ast.GetSourceRange(children[len(children)-1])
+	if !start.IsValid() || !end.IsValid() || start.File != end.File {
+		return
+	}
+	section.Source = ast.SourceRange{File: start.File, Start: start.Start, End: end.End}
+}
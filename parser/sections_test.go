@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestSections(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   string
+	}{
+		{
+			"intro\n\n# A\n\na\n\n## B\n\nb\n\n### C\n\n# D\n",
+			"Paragraph\n  Text 'intro'\nSection\n  Heading\n    Text 'A'\n  Paragraph\n    Text 'a'\n" +
+				"  Section\n    Heading\n      Text 'B'\n    Paragraph\n      Text 'b'\n" +
+				"    Section\n      Heading\n        Text 'C'\nSection\n  Heading\n    Text 'D'\n",
+		},
+		{
+			// a higher level heading closes the sections of lower levels
+			"### A\n\n## B\n\n### C\n",
+			"Section\n  Heading\n    Text 'A'\nSection\n  Heading\n    Text 'B'\n" +
+				"  Section\n    Heading\n      Text 'C'\n",
+		},
+		{
+			// only top level headings start sections
+			"# A\n\n> # B\n>\n> b\n",
+			"Section\n  Heading\n    Text 'A'\n  BlockQuote\n    Heading\n      Text 'B'\n    Paragraph\n      Text 'b'\n",
+		},
+		{
+			"% Title\n\n# A\n\na[^1]\n\n[^1]: note\n",
+			"Heading\n  Text 'Title'\nSection\n  Heading\n    Text 'A'\n  Paragraph\n    Text 'a'\n    Link 'url=1'\n" +
+				"Footnotes\nList 'footnotes flags=ordered'\n  ListItem 'flags=ordered start'\n    Text 'note'\n",
+		},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions | Sections | Titleblock | Footnotes)
+		doc := p.Parse([]byte(test.input))
+		got := ast.ToString(doc)
+		if got != test.exp {
+			t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", test.input, test.exp, got)
+		}
+
+		// wrapping again doesn't change anything
+		WrapSections(doc)
+		if got := ast.ToString(doc); got != test.exp {
+			t.Errorf("\nInput   [%#v]\nwrapped again\nGot     [%#v]\n", test.input, got)
+		}
+	}
+}
+
+func TestSectionsSourceRange(t *testing.T) {
+	input := "# A\n\na\n\n## B\n\nb\n\n# C\n"
+	p := NewWithExtensions(CommonExtensions | Sections)
+	doc := p.Parse([]byte(input))
+	section := doc.GetChildren()[0].(*ast.Section)
+	if section.Level != 1 {
+		t.Errorf("level is %d, expected 1", section.Level)
+	}
+	r := ast.GetSourceRange(section)
+	if got := input[r.Start.Offset:r.End.Offset]; got != "# A\n\na\n\n## B\n\nb" {
+		t.Errorf("source is %q", got)
+	}
+}
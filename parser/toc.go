@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// tocPlaceholder parses a table of contents placeholder on a line of its
+// own, [TOC] or {{toc}}, with optional parameters: "section" limits it to
+// the headings in the section it is in and "levels=2-3" or "levels=2" to
+// the heading levels.
+//
+//	[TOC section levels=2-3]
+func (p *Parser) tocPlaceholder(data []byte) int {
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		end = len(data)
+	}
+	line := bytes.TrimRight(data[:end], " \t\r")
+	var params []byte
+	switch {
+	case len(line) >= 5 && bytes.EqualFold(line[:4], []byte("[toc")) && line[len(line)-1] == ']':
+		params = line[4 : len(line)-1]
+	case len(line) >= 7 && bytes.EqualFold(line[:5], []byte("{{toc")) && bytes.HasSuffix(line, []byte("}}")):
+		params = line[5 : len(line)-2]
+	default:
+		return 0
+	}
+	if len(params) > 0 && params[0] != ' ' && params[0] != '\t' {
+		return 0
+	}
+
+	node := &ast.TOC{}
+	for _, param := range bytes.Fields(params) {
+		switch {
+		case string(param) == "section":
+			node.Section = true
+		case bytes.HasPrefix(param, []byte("levels=")):
+			levels := bytes.SplitN(param[len("levels="):], []byte("-"), 2)
+			min, err := strconv.Atoi(string(levels[0]))
+			if err != nil {
+				return 0
+			}
+			max := min
+			if len(levels) == 2 {
+				if max, err = strconv.Atoi(string(levels[1])); err != nil {
+					return 0
+				}
+			}
+			node.MinLevel, node.MaxLevel = min, max
+		default:
+			return 0
+		}
+	}
+	p.AddBlock(node)
+	p.Finalize(node)
+
+	if end < len(data) {
+		end++
+	}
+	return end
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestTOCPlaceholder(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   *ast.TOC // nil if it's not a placeholder
+	}{
+		{"[TOC]", &ast.TOC{}},
+		{"[toc]  \n", &ast.TOC{}},
+		{"{{toc}}", &ast.TOC{}},
+		{"{{TOC section}}", &ast.TOC{Section: true}},
+		{"[TOC levels=2-3]", &ast.TOC{MinLevel: 2, MaxLevel: 3}},
+		{"[TOC section levels=2]", &ast.TOC{Section: true, MinLevel: 2, MaxLevel: 2}},
+		{"[TOC levels=a]", nil},
+		{"[TOC other]", nil},
+		{"[TOCsection]", nil},
+		{"[TOC] text", nil},
+		{"{{toc}", nil},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(TOCPlaceholder)
+		doc := p.Parse([]byte(test.input))
+		node, ok := doc.GetChildren()[0].(*ast.TOC)
+		if test.exp == nil {
+			if ok {
+				t.Errorf("%q: parsed as a placeholder", test.input)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%q: not parsed as a placeholder", test.input)
+			continue
+		}
+		if node.Section != test.exp.Section || node.MinLevel != test.exp.MinLevel || node.MaxLevel != test.exp.MaxLevel {
+			t.Errorf("%q: got %+v, expected %+v", test.input, node, test.exp)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// WikiLinkResolverFunc maps the page of a wiki link to its destination and
+// reports whether the page exists.
+type WikiLinkResolverFunc func(page string) (dest []byte, exists bool)
+
+// defaultWikiLinkResolver links to the page name with spaces replaced by
+// underscores, like MediaWiki, and assumes all pages exist.
+func defaultWikiLinkResolver(page string) ([]byte, bool) {
+	return []byte(strings.Replace(page, " ", "_", -1)), true
+}
+
+// wikiLink parses [[Page]], [[Page|label]], [[Page#Section]] and
+// [[#Section]] links.
+func wikiLink(p *Parser, data []byte, offset int) (int, ast.Node) {
+	if p.InsideLink {
+		return 0, nil
+	}
+	data = data[offset:]
+	if !bytes.HasPrefix(data, []byte("[[")) {
+		return 0, nil
+	}
+	end := bytes.Index(data, []byte("]]"))
+	if end < 0 {
+		return 0, nil
+	}
+	inner := data[2:end]
+	if bytes.ContainsAny(inner, "[]\n") {
+		return 0, nil
+	}
+
+	target, label := inner, []byte(nil)
+	if i := bytes.IndexByte(inner, '|'); i >= 0 {
+		target, label = inner[:i], inner[i+1:]
+	}
+	page, section := target, []byte(nil)
+	if i := bytes.IndexByte(target, '#'); i >= 0 {
+		page, section = target[:i], bytes.TrimSpace(target[i+1:])
+	}
+	page = bytes.TrimSpace(page)
+	if len(page) == 0 && len(section) == 0 {
+		return 0, nil
+	}
+
+	link := &ast.WikiLink{Page: page, Section: section, Label: label}
+	if len(page) > 0 {
+		resolve := p.WikiLinkResolver
+		if resolve == nil {
+			resolve = defaultWikiLinkResolver
+		}
+		dest, exists := resolve(string(page))
+		link.Destination = dest
+		link.Missing = !exists
+	}
+	if len(section) > 0 {
+		// the resolver may return the same slice for every link
+		dest := make([]byte, 0, len(link.Destination)+1+len(section))
+		dest = append(dest, link.Destination...)
+		dest = append(dest, '#')
+		link.Destination = append(dest, sanitizeHeadingID(string(section))...)
+	}
+
+	if len(bytes.TrimSpace(label)) > 0 {
+		p.InsideLink = true
+		p.Inline(link, label)
+		p.InsideLink = false
+	} else {
+		ast.AppendChild(link, newTextNode(bytes.TrimSpace(target)))
+	}
+	return end + 2, link
+}
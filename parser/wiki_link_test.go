@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestWikiLinks(t *testing.T) {
+	tests := []struct {
+		input   string
+		page    string
+		section string
+		label   string
+		dest    string
+	}{
+		{"[[Page]]", "Page", "", "", "Page"},
+		{"[[ Home Page ]]", "Home Page", "", "", "Home_Page"},
+		{"[[Page|the page]]", "Page", "", "the page", "Page"},
+		{"[[Page#Section One]]", "Page", "Section One", "", "Page#section-one"},
+		{"[[#Section|here]]", "", "Section", "here", "#section"},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(WikiLinks)
+		doc := p.Parse([]byte(test.input))
+		var link *ast.WikiLink
+		for _, child := range doc.GetChildren()[0].GetChildren() {
+			if l, ok := child.(*ast.WikiLink); ok {
+				link = l
+			}
+		}
+		if link == nil {
+			t.Errorf("%q: expected a wiki link, got:\n%s", test.input, ast.ToString(doc))
+			continue
+		}
+		if string(link.Page) != test.page || string(link.Section) != test.section ||
+			string(link.Label) != test.label || string(link.Destination) != test.dest {
+			t.Errorf("%q: expected page %q, section %q, label %q, destination %q, got %q, %q, %q, %q",
+				test.input, test.page, test.section, test.label, test.dest,
+				link.Page, link.Section, link.Label, link.Destination)
+		}
+		if link.Missing {
+			t.Errorf("%q: expected the page to exist", test.input)
+		}
+	}
+}
+
+func TestWikiLinkResolver(t *testing.T) {
+	p := NewWithExtensions(WikiLinks)
+	p.WikiLinkResolver = func(page string) ([]byte, bool) {
+		return []byte("/kb/" + page), page == "Known"
+	}
+	doc := p.Parse([]byte("[[Known]] [[Unknown]]"))
+	var links []*ast.WikiLink
+	for _, child := range doc.GetChildren()[0].GetChildren() {
+		if l, ok := child.(*ast.WikiLink); ok {
+			links = append(links, l)
+		}
+	}
+	if len(links) != 2 {
+		t.Fatalf("expected 2 wiki links, got:\n%s", ast.ToString(doc))
+	}
+	if string(links[0].Destination) != "/kb/Known" || links[0].Missing {
+		t.Errorf("expected an existing link to /kb/Known, got %q, missing %v", links[0].Destination, links[0].Missing)
+	}
+	if string(links[1].Destination) != "/kb/Unknown" || !links[1].Missing {
+		t.Errorf("expected a missing link to /kb/Unknown, got %q, missing %v", links[1].Destination, links[1].Missing)
+	}
+}
+
+func TestWikiLinkResolverSharedSlice(t *testing.T) {
+	// a slice with spare capacity, returned for every page
+	home := make([]byte, 0, 64)
+	home = append(home, "/home"...)
+	p := NewWithExtensions(WikiLinks)
+	p.WikiLinkResolver = func(page string) ([]byte, bool) {
+		return home, true
+	}
+	doc := p.Parse([]byte("[[Page#One]] [[Page#Two]] [[Page]]"))
+	var dests []string
+	for _, child := range doc.GetChildren()[0].GetChildren() {
+		if l, ok := child.(*ast.WikiLink); ok {
+			dests = append(dests, string(l.Destination))
+		}
+	}
+	exp := []string{"/home#one", "/home#two", "/home"}
+	if strings.Join(dests, " ") != strings.Join(exp, " ") {
+		t.Errorf("expected destinations %q, got %q", exp, dests)
+	}
+	if spare := home[len(home):cap(home)]; bytes.IndexByte(spare, '#') >= 0 {
+		t.Errorf("the spare capacity of the resolver's slice was written to: %q", spare)
+	}
+}
@@ -0,0 +1,23 @@
+/*
+Package text implements plain text renderer of parsed markdown document,
+e.g. for full-text search, email bodies and link previews.
+
+	import (
+		"github.com/gomarkdown/markdown"
+		"github.com/gomarkdown/markdown/text"
+	)
+
+	opts := text.RendererOptions{
+		Width: 72,
+		Flags: text.LinkURLs,
+	}
+	renderer := text.NewRenderer(opts)
+	txt := markdown.Render(doc, renderer)
+
+The markup is dropped, but paragraphs are separated by blank lines, list
+items keep their bullets and numbers, code blocks are indented, tables are
+written as aligned columns and footnotes as numbered notes at the end.
+
+To make an excerpt, set RendererOptions.Truncate.
+*/
+package text
@@ -0,0 +1,506 @@
+package text
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Flags control optional behavior of text renderer.
+type Flags int
+
+// Text renderer configuration options.
+const (
+	FlagsNone Flags = 0
+	LinkURLs  Flags = 1 << iota // Write links as "text (url)" instead of text only
+)
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of text renderer.
+type RendererOptions struct {
+	// Width wraps lines at Width characters. If 0, paragraphs are written
+	// on one line.
+	Width int
+
+	// Truncate stops the output after Truncate characters, at the end of a
+	// word if there is one, and adds "…". Useful for excerpts. If 0, the
+	// whole document is written.
+	Truncate int
+
+	Flags Flags // Flags allow customizing this renderer's behavior
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer implements Renderer interface for plain text output. It drops
+// the markup but keeps the paragraph and list structure.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	// blocks are written line by line, prefixed with the indentation of
+	// the blocks they are nested in. The first line of a list item starts
+	// with the marker instead.
+	prefixes []string
+	marker   string
+	inline   bytes.Buffer // text of the current block
+	lists    []int        // number of the next item of the nested lists
+	wrote    bool         // anything has been written
+	blank    bool         // write a blank line before the next block
+
+	table      [][]string // rows of the current table
+	row        []string
+	aligns     []ast.CellAlignFlags
+	headerRows int
+
+	written   int // characters written, for Truncate
+	truncated bool
+}
+
+// NewRenderer creates and configures a Renderer object, which
+// satisfies the Renderer interface.
+func NewRenderer(opts RendererOptions) *Renderer {
+	return &Renderer{Opts: opts}
+}
+
+// out writes s to w, truncating the output if Opts.Truncate is set
+func (r *Renderer) out(w io.Writer, s string) {
+	if r.truncated {
+		return
+	}
+	if max := r.Opts.Truncate; max > 0 {
+		n := utf8.RuneCountInString(s)
+		if r.written+n > max {
+			s = truncateWords(s, max-r.written) + "…"
+			r.truncated = true
+		}
+		r.written += n
+	}
+	io.WriteString(w, s)
+}
+
+// truncateWords returns the first n characters of s, without a word cut
+// at the end. A word that is all there is, e.g. a long URL, is cut anyway,
+// and so is CJK text, which has no spaces between words.
+func truncateWords(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			r, _ := utf8.DecodeRuneInString(s[pos:])
+			prev, _ := utf8.DecodeLastRuneInString(s[:pos])
+			s = s[:pos]
+			if !unicode.IsSpace(r) && !isCJK(r) && !isCJK(prev) {
+				// in a word, drop it
+				words := strings.TrimRightFunc(s, func(r rune) bool { return !unicode.IsSpace(r) })
+				if strings.TrimSpace(words) != "" {
+					s = words
+				}
+			}
+			break
+		}
+		i++
+	}
+	return strings.TrimRightFunc(s, unicode.IsSpace)
+}
+
+// isCJK returns true if r is a Chinese or Japanese character, which can
+// be cut after
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+func (r *Renderer) pushPrefix(prefix string) {
+	r.prefixes = append(r.prefixes, prefix)
+}
+
+func (r *Renderer) popPrefix() {
+	r.prefixes = r.prefixes[:len(r.prefixes)-1]
+}
+
+// prefix returns the prefix of the next line
+func (r *Renderer) prefix() string {
+	p := strings.Join(r.prefixes, "")
+	if r.marker != "" {
+		p = p[:len(p)-len(r.marker)] + r.marker
+	}
+	return p
+}
+
+// writeLines writes the lines of a block
+func (r *Renderer) writeLines(w io.Writer, lines []string) {
+	if r.wrote && r.blank {
+		r.out(w, "\n")
+	}
+	for _, line := range lines {
+		r.out(w, strings.TrimRight(r.prefix()+line, " ")+"\n")
+		r.marker = ""
+	}
+	r.wrote = true
+	r.blank = true
+}
+
+// flushInline writes the text of the current block, wrapped at
+// Opts.Width
+func (r *Renderer) flushInline(w io.Writer) {
+	text := strings.TrimSpace(r.inline.String())
+	r.inline.Reset()
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, r.wrap(strings.TrimSpace(line))...)
+	}
+	r.writeLines(w, lines)
+}
+
+// wrap splits s into lines of at most Opts.Width characters, including
+// the prefix
+func (r *Renderer) wrap(s string) []string {
+	width := r.Opts.Width - utf8.RuneCountInString(r.prefix())
+	if r.Opts.Width <= 0 || width <= 0 {
+		return []string{s}
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	return append(lines, line)
+}
+
+func admonitionTitle(node *ast.Admonition) string {
+	if len(node.Title) > 0 {
+		return string(node.Title)
+	}
+	return node.Kind.Title()
+}
+
+func isTightList(node ast.Node) bool {
+	list, ok := node.(*ast.List)
+	return ok && list.Tight
+}
+
+// Paragraph writes ast.Paragraph node
+func (r *Renderer) Paragraph(w io.Writer, para *ast.Paragraph, entering bool) {
+	if entering {
+		return
+	}
+	if _, ok := para.Parent.(*ast.TableCell); ok {
+		return
+	}
+	r.flushInline(w)
+	if item, ok := para.Parent.(*ast.ListItem); ok && isTightList(item.Parent) {
+		r.blank = false
+	}
+}
+
+// List writes ast.List node
+func (r *Renderer) List(w io.Writer, list *ast.List, entering bool) {
+	if !entering {
+		r.lists = r.lists[:len(r.lists)-1]
+		r.blank = true
+		return
+	}
+	start := list.Start
+	if start == 0 {
+		start = 1
+	}
+	r.lists = append(r.lists, start)
+}
+
+// ListItem writes ast.ListItem node
+func (r *Renderer) ListItem(w io.Writer, item *ast.ListItem, entering bool) {
+	if !entering {
+		if r.inline.Len() > 0 {
+			// the text of an item without a paragraph, like a footnote
+			r.flushInline(w)
+			r.blank = false
+		}
+		r.popPrefix()
+		r.marker = ""
+		return
+	}
+	list, _ := item.Parent.(*ast.List)
+	var marker string
+	switch {
+	case item.ListFlags&ast.ListTypeTerm != 0:
+		marker = ""
+	case item.ListFlags&ast.ListTypeDefinition != 0:
+		marker = "    "
+	case list != nil && list.IsFootnotesList:
+		n := r.lists[len(r.lists)-1]
+		r.lists[len(r.lists)-1]++
+		marker = "[" + strconv.Itoa(n) + "] "
+	case item.ListFlags&ast.ListTypeOrdered != 0:
+		n := r.lists[len(r.lists)-1]
+		r.lists[len(r.lists)-1]++
+		marker = strconv.Itoa(n) + ". "
+	case item.IsTask && item.Checked:
+		marker = "- [x] "
+	case item.IsTask:
+		marker = "- [ ] "
+	default:
+		marker = "- "
+	}
+	r.pushPrefix(strings.Repeat(" ", len(marker)))
+	r.marker = marker
+	if item.ListFlags&ast.ListTypeDefinition != 0 && item.ListFlags&ast.ListTypeTerm == 0 {
+		// the definition follows its term
+		r.marker = ""
+		r.blank = false
+	}
+}
+
+// Link writes ast.Link node
+func (r *Renderer) Link(w io.Writer, link *ast.Link, entering bool) ast.WalkStatus {
+	if link.NoteID != 0 {
+		if entering {
+			r.inline.WriteString("[" + strconv.Itoa(link.NoteID) + "]")
+		}
+		return ast.SkipChildren
+	}
+	if entering || r.Opts.Flags&LinkURLs == 0 {
+		return ast.GoToNext
+	}
+	dest := string(link.Destination)
+	if dest == "" || dest[0] == '#' {
+		return ast.GoToNext
+	}
+	text := strings.TrimSpace(string(childrenText(link)))
+	if text == dest || "mailto:"+text == dest {
+		return ast.GoToNext
+	}
+	r.inline.WriteString(" (" + dest + ")")
+	return ast.GoToNext
+}
+
+// childrenText returns the text in the leaves under node
+func childrenText(node ast.Node) []byte {
+	var buf bytes.Buffer
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); leaf != nil && entering {
+			buf.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return buf.Bytes()
+}
+
+// CodeBlock writes ast.CodeBlock node, indented
+func (r *Renderer) CodeBlock(w io.Writer, literal []byte) {
+	code := strings.TrimRight(string(literal), "\n")
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		lines[i] = "    " + line
+	}
+	r.writeLines(w, lines)
+}
+
+// TableCell writes ast.TableCell node
+func (r *Renderer) TableCell(w io.Writer, cell *ast.TableCell, entering bool) {
+	if entering {
+		return
+	}
+	text := strings.Join(strings.Fields(r.inline.String()), " ")
+	r.inline.Reset()
+	r.row = append(r.row, text)
+	if len(r.table) == 0 {
+		r.aligns = append(r.aligns, cell.Align)
+	}
+	for i := 1; i < cell.ColSpan; i++ {
+		r.row = append(r.row, "")
+		if len(r.table) == 0 {
+			r.aligns = append(r.aligns, cell.Align)
+		}
+	}
+}
+
+// TableRow writes ast.TableRow node
+func (r *Renderer) TableRow(w io.Writer, row *ast.TableRow, entering bool) {
+	if entering {
+		return
+	}
+	r.table = append(r.table, r.row)
+	r.row = nil
+	if _, ok := row.Parent.(*ast.TableHeader); ok {
+		r.headerRows = len(r.table)
+	}
+}
+
+// Table writes ast.Table node, as aligned columns
+func (r *Renderer) Table(w io.Writer, table *ast.Table, entering bool) {
+	if entering {
+		r.table, r.aligns, r.headerRows = nil, nil, 0
+		return
+	}
+	var widths []int
+	for _, row := range r.table {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	var lines []string
+	for i, row := range r.table {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			var align ast.CellAlignFlags
+			if j < len(r.aligns) {
+				align = r.aligns[j]
+			}
+			cells[j] = pad(cell, widths[j], align)
+		}
+		lines = append(lines, strings.Join(cells, "  "))
+		if i == r.headerRows-1 {
+			rule := make([]string, len(widths))
+			for j, n := range widths {
+				rule[j] = strings.Repeat("-", n)
+			}
+			lines = append(lines, strings.Join(rule, "  "))
+		}
+	}
+	r.table = nil
+	r.writeLines(w, lines)
+}
+
+// pad pads s with spaces to width characters
+func pad(s string, width int, align ast.CellAlignFlags) string {
+	n := width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	switch align {
+	case ast.TableAlignmentRight:
+		return strings.Repeat(" ", n) + s
+	case ast.TableAlignmentCenter:
+		return strings.Repeat(" ", n/2) + s + strings.Repeat(" ", n-n/2)
+	}
+	return s + strings.Repeat(" ", n)
+}
+
+// RenderNode renders a markdown node to plain text
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	status := r.renderNode(w, node, entering)
+	if r.truncated {
+		return ast.Terminate
+	}
+	return status
+}
+
+func (r *Renderer) renderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Document, *ast.DocumentMatter, *ast.Footnotes, *ast.TOC, *ast.Section,
+		*ast.FrontMatter, *ast.FencedDiv:
+		// do nothing
+	case *ast.Text:
+		// newlines in text are soft breaks
+		r.inline.Write(bytes.Replace(node.Literal, []byte("\n"), []byte(" "), -1))
+	case *ast.Code, *ast.Math, *ast.Subscript, *ast.Superscript:
+		r.inline.Write(node.AsLeaf().Literal)
+	case *ast.Softbreak:
+		r.inline.WriteString(" ")
+	case *ast.Hardbreak:
+		r.inline.WriteString("\n")
+	case *ast.NonBlockingSpace:
+		r.inline.WriteString(" ")
+	case *ast.Emph, *ast.Strong, *ast.Del, *ast.Image:
+		// only the text
+	case *ast.HTMLSpan, *ast.HTMLBlock, *ast.Index, *ast.HorizontalRule:
+		// no text
+	case *ast.Callout:
+		r.inline.WriteString("(" + string(node.ID) + ")")
+	case *ast.Citation:
+		r.inline.WriteString("[" + string(bytes.Join(node.Destination, []byte("; "))) + "]")
+	case *ast.CrossReference:
+		if entering && len(node.GetChildren()) == 0 {
+			r.inline.Write(node.Destination)
+		}
+	case *ast.Link:
+		return r.Link(w, node, entering)
+	case *ast.WikiLink:
+		return r.Link(w, node.AsLink(), entering)
+	case *ast.Paragraph:
+		r.Paragraph(w, node, entering)
+	case *ast.Heading, *ast.Caption:
+		if !entering {
+			r.flushInline(w)
+		}
+	case *ast.BlockQuote, *ast.Aside:
+		if entering {
+			r.pushPrefix("  ")
+		} else {
+			r.popPrefix()
+		}
+	case *ast.Admonition:
+		if entering {
+			r.pushPrefix("  ")
+			r.writeLines(w, []string{admonitionTitle(node)})
+			r.blank = false
+		} else {
+			r.popPrefix()
+		}
+	case *ast.CaptionFigure:
+		// the caption follows its block
+	case *ast.CodeBlock:
+		r.CodeBlock(w, node.Literal)
+	case *ast.MathBlock:
+		if entering {
+			r.CodeBlock(w, bytes.TrimSpace(node.Literal))
+		}
+		return ast.SkipChildren
+	case *ast.List:
+		r.List(w, node, entering)
+	case *ast.ListItem:
+		r.ListItem(w, node, entering)
+	case *ast.Table:
+		r.Table(w, node, entering)
+	case *ast.TableHeader, *ast.TableBody, *ast.TableFooter:
+		// the rows are written by Table
+	case *ast.TableRow:
+		r.TableRow(w, node, entering)
+	case *ast.TableCell:
+		r.TableCell(w, node, entering)
+	default:
+		panic(fmt.Sprintf("Unknown node %T", node))
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader writes nothing
+func (r *Renderer) RenderHeader(w io.Writer, _ ast.Node) {
+}
+
+// RenderFooter writes nothing
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+}
@@ -0,0 +1,113 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func renderString(input string, opts RendererOptions) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Footnotes | parser.OrderedListStart | parser.Alerts)
+	doc := p.Parse([]byte(input))
+	return string(markdown.Render(doc, NewRenderer(opts)))
+}
+
+func doTests(t *testing.T, tests []string, opts RendererOptions) {
+	t.Helper()
+	for i := 0; i < len(tests); i += 2 {
+		got := renderString(tests[i], opts)
+		if got != tests[i+1] {
+			t.Errorf("\nInput:    %q\nExpected: %q\nGot:      %q", tests[i], tests[i+1], got)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []string{
+		"# Title\n\nSome *emph*, **strong** and `code`\non two lines.<br>\n",
+		"Title\n\nSome emph, strong and code on two lines.\n",
+
+		"[link](http://x.org) <http://y.org> [ref](#sec)\n",
+		"link http://y.org ref\n",
+
+		"* a\n* b\n    1. c\n    2. d\n\n7. e\n",
+		"- a\n- b\n  1. c\n  2. d\n\n7. e\n",
+
+		"* a\n\n    second paragraph\n* b\n",
+		"- a\n\n  second paragraph\n\n- b\n",
+
+		"> quote\n>\n> ```\n> code\n> ```\n",
+		"  quote\n\n      code\n",
+
+		"> [!NOTE]\n> Read this.\n",
+		"  Note\n  Read this.\n",
+
+		"Term\n: Definition\n",
+		"Term\n    Definition\n",
+
+		"| Name | Age |\n|:-----|----:|\n| Bob  | 7   |\n| Alice | 23 |\n",
+		"Name   Age\n-----  ---\nBob      7\nAlice   23\n",
+
+		"Note[^1] and note[^2].\n\n[^1]: First.\n[^2]: Second.\n",
+		"Note[1] and note[2].\n\n[1] First.\n[2] Second.\n",
+
+		"<div>html</div>\n\n![alt text](pic.png)\n",
+		"alt text\n",
+	}
+	doTests(t, tests, RendererOptions{})
+}
+
+func TestRenderLinkURLs(t *testing.T) {
+	tests := []string{
+		"[link](http://x.org) <http://y.org> <a@b.org> [ref](#sec)\n",
+		"link (http://x.org) http://y.org a@b.org ref\n",
+	}
+	doTests(t, tests, RendererOptions{Flags: LinkURLs})
+}
+
+func TestRenderWidth(t *testing.T) {
+	tests := []string{
+		"The quick brown fox jumps over the lazy dog.\n",
+		"The quick brown\nfox jumps over the\nlazy dog.\n",
+
+		"* The quick brown fox jumps over the lazy dog.\n",
+		"- The quick brown\n  fox jumps over\n  the lazy dog.\n",
+
+		"Averyveryverylongwordthatdoesntfit here\n",
+		"Averyveryverylongwordthatdoesntfit\nhere\n",
+	}
+	doTests(t, tests, RendererOptions{Width: 18})
+}
+
+func TestRenderTruncate(t *testing.T) {
+	tests := []string{
+		"The quick brown fox jumps over the lazy dog.\n",
+		"The quick brown…",
+
+		"The quick brown\n",
+		"The quick brown\n",
+
+		"# The quick\n\nbrown fox\n",
+		"The quick\n\nbrown…",
+
+		"Thequickbrownfox jumps\n",
+		"Thequickbrownfox…",
+
+		"Thequickbrownfoxjumps over\n",
+		"Thequickbrownfoxju…",
+
+		"See https://example.com/a/long/path\n",
+		"See…",
+
+		"https://example.com/a/long/path\n",
+		"https://example.co…",
+
+		"これは日本語の文章で、単語の間にスペースがありません。\n",
+		"これは日本語の文章で、単語の間にスペ…",
+
+		"Read 日本語の文章で、単語の間にスペース\n",
+		"Read 日本語の文章で、単語の間に…",
+	}
+	doTests(t, tests, RendererOptions{Truncate: 18})
+}
@@ -0,0 +1,16 @@
+/*
+Package toc builds the table of contents of a parsed markdown document.
+
+	import (
+		"github.com/gomarkdown/markdown/parser"
+		"github.com/gomarkdown/markdown/toc"
+	)
+
+	doc := parser.New().Parse(md)
+	entries := toc.Build(doc, toc.Options{MaxLevel: 3, SkipTitleblock: true})
+
+Unlike the html.TOC flag, which writes the table of contents as a <nav>, it
+returns the headings as a tree of Entry, which can be rendered by a template
+or encoded as JSON. The IDs are the ones html.Renderer gives the headings.
+*/
+package toc
@@ -0,0 +1,137 @@
+package toc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Entry is a heading in the table of contents
+type Entry struct {
+	Level    int      `json:"level"`
+	ID       string   `json:"id"`
+	Title    string   `json:"title"` // plain text of the heading
+	Children []*Entry `json:"children,omitempty"`
+}
+
+// Options control which headings are in the table of contents and their IDs
+type Options struct {
+	MinLevel       int  // Headings with a lower level are left out
+	MaxLevel       int  // Headings with a higher level are left out, 0 for no limit
+	SkipTitleblock bool // Leave out the title block of the document
+
+	// If set, only the headings in the section of this heading, up to the
+	// next heading with the same or a lower level, are in the table
+	Section *ast.Heading
+
+	// Added to the IDs, like html.RendererOptions.HeadingIDPrefix and
+	// HeadingIDSuffix, so that they match the rendered headings
+	IDPrefix string
+	IDSuffix string
+}
+
+// UniqueIDs makes heading IDs unique, like html.Renderer does, by adding
+// -1, -2 etc. to IDs that were already used.
+type UniqueIDs map[string]int
+
+// Ensure returns id, or a variant of it that wasn't returned before.
+func (ids UniqueIDs) Ensure(id string) string {
+	for count, found := ids[id]; found; count, found = ids[id] {
+		tmp := fmt.Sprintf("%s-%d", id, count+1)
+
+		if _, tmpFound := ids[tmp]; !tmpFound {
+			ids[id] = count + 1
+			id = tmp
+		} else {
+			id = id + "-1"
+		}
+	}
+
+	if _, found := ids[id]; !found {
+		ids[id] = 0
+	}
+
+	return id
+}
+
+// Build returns the table of contents of doc, the headings nested by
+// level. doc isn't modified. Headings without an ID get "toc_N", like they
+// do with the html.TOC flag, where N counts the headings, not counting
+// title blocks. All IDs are made unique, as html.Renderer makes them, so
+// the IDs match the rendered document.
+func Build(doc ast.Node, opts Options) []*Entry {
+	ids := UniqueIDs{}
+	var roots, stack []*Entry
+	count := 0
+	inSection := false
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		hdr, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		id := hdr.HeadingID
+		if !hdr.IsTitleblock {
+			if id == "" {
+				id = fmt.Sprintf("toc_%d", count)
+			}
+			count++
+		}
+		if id != "" {
+			id = opts.IDPrefix + ids.Ensure(id) + opts.IDSuffix
+		}
+
+		if opts.Section != nil {
+			if hdr == opts.Section {
+				inSection = true
+				return ast.SkipChildren
+			}
+			if hdr.Level <= opts.Section.Level {
+				inSection = false
+			}
+			if !inSection {
+				return ast.SkipChildren
+			}
+		}
+		if hdr.IsTitleblock && opts.SkipTitleblock {
+			return ast.SkipChildren
+		}
+		if hdr.Level < opts.MinLevel || (opts.MaxLevel > 0 && hdr.Level > opts.MaxLevel) {
+			return ast.SkipChildren
+		}
+		entry := &Entry{Level: hdr.Level, ID: id, Title: PlainText(hdr)}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+		return ast.SkipChildren
+	})
+	return roots
+}
+
+// PlainText returns the text of node without markup, e.g. the title of
+// a heading. Raw HTML, index items and citations are left out.
+func PlainText(node ast.Node) string {
+	var buf bytes.Buffer
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.Text, *ast.Code, *ast.Math, *ast.Subscript, *ast.Superscript:
+			buf.Write(node.AsLeaf().Literal)
+		case *ast.Softbreak, *ast.Hardbreak, *ast.NonBlockingSpace:
+			buf.WriteByte(' ')
+		}
+		return ast.GoToNext
+	})
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
@@ -0,0 +1,89 @@
+package toc_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/gomarkdown/markdown/toc"
+)
+
+func parse(input string) ast.Node {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs | parser.Titleblock)
+	return p.Parse([]byte(input))
+}
+
+func toJSON(t *testing.T, entries []*toc.Entry) string {
+	t.Helper()
+	d, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(d)
+}
+
+func TestBuild(t *testing.T) {
+	input := "% Title\n\n# Intro\n\n## The *first* `part`\n\n### Deep\n\n## Intro\n\n# Intro\n\n#### Skipped a level\n"
+	tests := []struct {
+		opts toc.Options
+		exp  string
+	}{
+		{
+			toc.Options{},
+			`[{"level":1,"id":"","title":"Title"},` +
+				`{"level":1,"id":"intro","title":"Intro","children":[` +
+				`{"level":2,"id":"the-first-part","title":"The first part","children":[{"level":3,"id":"deep","title":"Deep"}]},` +
+				`{"level":2,"id":"intro-1","title":"Intro"}]},` +
+				`{"level":1,"id":"intro-2","title":"Intro","children":[{"level":4,"id":"skipped-a-level","title":"Skipped a level"}]}]`,
+		},
+		{
+			toc.Options{SkipTitleblock: true, MaxLevel: 2, IDPrefix: "h-"},
+			`[{"level":1,"id":"h-intro","title":"Intro","children":[` +
+				`{"level":2,"id":"h-the-first-part","title":"The first part"},` +
+				`{"level":2,"id":"h-intro-1","title":"Intro"}]},` +
+				`{"level":1,"id":"h-intro-2","title":"Intro"}]`,
+		},
+		{
+			toc.Options{MinLevel: 2, MaxLevel: 3},
+			`[{"level":2,"id":"the-first-part","title":"The first part","children":[{"level":3,"id":"deep","title":"Deep"}]},` +
+				`{"level":2,"id":"intro-1","title":"Intro"}]`,
+		},
+	}
+	for _, test := range tests {
+		doc := parse(input)
+		got := toJSON(t, toc.Build(doc, test.opts))
+		if got != test.exp {
+			t.Errorf("%+v:\nExpected: %s\nGot:      %s", test.opts, test.exp, got)
+		}
+	}
+}
+
+func TestBuildIDs(t *testing.T) {
+	// headings without an ID get the IDs of the html.TOC flag, the document
+	// is not modified
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	doc := p.Parse([]byte("# A\n\n## B\n"))
+	got := toJSON(t, toc.Build(doc, toc.Options{}))
+	exp := `[{"level":1,"id":"toc_0","title":"A","children":[{"level":2,"id":"toc_1","title":"B"}]}]`
+	if got != exp {
+		t.Errorf("\nExpected: %s\nGot:      %s", exp, got)
+	}
+	if id := doc.GetChildren()[0].(*ast.Heading).HeadingID; id != "" {
+		t.Errorf("HeadingID was changed to %q", id)
+	}
+
+	// the IDs match the rendered headings
+	doc = parse("# Intro\n\n# Intro\n\n# Intro-1\n")
+	entries := toc.Build(doc, toc.Options{IDPrefix: "p-"})
+	opts := html.RendererOptions{HeadingIDPrefix: "p-"}
+	out := string(markdown.Render(doc, html.NewRenderer(opts)))
+	for _, entry := range entries {
+		if !strings.Contains(out, `id="`+entry.ID+`"`) {
+			t.Errorf("ID %q is not in %s", entry.ID, out)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Package xml2rfc implements xml2rfc v3 (RFC 7991) renderer of parsed Mmark
+document.
+
+	import (
+		"github.com/gomarkdown/markdown"
+		"github.com/gomarkdown/markdown/parser"
+		"github.com/gomarkdown/markdown/xml2rfc"
+	)
+
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Mmark)
+	doc := p.Parse(md)
+	opts := xml2rfc.RendererOptions{
+		DocName: "draft-gieben-mmark-00",
+		Authors: []xml2rfc.Author{{Fullname: "Miek Gieben", Initials: "M.", Surname: "Gieben"}},
+	}
+	xml := markdown.Render(doc, xml2rfc.NewRenderer(opts))
+
+{frontmatter}, {mainmatter} and {backmatter} start the <front>, <middle> and
+<back> of the document. Without them the whole document is the <middle>.
+In the front matter the .# Abstract heading starts the <abstract>, other
+special headings start a <note>.
+
+Citations are written as <xref> and the cited documents in the normative and
+informative <references> at the start of the <back>. RFCs and
+Internet-Drafts are included from the IETF bibliography. Cross references are
+<xref>, index items <iref>. Fenced code blocks are <sourcecode>, indented
+code blocks and fenced code blocks with the ascii-art language <artwork>.
+Footnotes, which xml2rfc doesn't have, are written as <cref>.
+*/
+package xml2rfc
@@ -0,0 +1,841 @@
+package xml2rfc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// Author is an author of the document
+type Author struct {
+	Fullname     string
+	Initials     string
+	Surname      string
+	Organization string
+	Email        string
+}
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of xml2rfc renderer.
+type RendererOptions struct {
+	Title          string // Document title, defaults to the title block of the document
+	Abbrev         string // Abbreviated title, used in the page headers
+	DocName        string // Name of the Internet-Draft, e.g. "draft-gieben-mmark-00"
+	Category       string // "std", "bcp", "info", "exp" or "historic", defaults to "info"
+	IPR            string // Intellectual property rights, defaults to "trust200902"
+	SubmissionType string // Defaults to "IETF"
+	Authors        []Author
+
+	// References are the <reference> elements of cited documents, by
+	// anchor. RFCs and Internet-Drafts, e.g. RFC2119 and I-D.ietf-foo, are
+	// included from the IETF bibliography if they aren't here.
+	References map[string]string
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer implements Renderer interface for xml2rfc v3 (RFC 7991) output.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	matter     ast.DocumentMatters
+	sections   []section
+	references []reference
+	inFootnote bool
+}
+
+// section is an open <section>, <abstract> or <note>
+type section struct {
+	level int
+	end   string
+}
+
+// reference is a document cited in the text
+type reference struct {
+	anchor    string
+	normative bool
+}
+
+// NewRenderer creates and configures a Renderer object, which
+// satisfies the Renderer interface.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.Category == "" {
+		opts.Category = "info"
+	}
+	if opts.IPR == "" {
+		opts.IPR = "trust200902"
+	}
+	if opts.SubmissionType == "" {
+		opts.SubmissionType = "IETF"
+	}
+	return &Renderer{Opts: opts}
+}
+
+// Escape writes d to w with the XML special characters escaped.
+func Escape(w io.Writer, d []byte) {
+	start := 0
+	for i, c := range d {
+		var esc string
+		switch c {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '"':
+			esc = "&quot;"
+		default:
+			if !isInvalidXMLChar(c) {
+				continue
+			}
+		}
+		w.Write(d[start:i])
+		io.WriteString(w, esc)
+		start = i + 1
+	}
+	w.Write(d[start:])
+}
+
+// isInvalidXMLChar returns true if c is a control character that's not
+// allowed in XML, not even escaped
+func isInvalidXMLChar(c byte) bool {
+	return c < 0x20 && c != '\t' && c != '\n' && c != '\r'
+}
+
+// EscapeString returns s with the XML special characters escaped.
+func EscapeString(s string) string {
+	var buf bytes.Buffer
+	Escape(&buf, []byte(s))
+	return buf.String()
+}
+
+// Out is a helper to write data to writer
+func (r *Renderer) Out(w io.Writer, d []byte) {
+	w.Write(d)
+}
+
+// Outs is a helper to write data to writer
+func (r *Renderer) Outs(w io.Writer, s string) {
+	io.WriteString(w, s)
+}
+
+// OutOneOf writes first or second depending on outFirst
+func (r *Renderer) OutOneOf(w io.Writer, outFirst bool, first string, second string) {
+	if outFirst {
+		r.Outs(w, first)
+	} else {
+		r.Outs(w, second)
+	}
+}
+
+// attr returns ` name="value"` or "" if value is empty
+func attr(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	return " " + name + `="` + EscapeString(value) + `"`
+}
+
+// cdata writes d as a CDATA section
+func (r *Renderer) cdata(w io.Writer, d []byte) {
+	r.Outs(w, "<![CDATA[")
+	// ]]> ends the section, so it's split in two
+	d = bytes.Replace(d, []byte("]]>"), []byte("]]]]><![CDATA[>"), -1)
+	r.Out(w, bytes.Map(func(c rune) rune {
+		if c < 0x20 && isInvalidXMLChar(byte(c)) {
+			return -1
+		}
+		return c
+	}, d))
+	r.Outs(w, "]]>")
+}
+
+// plainText returns the text of node and its children, without markup
+func plainText(node ast.Node) string {
+	var buf bytes.Buffer
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if leaf := node.AsLeaf(); leaf != nil && entering {
+			buf.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// renderChildren returns the children of node rendered
+func (r *Renderer) renderChildren(node ast.Node) []byte {
+	var buf bytes.Buffer
+	for _, child := range node.GetChildren() {
+		ast.WalkFunc(child, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(&buf, node, entering)
+		})
+	}
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+// closeSections closes the sections with a level of at least level
+func (r *Renderer) closeSections(w io.Writer, level int) {
+	for len(r.sections) > 0 {
+		s := r.sections[len(r.sections)-1]
+		if s.level < level {
+			return
+		}
+		r.Outs(w, s.end)
+		r.sections = r.sections[:len(r.sections)-1]
+	}
+}
+
+// isSectionHeading returns true if hdr isn't nested in a block, like a list
+// or a block quote, so it can start a section
+func isSectionHeading(hdr *ast.Heading) bool {
+	for parent := hdr.Parent; parent != nil; parent = parent.GetParent() {
+		switch parent.(type) {
+		case *ast.Document, *ast.Section, *ast.FencedDiv:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isAbstract returns true if hdr starts the abstract of the document
+func (r *Renderer) isAbstract(hdr *ast.Heading) bool {
+	return hdr.IsSpecial && r.matter == ast.DocumentMatterFront &&
+		strings.EqualFold(plainText(hdr), "abstract")
+}
+
+// Heading writes ast.Heading node. Headings start sections, special
+// headings (.#) start the abstract or notes in the front matter and
+// unnumbered sections elsewhere.
+func (r *Renderer) Heading(w io.Writer, hdr *ast.Heading, entering bool) ast.WalkStatus {
+	if hdr.IsTitleblock {
+		// written in the front
+		return ast.SkipChildren
+	}
+	if !isSectionHeading(hdr) {
+		// sections can't be in lists, block quotes etc.
+		r.OutOneOf(w, entering, "<t><strong>", "</strong></t>\n")
+		return ast.GoToNext
+	}
+	if r.isAbstract(hdr) {
+		if entering {
+			r.closeSections(w, hdr.Level)
+			r.sections = append(r.sections, section{hdr.Level, "</abstract>\n"})
+			r.Outs(w, "<abstract>\n")
+		}
+		return ast.SkipChildren
+	}
+	if !entering {
+		r.Outs(w, "</name>\n")
+		return ast.GoToNext
+	}
+	r.closeSections(w, hdr.Level)
+	if hdr.IsSpecial && r.matter == ast.DocumentMatterFront {
+		r.sections = append(r.sections, section{hdr.Level, "</note>\n"})
+		r.Outs(w, "<note>\n<name>")
+		return ast.GoToNext
+	}
+	r.sections = append(r.sections, section{hdr.Level, "</section>\n"})
+	r.Outs(w, "<section"+attr("anchor", hdr.HeadingID))
+	if hdr.IsSpecial {
+		r.Outs(w, ` numbered="false"`)
+	}
+	r.Outs(w, ">\n<name>")
+	return ast.GoToNext
+}
+
+// setMatter ends the current division of the document and starts m
+func (r *Renderer) setMatter(w io.Writer, m ast.DocumentMatters) {
+	if m == r.matter || m == ast.DocumentMatterNone {
+		return
+	}
+	r.closeSections(w, 0)
+	switch r.matter {
+	case ast.DocumentMatterFront:
+		r.Outs(w, "</front>\n")
+	case ast.DocumentMatterMain:
+		r.Outs(w, "</middle>\n")
+	case ast.DocumentMatterBack:
+		r.Outs(w, "</back>\n")
+	}
+	if r.matter == ast.DocumentMatterFront && m == ast.DocumentMatterBack {
+		// <middle> is required
+		r.Outs(w, "<middle>\n</middle>\n")
+	}
+	r.matter = m
+	switch m {
+	case ast.DocumentMatterFront:
+		r.Outs(w, "<front>\n")
+	case ast.DocumentMatterMain:
+		r.Outs(w, "<middle>\n")
+	case ast.DocumentMatterBack:
+		r.Outs(w, "<back>\n")
+		r.writeReferences(w)
+	}
+}
+
+// Paragraph writes ast.Paragraph node
+func (r *Renderer) Paragraph(w io.Writer, para *ast.Paragraph, entering bool) ast.WalkStatus {
+	if r.inFootnote {
+		return ast.GoToNext
+	}
+	if img := paragraphImage(para); img != nil {
+		if entering {
+			r.Outs(w, "<artwork"+attr("src", string(img.Destination))+attr("alt", plainText(img))+"/>\n")
+		}
+		return ast.SkipChildren
+	}
+	switch parent := para.Parent.(type) {
+	case *ast.TableCell:
+		return ast.GoToNext
+	case *ast.ListItem:
+		list, _ := parent.Parent.(*ast.List)
+		if parent.ListFlags&ast.ListTypeTerm != 0 || (list != nil && list.Tight) {
+			return ast.GoToNext
+		}
+	}
+	r.OutOneOf(w, entering, "<t>", "</t>\n")
+	return ast.GoToNext
+}
+
+// paragraphImage returns the image if it's the only content of para
+func paragraphImage(para *ast.Paragraph) *ast.Image {
+	children := para.GetChildren()
+	if len(children) != 1 {
+		return nil
+	}
+	img, _ := children[0].(*ast.Image)
+	return img
+}
+
+// List writes ast.List node
+func (r *Renderer) List(w io.Writer, list *ast.List, entering bool) ast.WalkStatus {
+	if list.IsFootnotesList {
+		// footnotes are written where they are referenced
+		return ast.SkipChildren
+	}
+	switch {
+	case list.ListFlags&ast.ListTypeDefinition != 0:
+		r.OutOneOf(w, entering, "<dl>\n", "</dl>\n")
+	case list.ListFlags&ast.ListTypeOrdered != 0:
+		start := ""
+		if list.Start > 1 {
+			start = fmt.Sprintf(` start="%d"`, list.Start)
+		}
+		r.OutOneOf(w, entering, "<ol"+start+">\n", "</ol>\n")
+	default:
+		r.OutOneOf(w, entering, "<ul>\n", "</ul>\n")
+	}
+	return ast.GoToNext
+}
+
+// ListItem writes ast.ListItem node
+func (r *Renderer) ListItem(w io.Writer, item *ast.ListItem, entering bool) {
+	switch {
+	case item.ListFlags&ast.ListTypeTerm != 0:
+		r.OutOneOf(w, entering, "<dt>", "</dt>\n")
+	case item.ListFlags&ast.ListTypeDefinition != 0:
+		r.OutOneOf(w, entering, "<dd>", "</dd>\n")
+	default:
+		r.OutOneOf(w, entering, "<li>", "</li>\n")
+	}
+}
+
+// Link writes ast.Link node
+func (r *Renderer) Link(w io.Writer, link *ast.Link, entering bool) ast.WalkStatus {
+	if link.NoteID != 0 {
+		if entering {
+			r.footnote(w, link)
+		}
+		return ast.SkipChildren
+	}
+	dest := string(link.Destination)
+	tag := "eref"
+	if strings.HasPrefix(dest, "#") {
+		tag, dest = "xref", dest[1:]
+	}
+	if len(link.Children) == 0 || isAutolink(link) {
+		if entering {
+			r.Outs(w, "<"+tag+attr("target", dest)+"/>")
+		}
+		return ast.SkipChildren
+	}
+	r.OutOneOf(w, entering, "<"+tag+attr("target", dest)+">", "</"+tag+">")
+	return ast.GoToNext
+}
+
+// isAutolink returns true if the text of link is its destination
+func isAutolink(link *ast.Link) bool {
+	children := link.GetChildren()
+	if len(children) != 1 {
+		return false
+	}
+	text, ok := children[0].(*ast.Text)
+	if !ok {
+		return false
+	}
+	return bytes.Equal(text.Literal, link.Destination) ||
+		bytes.Equal(append([]byte("mailto:"), text.Literal...), link.Destination)
+}
+
+// footnote writes the footnote referenced by link as a <cref>, as
+// xml2rfc has no footnotes
+func (r *Renderer) footnote(w io.Writer, link *ast.Link) {
+	if link.Footnote == nil || r.inFootnote {
+		return
+	}
+	r.inFootnote = true
+	d := r.renderChildren(link.Footnote)
+	r.inFootnote = false
+	r.Outs(w, "<cref>")
+	r.Out(w, d)
+	r.Outs(w, "</cref>")
+}
+
+var sectionSuffix = regexp.MustCompile(`^(?i:section) ([0-9A-Za-z.]+)$`)
+
+// Citation writes ast.Citation node as <xref>s. Suppressed citations are
+// only in the references.
+func (r *Renderer) Citation(w io.Writer, node *ast.Citation) {
+	first := true
+	for i, dest := range node.Destination {
+		if i < len(node.Type) && node.Type[i] == ast.CitationTypeSuppressed {
+			continue
+		}
+		if !first {
+			r.Outs(w, ", ")
+		}
+		first = false
+		var suffix []byte
+		if i < len(node.Suffix) {
+			suffix = node.Suffix[i]
+		}
+		if m := sectionSuffix.FindSubmatch(suffix); m != nil {
+			r.Outs(w, "<xref"+attr("target", string(dest))+attr("section", string(m[1]))+` sectionFormat="of"/>`)
+			continue
+		}
+		r.Outs(w, "<xref"+attr("target", string(dest))+"/>")
+		if len(suffix) > 0 {
+			r.Outs(w, ", ")
+			Escape(w, suffix)
+		}
+	}
+}
+
+// Index writes ast.Index node as <iref>
+func (r *Renderer) Index(w io.Writer, node *ast.Index) {
+	r.Outs(w, "<iref"+attr("item", string(node.Item))+attr("subitem", string(node.Subitem)))
+	if node.Primary {
+		r.Outs(w, ` primary="true"`)
+	}
+	r.Outs(w, "/>")
+}
+
+// codeLanguage returns the language of a code block from its info string
+func codeLanguage(info []byte) string {
+	if i := bytes.IndexAny(info, "\t {"); i >= 0 {
+		info = info[:i]
+	}
+	return string(info)
+}
+
+// CodeBlock writes ast.CodeBlock node. Indented code blocks and fenced code
+// blocks with the ascii-art language are <artwork>, others <sourcecode>.
+func (r *Renderer) CodeBlock(w io.Writer, codeBlock *ast.CodeBlock) {
+	anchor := ""
+	if codeBlock.Attribute != nil && !isCaptionFigure(codeBlock.Parent) {
+		anchor = string(codeBlock.Attribute.ID)
+	}
+	lang := codeLanguage(codeBlock.Info)
+	tag := "sourcecode"
+	if !codeBlock.IsFenced || lang == "ascii-art" || lang == "art" {
+		tag, lang = "artwork", "ascii-art"
+	}
+	r.Outs(w, "<"+tag+attr("anchor", anchor)+attr("type", lang)+">")
+	r.cdata(w, codeBlock.Literal)
+	r.Outs(w, "</"+tag+">\n")
+}
+
+func isCaptionFigure(node ast.Node) bool {
+	_, ok := node.(*ast.CaptionFigure)
+	return ok
+}
+
+// caption returns the caption of a figure, or nil
+func caption(figure ast.Node) *ast.Caption {
+	if !isCaptionFigure(figure) {
+		return nil
+	}
+	for _, child := range figure.GetChildren() {
+		if c, ok := child.(*ast.Caption); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// CaptionFigure writes ast.CaptionFigure node as a <figure>. Tables and
+// quotes write their caption themselves.
+func (r *Renderer) CaptionFigure(w io.Writer, figure *ast.CaptionFigure, entering bool) {
+	for _, child := range figure.Children {
+		switch child.(type) {
+		case *ast.Table, *ast.BlockQuote:
+			return
+		}
+	}
+	if !entering {
+		r.Outs(w, "</figure>\n")
+		return
+	}
+	anchor := figure.HeadingID
+	if anchor == "" && figure.Attribute != nil {
+		anchor = string(figure.Attribute.ID)
+	}
+	r.Outs(w, "<figure"+attr("anchor", anchor)+">\n")
+	if c := caption(figure); c != nil {
+		r.Outs(w, "<name>")
+		r.Out(w, r.renderChildren(c))
+		r.Outs(w, "</name>\n")
+	}
+}
+
+// BlockQuote writes ast.BlockQuote node. The caption of the quote is the
+// quotedFrom attribute.
+func (r *Renderer) BlockQuote(w io.Writer, quote *ast.BlockQuote, entering bool) {
+	if !entering {
+		r.Outs(w, "</blockquote>\n")
+		return
+	}
+	from := ""
+	if c := caption(quote.Parent); c != nil {
+		from = plainText(c)
+	}
+	r.Outs(w, "<blockquote"+attr("quotedFrom", from)+">\n")
+}
+
+// Admonition writes ast.Admonition node as an <aside> starting with its
+// title
+func (r *Renderer) Admonition(w io.Writer, node *ast.Admonition, entering bool) {
+	if !entering {
+		r.Outs(w, "</aside>\n")
+		return
+	}
+	title := node.Title
+	if len(title) == 0 {
+		title = []byte(node.Kind.Title())
+	}
+	r.Outs(w, "<aside>\n<t><strong>")
+	Escape(w, title)
+	r.Outs(w, "</strong></t>\n")
+}
+
+// Table writes ast.Table node
+func (r *Renderer) Table(w io.Writer, table *ast.Table, entering bool) {
+	if !entering {
+		r.Outs(w, "</table>\n")
+		return
+	}
+	anchor := ""
+	if table.Attribute != nil {
+		anchor = string(table.Attribute.ID)
+	}
+	if figure, ok := table.Parent.(*ast.CaptionFigure); ok && figure.HeadingID != "" {
+		anchor = figure.HeadingID
+	}
+	r.Outs(w, "<table"+attr("anchor", anchor)+">\n")
+	if c := caption(table.Parent); c != nil {
+		r.Outs(w, "<name>")
+		r.Out(w, r.renderChildren(c))
+		r.Outs(w, "</name>\n")
+	}
+}
+
+// TableCell writes ast.TableCell node
+func (r *Renderer) TableCell(w io.Writer, cell *ast.TableCell, entering bool) {
+	tag := "td"
+	if cell.IsHeader {
+		tag = "th"
+	}
+	if !entering {
+		r.Outs(w, "</"+tag+">\n")
+		return
+	}
+	r.Outs(w, "<"+tag+attr("align", cell.Align.String()))
+	if cell.ColSpan > 1 {
+		r.Outs(w, fmt.Sprintf(` colspan="%d"`, cell.ColSpan))
+	}
+	r.Outs(w, ">")
+}
+
+// referenceURL returns the URL of the bibxml file of anchor, e.g. RFC2119,
+// or "" if there is none
+func referenceURL(anchor string) string {
+	if strings.HasPrefix(anchor, "RFC") && len(anchor) > 3 && strings.Trim(anchor[3:], "0123456789") == "" {
+		return fmt.Sprintf("https://bib.ietf.org/public/rfc/bibxml/reference.RFC.%04s.xml", anchor[3:])
+	}
+	if strings.HasPrefix(anchor, "I-D.") {
+		return "https://bib.ietf.org/public/rfc/bibxml3/reference." + anchor + ".xml"
+	}
+	return ""
+}
+
+// writeReferences writes the normative and informative references of the
+// cited documents.
+func (r *Renderer) writeReferences(w io.Writer) {
+	for _, normative := range []bool{true, false} {
+		name := "Informative References"
+		if normative {
+			name = "Normative References"
+		}
+		started := false
+		for _, ref := range r.references {
+			if ref.normative != normative {
+				continue
+			}
+			if !started {
+				r.Outs(w, "<references>\n<name>"+name+"</name>\n")
+				started = true
+			}
+			if raw, ok := r.Opts.References[ref.anchor]; ok {
+				r.Outs(w, strings.TrimSpace(raw)+"\n")
+			} else if url := referenceURL(ref.anchor); url != "" {
+				r.Outs(w, `<xi:include href="`+url+`"/>`+"\n")
+			} else {
+				r.Outs(w, "<reference"+attr("anchor", ref.anchor)+">\n<front>\n<title>"+EscapeString(ref.anchor)+"</title>\n</front>\n</reference>\n")
+			}
+		}
+		if started {
+			r.Outs(w, "</references>\n")
+		}
+	}
+	r.references = nil
+}
+
+// collect finds the title and the citations of doc
+func (r *Renderer) collect(doc ast.Node) (title string, hasMatter bool) {
+	seen := map[string]int{}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.Heading:
+			if node.IsTitleblock && title == "" {
+				title = plainText(node)
+			}
+		case *ast.DocumentMatter:
+			hasMatter = true
+		case *ast.Citation:
+			for i, dest := range node.Destination {
+				normative := i < len(node.Type) && node.Type[i] == ast.CitationTypeNormative
+				if j, ok := seen[string(dest)]; ok {
+					r.references[j].normative = r.references[j].normative || normative
+					continue
+				}
+				seen[string(dest)] = len(r.references)
+				r.references = append(r.references, reference{string(dest), normative})
+			}
+		}
+		return ast.GoToNext
+	})
+	return title, hasMatter
+}
+
+// RenderNode renders a markdown node to xml2rfc
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Document, *ast.Section, *ast.FencedDiv:
+		// do nothing
+	case *ast.Text:
+		Escape(w, node.Literal)
+	case *ast.Softbreak:
+		r.Outs(w, "\n")
+	case *ast.Hardbreak:
+		r.Outs(w, "<br/>\n")
+	case *ast.NonBlockingSpace:
+		r.Outs(w, "&#160;")
+	case *ast.Emph:
+		r.OutOneOf(w, entering, "<em>", "</em>")
+	case *ast.Strong:
+		r.OutOneOf(w, entering, "<strong>", "</strong>")
+	case *ast.Del:
+		// there is no strikethrough in xml2rfc
+	case *ast.BlockQuote:
+		r.BlockQuote(w, node, entering)
+	case *ast.Aside:
+		r.OutOneOf(w, entering, "<aside>\n", "</aside>\n")
+	case *ast.Admonition:
+		r.Admonition(w, node, entering)
+	case *ast.Link:
+		return r.Link(w, node, entering)
+	case *ast.WikiLink:
+		return r.Link(w, node.AsLink(), entering)
+	case *ast.CrossReference:
+		if entering {
+			if len(node.Suffix) > 0 {
+				r.Outs(w, "<xref"+attr("target", string(node.Destination))+">")
+				Escape(w, node.Suffix)
+				r.Outs(w, "</xref>")
+			} else {
+				r.Outs(w, "<xref"+attr("target", string(node.Destination))+"/>")
+			}
+		}
+		return ast.SkipChildren
+	case *ast.Citation:
+		r.Citation(w, node)
+	case *ast.Image:
+		if entering {
+			r.Outs(w, "<eref"+attr("target", string(node.Destination))+">")
+			Escape(w, []byte(plainText(node)))
+			r.Outs(w, "</eref>")
+		}
+		return ast.SkipChildren
+	case *ast.Code:
+		r.Outs(w, "<tt>")
+		Escape(w, node.Literal)
+		r.Outs(w, "</tt>")
+	case *ast.CodeBlock:
+		r.CodeBlock(w, node)
+	case *ast.Caption:
+		// written by the figure, table or quote
+		return ast.SkipChildren
+	case *ast.CaptionFigure:
+		r.CaptionFigure(w, node, entering)
+	case *ast.Paragraph:
+		return r.Paragraph(w, node, entering)
+	case *ast.HTMLSpan, *ast.HTMLBlock:
+		// raw HTML isn't XML and has no xml2rfc equivalent
+	case *ast.Heading:
+		return r.Heading(w, node, entering)
+	case *ast.HorizontalRule:
+		// there are no horizontal rules in xml2rfc
+	case *ast.List:
+		return r.List(w, node, entering)
+	case *ast.ListItem:
+		r.ListItem(w, node, entering)
+	case *ast.Table:
+		r.Table(w, node, entering)
+	case *ast.TableCell:
+		r.TableCell(w, node, entering)
+	case *ast.TableHeader:
+		r.OutOneOf(w, entering, "<thead>\n", "</thead>\n")
+	case *ast.TableBody:
+		r.OutOneOf(w, entering, "<tbody>\n", "</tbody>\n")
+	case *ast.TableFooter:
+		r.OutOneOf(w, entering, "<tfoot>\n", "</tfoot>\n")
+	case *ast.TableRow:
+		r.OutOneOf(w, entering, "<tr>\n", "</tr>\n")
+	case *ast.Math:
+		r.Outs(w, "<tt>")
+		Escape(w, node.Literal)
+		r.Outs(w, "</tt>")
+	case *ast.MathBlock:
+		if entering {
+			r.Outs(w, `<artwork type="ascii-art">`)
+			r.cdata(w, bytes.TrimSpace(node.Literal))
+			r.Outs(w, "</artwork>\n")
+		}
+		return ast.SkipChildren
+	case *ast.DocumentMatter:
+		if entering {
+			r.setMatter(w, node.Matter)
+		}
+	case *ast.Callout:
+		r.Outs(w, "(")
+		Escape(w, node.ID)
+		r.Outs(w, ")")
+	case *ast.Index:
+		r.Index(w, node)
+	case *ast.Subscript:
+		r.Outs(w, "<sub>")
+		Escape(w, node.Literal)
+		r.Outs(w, "</sub>")
+	case *ast.Superscript:
+		r.Outs(w, "<sup>")
+		Escape(w, node.Literal)
+		r.Outs(w, "</sup>")
+	case *ast.Footnotes:
+		// footnotes are written where they are referenced
+		return ast.SkipChildren
+	case *ast.TOC:
+		// xml2rfc writes the table of contents
+	case *ast.FrontMatter:
+		// front matter is metadata, not content
+	default:
+		panic(fmt.Sprintf("Unknown node %T", node))
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader writes the <rfc> element and the start of <front> with the
+// title and the authors. Without {frontmatter}, {mainmatter} and
+// {backmatter} the whole document is the <middle>.
+func (r *Renderer) RenderHeader(w io.Writer, doc ast.Node) {
+	title, hasMatter := r.collect(doc)
+	if r.Opts.Title != "" {
+		title = r.Opts.Title
+	}
+	r.Outs(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n")
+	r.Outs(w, `<rfc version="3"`+attr("ipr", r.Opts.IPR)+attr("docName", r.Opts.DocName))
+	r.Outs(w, attr("submissionType", r.Opts.SubmissionType)+attr("category", r.Opts.Category))
+	r.Outs(w, ` xml:lang="en" xmlns:xi="http://www.w3.org/2001/XInclude">`+"\n")
+	r.matter = ast.DocumentMatterFront
+	r.Outs(w, "<front>\n")
+	r.Outs(w, "<title"+attr("abbrev", r.Opts.Abbrev)+">"+EscapeString(title)+"</title>\n")
+	if r.Opts.DocName != "" {
+		r.Outs(w, `<seriesInfo name="Internet-Draft"`+attr("value", r.Opts.DocName)+"/>\n")
+	}
+	for _, a := range r.Opts.Authors {
+		r.Outs(w, "<author"+attr("fullname", a.Fullname)+attr("initials", a.Initials)+attr("surname", a.Surname)+">\n")
+		if a.Organization != "" {
+			r.Outs(w, "<organization>"+EscapeString(a.Organization)+"</organization>\n")
+		}
+		if a.Email != "" {
+			r.Outs(w, "<address>\n<email>"+EscapeString(a.Email)+"</email>\n</address>\n")
+		}
+		r.Outs(w, "</author>\n")
+	}
+	if !hasMatter {
+		r.setMatter(w, ast.DocumentMatterMain)
+	}
+}
+
+// RenderFooter ends the document. The references are written in the
+// <back>, if the document has no {backmatter}.
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+	if r.matter == ast.DocumentMatterFront {
+		r.setMatter(w, ast.DocumentMatterMain)
+	}
+	if len(r.references) > 0 {
+		r.setMatter(w, ast.DocumentMatterBack)
+	}
+	r.closeSections(w, 0)
+	switch r.matter {
+	case ast.DocumentMatterMain:
+		r.Outs(w, "</middle>\n")
+	case ast.DocumentMatterBack:
+		r.Outs(w, "</back>\n")
+	}
+	r.Outs(w, "</rfc>\n")
+}
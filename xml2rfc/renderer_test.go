@@ -0,0 +1,225 @@
+package xml2rfc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func renderString(input string, opts RendererOptions) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Mmark | parser.Footnotes |
+		parser.Attributes | parser.SuperSubscript | parser.Titleblock | parser.OrderedListStart)
+	doc := p.Parse([]byte(input))
+	return string(markdown.Render(doc, NewRenderer(opts)))
+}
+
+// middle returns the content of <middle>
+func middle(s string) string {
+	start := strings.Index(s, "<middle>\n")
+	end := strings.Index(s, "</middle>\n")
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start+len("<middle>\n") : end]
+}
+
+func doTests(t *testing.T, tests []string) {
+	t.Helper()
+	for i := 0; i < len(tests); i += 2 {
+		got := middle(renderString(tests[i], RendererOptions{}))
+		if got != tests[i+1] {
+			t.Errorf("\nInput:    %q\nExpected: %q\nGot:      %q", tests[i], tests[i+1], got)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []string{
+		"# Intro {#intro}\n\nSome *emph*, **strong** & `code`.\n\n## Sub\n\nText\n\n# Next\n",
+		"<section anchor=\"intro\">\n<name>Intro</name>\n<t>Some <em>emph</em>, <strong>strong</strong> &amp; <tt>code</tt>.</t>\n" +
+			"<section>\n<name>Sub</name>\n<t>Text</t>\n</section>\n</section>\n<section>\n<name>Next</name>\n</section>\n",
+
+		"See (#intro), [web](https://x.org) and <https://y.org>.\n",
+		"<t>See <xref target=\"intro\"/>, <eref target=\"https://x.org\">web</eref> and <eref target=\"https://y.org\"/>.</t>\n",
+
+		"H~2~O(!!DNS, zone) and a note[^1].\n\n[^1]: The *note*.\n",
+		"<t>H<sub>2</sub>O<iref item=\"DNS\" subitem=\"zone\" primary=\"true\"/> and a note<cref>The <em>note</em>.</cref>.</t>\n",
+
+		"* a\n* b\n\n3. c\n\nTerm\n: Definition\n",
+		"<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol start=\"3\">\n<li>c</li>\n</ol>\n<dl>\n<dt>Term</dt>\n<dd>Definition</dd>\n</dl>\n",
+
+		"```go\na < b\n```\n\n    art\n",
+		"<sourcecode type=\"go\"><![CDATA[a < b\n]]></sourcecode>\n<artwork type=\"ascii-art\"><![CDATA[art\n]]></artwork>\n",
+
+		"```\ncode\n```\nFigure: The *code* {#code}\n",
+		"<figure anchor=\"code\">\n<name>The <em>code</em></name>\n<sourcecode><![CDATA[code\n]]></sourcecode>\n</figure>\n",
+
+		"| a | b |\n|:--|--:|\n| 1 | 2 |\nTable: Numbers {#numbers}\n",
+		"<table anchor=\"numbers\">\n<name>Numbers</name>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n" +
+			"<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"right\">2</td>\n</tr>\n</tbody>\n</table>\n",
+
+		"> quote\n\nQuote: Someone\n\nA> aside\n",
+		"<blockquote quotedFrom=\"Someone\">\n<t>quote</t>\n</blockquote>\n<aside>\n<t>aside</t>\n</aside>\n",
+
+		"a <b>bold<br> text</b>\n\n<div>\n<p>html\n</div>\n",
+		"<t>a bold text</t>\n",
+	}
+	doTests(t, tests)
+}
+
+func TestRenderNestedHeadings(t *testing.T) {
+	tests := []string{
+		"> # H\n> text\n",
+		"<blockquote>\n<t><strong>H</strong></t>\n<t>text</t>\n</blockquote>\n",
+
+		"* List\n# Header\n* List\n",
+		"<ul>\n<li><t>List</t>\n<t><strong>Header</strong></t>\n</li>\n<li><t>List</t>\n</li>\n</ul>\n",
+
+		"*   List\n    # Nested header\n",
+		"<ul>\n<li><t>List</t>\n<t><strong>Nested header</strong></t>\n</li>\n</ul>\n",
+	}
+	doTests(t, tests)
+}
+
+// TestRenderWellFormed checks that the testdata corpus renders to
+// well-formed XML.
+func TestRenderWellFormed(t *testing.T) {
+	var inputs [][]byte
+	for _, pattern := range []string{"*.text", "*.md", "*.test", "*.tests"} {
+		paths, err := filepath.Glob(filepath.Join("..", "testdata", pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			d, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasSuffix(path, ".text") || strings.HasSuffix(path, ".md") {
+				inputs = append(inputs, d)
+				continue
+			}
+			// .test(s) files alternate between markdown and the expected html
+			parts := bytes.Split(d, []byte("+++\n"))
+			for i := 0; i < len(parts); i += 2 {
+				inputs = append(inputs, parts[i])
+			}
+		}
+	}
+
+	for _, input := range inputs {
+		if !utf8.Valid(input) {
+			continue
+		}
+		out := renderString(string(input), RendererOptions{})
+		dec := xml.NewDecoder(strings.NewReader(out))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%v\nInput:\n%s\nOutput:\n%s", err, input, out)
+				break
+			}
+		}
+	}
+}
+
+func TestRenderDocument(t *testing.T) {
+	input := `% The Title
+
+{frontmatter}
+
+.# Abstract
+
+The abstract.
+
+{mainmatter}
+
+# Intro
+
+See [@RFC2119, Section 3], [@!RFC8174; @-I-D.foo] and [@bar].
+
+{backmatter}
+
+.# Acknowledgements
+
+Thanks.
+`
+	opts := RendererOptions{
+		DocName: "draft-x-00",
+		Authors: []Author{{Fullname: "A. Bee", Initials: "A.", Surname: "Bee", Email: "a@bee.org"}},
+		References: map[string]string{
+			"bar": `<reference anchor="bar"><front><title>Bar</title></front></reference>`,
+		},
+	}
+	exp := `<?xml version="1.0" encoding="utf-8"?>
+<rfc version="3" ipr="trust200902" docName="draft-x-00" submissionType="IETF" category="info" xml:lang="en" xmlns:xi="http://www.w3.org/2001/XInclude">
+<front>
+<title>The Title</title>
+<seriesInfo name="Internet-Draft" value="draft-x-00"/>
+<author fullname="A. Bee" initials="A." surname="Bee">
+<address>
+<email>a@bee.org</email>
+</address>
+</author>
+<abstract>
+<t>The abstract.</t>
+</abstract>
+</front>
+<middle>
+<section>
+<name>Intro</name>
+<t>See <xref target="RFC2119" section="3" sectionFormat="of"/>, <xref target="RFC8174"/> and <xref target="bar"/>.</t>
+</section>
+</middle>
+<back>
+<references>
+<name>Normative References</name>
+<xi:include href="https://bib.ietf.org/public/rfc/bibxml/reference.RFC.8174.xml"/>
+</references>
+<references>
+<name>Informative References</name>
+<xi:include href="https://bib.ietf.org/public/rfc/bibxml/reference.RFC.2119.xml"/>
+<xi:include href="https://bib.ietf.org/public/rfc/bibxml3/reference.I-D.foo.xml"/>
+<reference anchor="bar"><front><title>Bar</title></front></reference>
+</references>
+<section numbered="false">
+<name>Acknowledgements</name>
+<t>Thanks.</t>
+</section>
+</back>
+</rfc>
+`
+	got := renderString(input, opts)
+	if got != exp {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", exp, got)
+	}
+
+	// without divisions the document is the middle and the references
+	// are in the back
+	got = renderString("# Intro\n\n[@RFC791]\n", RendererOptions{})
+	exp = "<middle>\n<section>\n<name>Intro</name>\n<t><xref target=\"RFC791\"/></t>\n</section>\n</middle>\n<back>\n" +
+		"<references>\n<name>Informative References</name>\n<xi:include href=\"https://bib.ietf.org/public/rfc/bibxml/reference.RFC.0791.xml\"/>\n</references>\n</back>\n</rfc>\n"
+	if !strings.HasSuffix(got, exp) {
+		t.Errorf("\nExpected suffix:\n%s\nGot:\n%s", exp, got)
+	}
+}
+
+func TestRenderCalloutEscaped(t *testing.T) {
+	var buf bytes.Buffer
+	NewRenderer(RendererOptions{}).RenderNode(&buf, &ast.Callout{ID: []byte("<1>")}, true)
+	if got, exp := buf.String(), "(&lt;1&gt;)"; got != exp {
+		t.Errorf("got %q, want %q", got, exp)
+	}
+}